@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// apiKeyRoles are the roles an API key may be granted.
+var apiKeyRoles = []string{"user", "organizer", "admin"}
+
+// APIKey is a static credential for a server-to-server caller.
+type APIKey struct {
+	// Name identifies the partner; it becomes the caller's token subject as "apikey:<name>".
+	Name string
+	Role string
+	Key  string
+}
+
+// ParseAPIKeys reads the API_KEYS environment variable: comma separated name:role:key
+// entries, such as "box-office:organizer:s3cret".
+func ParseAPIKeys(s string) ([]APIKey, error) {
+	var keys []APIKey
+	names := make(map[string]bool)
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("API key entries must look like name:role:key")
+		}
+		name, role, key := parts[0], parts[1], parts[2]
+		if !slices.Contains(apiKeyRoles, role) {
+			return nil, fmt.Errorf("API key %q has unknown role %q; want one of %s", name, role, strings.Join(apiKeyRoles, ", "))
+		}
+		if names[name] {
+			return nil, fmt.Errorf("API key name %q is used more than once", name)
+		}
+		names[name] = true
+		keys = append(keys, APIKey{Name: name, Role: role, Key: key})
+	}
+	return keys, nil
+}
+
+// APIKeyMiddleware authenticates an X-API-Key header against keys and stores the matching
+// key's role as the request's claims, so RBAC and the rate limiter treat partners exactly
+// like callers with a verified token. Requests without the header pass through; an unknown
+// key, or one sent alongside a bearer token, is rejected with 401. No keys disables it.
+//
+// Keys are compared by SHA-256 digest in constant time, and every configured key is
+// checked, so response timing reveals neither how much of a key matched nor which one.
+func APIKeyMiddleware(keys []APIKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(keys) == 0 {
+			return next
+		}
+		digests := make([][sha256.Size]byte, len(keys))
+		for i, k := range keys {
+			digests[i] = sha256.Sum256([]byte(k.Key))
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented := r.Header.Get("X-API-Key")
+			if presented == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ClaimsFromContext(r.Context()) != nil {
+				SendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized: send either a bearer token or an API key, not both"})
+				return
+			}
+
+			digest := sha256.Sum256([]byte(presented))
+			match := -1
+			for i := range digests {
+				if subtle.ConstantTimeCompare(digest[:], digests[i][:]) == 1 {
+					match = i
+				}
+			}
+			if match < 0 {
+				SendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized: invalid API key"})
+				return
+			}
+
+			claims := &Claims{Subject: "apikey:" + keys[match].Name, Role: keys[match].Role}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsContextKey, claims)))
+		})
+	}
+}
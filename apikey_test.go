@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKeyAuthenticatesServerCallers(t *testing.T) {
+	keys, err := ParseAPIKeys("box-office:admin:s3cret-admin, kiosk:user:s3cret-user")
+	if err != nil {
+		t.Fatalf("Failed to parse keys: %v", err)
+	}
+	router := (&Handlers{DB: NewTestDB(t), JWTSecret: testJWTSecret, APIKeys: keys}).Routes()
+	adminToken := signJWT(t, Claims{Subject: "admin-1", Role: "admin"}, testJWTSecret)
+
+	undrain := func(addr, key, token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/admin/undrain", nil)
+		req.RemoteAddr = addr
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := undrain("10.0.0.1:1", "s3cret-admin", ""); code != http.StatusOK {
+		t.Errorf("Expected 200 for a valid admin key, got %d", code)
+	}
+	if code := undrain("10.0.0.2:1", "s3cret-user", ""); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a key whose role is user, got %d", code)
+	}
+	if code := undrain("10.0.0.3:1", "s3cret-admin-not", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown key, got %d", code)
+	}
+	if code := undrain("10.0.0.4:1", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a key, got %d", code)
+	}
+	if code := undrain("10.0.0.5:1", "s3cret-admin", adminToken); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a key sent alongside a token, got %d", code)
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys(" a:organizer:k1 ,, b:admin:k:2 ")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != (APIKey{Name: "a", Role: "organizer", Key: "k1"}) || keys[1].Key != "k:2" {
+		t.Errorf("Unexpected keys: %+v", keys)
+	}
+
+	for _, bad := range []string{"a:admin", "a:root:k", ":admin:k", "a:admin:", "a:admin:k1,a:user:k2"} {
+		if _, err := ParseAPIKeys(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
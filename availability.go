@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Availability is one event's entry in GET /events/availability.
+type Availability struct {
+	Available int  `json:"available"`
+	Total     int  `json:"total"`
+	SoldOut   bool `json:"sold_out"`
+}
+
+// AvailabilityByIDs returns the spot counts of the published events with the given ids,
+// read in one query. Ids with no event, drafts and cancelled events are left out.
+func (db *DB) AvailabilityByIDs(ctx context.Context, ids []int64) (map[int64]Availability, error) {
+	availability := make(map[int64]Availability, len(ids))
+	if len(ids) == 0 {
+		return availability, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := db.QueryContext(ctx, `SELECT id, available_spots, total_spots FROM events WHERE id IN (`+placeholders+`) AND status = ?`, append(args, EventStatusPublished)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query availability: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var a Availability
+		if err := rows.Scan(&id, &a.Available, &a.Total); err != nil {
+			return nil, fmt.Errorf("failed to scan availability: %w", err)
+		}
+		a.SoldOut = a.Available == 0
+		availability[id] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query availability: %w", err)
+	}
+	return availability, nil
+}
+
+// parseEventIDs reads a comma-separated ?ids= list of at most maxPageLimit event ids.
+func parseEventIDs(raw string) ([]int64, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxPageLimit {
+		return nil, fmt.Errorf("ids may list at most %d events", maxPageLimit)
+	}
+	ids := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil || id < 1 {
+			return nil, errors.New("ids must be a comma-separated list of event ids")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// HandleEventAvailability handles GET /events/availability?ids=1,2,3, answering a map of
+// event id to its spot counts so a page of event cards needs a single call.
+func (h *Handlers) HandleEventAvailability(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("ids")
+	if raw == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "ids is required"})
+		return
+	}
+	ids, err := parseEventIDs(raw)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	availability, err := h.DB.AvailabilityByIDs(r.Context(), ids)
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	SendJSON(w, http.StatusOK, availability)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestEventAvailabilityBatch(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	router := (&Handlers{DB: db}).Routes()
+
+	open, err := db.CreateEvent(ctx, Event{Name: "Open Mic", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	full, err := db.CreateEvent(ctx, Event{Name: "Tiny Table", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	draft, err := db.CreateEvent(ctx, Event{Name: "Not Yet", TotalSpots: 5, Status: EventStatusDraft})
+	if err != nil {
+		t.Fatalf("Failed to create draft: %v", err)
+	}
+	cancelled, err := db.CreateEvent(ctx, Event{Name: "Called Off", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE events SET status = ? WHERE id = ?`, EventStatusCancelled, cancelled.ID); err != nil {
+		t.Fatalf("Failed to cancel event: %v", err)
+	}
+	for i, evt := range []*Event{open, open, full} {
+		email := fmt.Sprintf("guest%d@example.com", i)
+		if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: email, IdempotencyKey: "key_" + email}); err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+	}
+
+	rec := serve(router, http.MethodGet, fmt.Sprintf("/events/availability?ids=%d,424242,%d,%d,%d", open.ID, full.ID, draft.ID, cancelled.ID), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var got map[int64]Availability
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode availability: %v", err)
+	}
+	want := map[int64]Availability{
+		open.ID: {Available: 3, Total: 5},
+		full.ID: {Available: 0, Total: 1, SoldOut: true},
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected only the published events, got %v", got)
+	}
+	for id, w := range want {
+		if got[id] != w {
+			t.Errorf("Event %d: expected %+v, got %+v", id, w, got[id])
+		}
+	}
+
+	for _, query := range []string{"", "?ids=", "?ids=1,abc"} {
+		if rec := serve(router, http.MethodGet, "/events/availability"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "time"
+
+// Clock tells the time. Every hold, expiry and registration-window decision goes through
+// the DB's Clock and is handed to SQLite as a bound parameter, never datetime('now'), so
+// tests can move time forward instead of waiting for it.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the wall clock.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// MockClock is a Clock that only moves when told to.
+type MockClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewMockClock(now time.Time) *MockClock {
+	return &MockClock{now: now}
+}
+
+func (c *MockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *MockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestReservationExpiryFollowsClock(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Frozen Time", TotalSpots: 2})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	slow, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "slow@example.com", IdempotencyKey: "slow"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	prompt, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "prompt@example.com", IdempotencyKey: "prompt"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	if want := clock.Now().Add(defaultHoldDuration); !slow.ExpiresAt.Equal(want) {
+		t.Fatalf("Expected expires_at %v, got %v", want, slow.ExpiresAt)
+	}
+
+	// One second before the deadline nothing has lapsed and confirmation still works
+	clock.Advance(defaultHoldDuration - time.Second)
+	if n, err := db.ReclaimExpiredSeats(ctx); err != nil || n != 0 {
+		t.Fatalf("Expected nothing reclaimed before the deadline, got %d (%v)", n, err)
+	}
+	if err := db.ConfirmReservation(ctx, prompt.ID, "prompt@example.com", ""); err != nil {
+		t.Fatalf("Expected confirmation inside the hold, got %v", err)
+	}
+
+	// At the deadline the remaining hold is gone
+	clock.Advance(time.Second)
+	if err := db.ConfirmReservation(ctx, slow.ID, "slow@example.com", ""); !errors.Is(err, ErrTicketExpired) {
+		t.Fatalf("Expected ErrTicketExpired at the deadline, got %v", err)
+	}
+	if n, err := db.ReclaimExpiredSeats(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected exactly the unconfirmed hold reclaimed, got %d (%v)", n, err)
+	}
+
+	got, err := db.GetEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to load event: %v", err)
+	}
+	if got.AvailableSpots != 1 {
+		t.Errorf("Expected 1 spot back in the pool, got %d", got.AvailableSpots)
+	}
+}
+
+func TestPerEventHoldDuration(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+
+	flashSeconds := 60
+	flash, err := db.CreateEvent(ctx, Event{Name: "Flash Sale", TotalSpots: 1, HoldSeconds: &flashSeconds})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	regular, err := db.CreateEvent(ctx, Event{Name: "Regular Sale", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: flash.ID, Email: "fast@example.com", IdempotencyKey: "flash"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: regular.ID, Email: "calm@example.com", IdempotencyKey: "regular"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	// Past the event's own hold only its reservation lapses
+	clock.Advance(time.Duration(flashSeconds) * time.Second)
+	if n, err := db.ReclaimExpiredSeats(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected only the flash sale hold reclaimed, got %d (%v)", n, err)
+	}
+	if got, err := db.GetEvent(ctx, flash.ID); err != nil || got.AvailableSpots != 1 {
+		t.Fatalf("Expected the flash sale spot back, got %+v (%v)", got, err)
+	}
+
+	// The other event keeps the server-wide default
+	clock.Advance(defaultHoldDuration - time.Duration(flashSeconds)*time.Second)
+	if n, err := db.ReclaimExpiredSeats(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected the default hold reclaimed at its own deadline, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClientConfig is the non-sensitive server configuration exposed to frontends.
+type ClientConfig struct {
+	ServerTime  time.Time `json:"server_time"`
+	HoldSeconds int       `json:"hold_seconds"`
+}
+
+// HandleConfig handles GET /config. Clients compute reservation deadlines against
+// server_time rather than their own, possibly skewed, clock.
+func (h *Handlers) HandleConfig(w http.ResponseWriter, r *http.Request) {
+	SendJSON(w, http.StatusOK, ClientConfig{
+		ServerTime:  h.DB.Clock.Now().UTC().Truncate(time.Second),
+		HoldSeconds: int(h.DB.DefaultHold().Seconds()),
+	})
+}
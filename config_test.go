@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConfigReportsClockAndHold(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 5, 1, 10, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	db.HoldDuration = 90 * time.Second
+	router := (&Handlers{DB: db}).Routes()
+
+	rec := serve(router, http.MethodGet, "/config", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var cfg ClientConfig
+	if err := json.NewDecoder(rec.Body).Decode(&cfg); err != nil {
+		t.Fatalf("Failed to decode config: %v", err)
+	}
+	if cfg.HoldSeconds != 90 {
+		t.Errorf("Expected hold_seconds 90, got %d", cfg.HoldSeconds)
+	}
+	if !cfg.ServerTime.Equal(clock.Now()) {
+		t.Errorf("Expected server_time %v, got %v", clock.Now(), cfg.ServerTime)
+	}
+}
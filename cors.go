@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsAllowedMethods and corsAllowedHeaders cover every route and request header the API
+// uses; corsExposedHeaders are the response headers scripts may read.
+const (
+	corsAllowedMethods = "GET, POST, PATCH, DELETE, OPTIONS"
+	corsAllowedHeaders = "Authorization, Content-Type, X-Role, X-User-Email, X-API-Key, Idempotency-Key"
+	corsExposedHeaders = "X-Request-ID, Retry-After, Idempotency-Replayed, X-Total-Count"
+)
+
+// CORSConfig controls cross-origin access from browsers. No AllowedOrigins disables CORS.
+type CORSConfig struct {
+	// AllowedOrigins lists exact origins such as "https://tickets.example.com", or "*".
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and auth headers cross-origin. The
+	// matching origin is then echoed back, since "*" is not valid with credentials.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight result; 0 omits the header.
+	MaxAge time.Duration
+}
+
+// Validate rejects configurations browsers would refuse anyway.
+func (c CORSConfig) Validate() error {
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		return errors.New("CORS credentials cannot be combined with a wildcard origin; list the origins explicitly")
+	}
+	return nil
+}
+
+func (c CORSConfig) allows(origin string) bool {
+	return slices.Contains(c.AllowedOrigins, "*") || slices.Contains(c.AllowedOrigins, origin)
+}
+
+// ParseOrigins splits a comma separated -cors-origins value.
+func ParseOrigins(s string) []string {
+	var origins []string
+	for _, o := range strings.Split(s, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// CORSMiddleware adds CORS headers for allowed origins and answers preflight requests
+// itself, so OPTIONS never reaches RBAC or the router. Requests from other origins get no
+// CORS headers, which makes the browser block them.
+func CORSMiddleware(cfg CORSConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(cfg.AllowedOrigins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if !cfg.allows(origin) {
+				if preflight {
+					SendJSON(w, http.StatusForbidden, map[string]string{"error": "Origin not allowed"})
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if cfg.AllowCredentials || !slices.Contains(cfg.AllowedOrigins, "*") {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			}
+			if cfg.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
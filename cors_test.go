@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSAllowedOriginWithCredentials(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t), CORS: CORSConfig{
+		AllowedOrigins:   []string{"https://tickets.example.com"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}}).Routes()
+
+	req := httptest.NewRequest(http.MethodOptions, "/events/1/register", nil)
+	req.Header.Set("Origin", "https://tickets.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type, x-role")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for the preflight, got %d", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://tickets.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+		"Vary":                             "Origin",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("Expected %s %q, got %q", header, want, got)
+		}
+	}
+
+	// The actual request carries the same origin echo
+	req = httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Origin", "https://tickets.example.com")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://tickets.example.com" {
+		t.Errorf("Expected 200 with the origin echoed, got %d and %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSRejectsUnknownOrigin(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t), CORS: CORSConfig{
+		AllowedOrigins:   []string{"https://tickets.example.com"},
+		AllowCredentials: true,
+	}}).Routes()
+
+	req := httptest.NewRequest(http.MethodOptions, "/events", nil)
+	req.Header.Set("Origin", "https://evil.example.net")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a preflight from an unknown origin, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Origin, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Credentials, got %q", got)
+	}
+}
+
+func TestCORSConfigRejectsWildcardWithCredentials(t *testing.T) {
+	if err := (CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}).Validate(); err == nil {
+		t.Error("Expected wildcard origin with credentials to be rejected")
+	}
+	if err := (CORSConfig{AllowedOrigins: []string{"*"}}).Validate(); err != nil {
+		t.Errorf("Expected wildcard origin without credentials to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultCurrency prices events when neither the request nor -base-currency names one.
+const defaultCurrency = "USD"
+
+// knownCurrencies is the set of ISO 4217 codes events may be priced in. Prices are always
+// stored in the currency's minor units (price_cents), so adding a code here is enough.
+var knownCurrencies = map[string]bool{
+	"AED": true, "ARS": true, "AUD": true, "BRL": true, "CAD": true, "CHF": true,
+	"CLP": true, "CNY": true, "COP": true, "CZK": true, "DKK": true, "EGP": true,
+	"EUR": true, "GBP": true, "HKD": true, "HUF": true, "IDR": true, "ILS": true,
+	"INR": true, "JPY": true, "KES": true, "KRW": true, "MXN": true, "MYR": true,
+	"NGN": true, "NOK": true, "NZD": true, "PHP": true, "PKR": true, "PLN": true,
+	"RON": true, "SAR": true, "SEK": true, "SGD": true, "THB": true, "TRY": true,
+	"TWD": true, "UAH": true, "USD": true, "VND": true, "ZAR": true,
+}
+
+// ParseCurrency normalises an ISO 4217 code to upper case and rejects unknown ones.
+func ParseCurrency(code string) (string, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(code))
+	if !knownCurrencies[normalized] {
+		return "", fmt.Errorf("unsupported currency %q, want an ISO 4217 code such as USD", code)
+	}
+	return normalized, nil
+}
+
+func (h *Handlers) baseCurrency() string {
+	if h.BaseCurrency != "" {
+		return h.BaseCurrency
+	}
+	return defaultCurrency
+}
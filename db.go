@@ -2,238 +2,1917 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// sqliteTimeFormat matches SQLite's own datetime() / CURRENT_TIMESTAMP layout so that
+// values we bind compare correctly against values SQLite generates.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// defaultHoldDuration is how long a reservation holds its spot before the reclaimer frees
+// it, unless DB.HoldDuration or the event's own hold_seconds says otherwise.
+const defaultHoldDuration = 5 * time.Minute
+
+// sqliteTimestamp formats t the way nullableTime and SQLite store DATETIME values.
+func sqliteTimestamp(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// noExpiry is stored as expires_at on tickets that never lapse (e.g. auto-confirmed ones).
+const noExpiry = "9999-12-31 23:59:59"
+
 // DB represents our database layer
 type DB struct {
 	*sql.DB
+
+	// Clock supplies "now" for holds, expiry and registration windows.
+	Clock Clock
+
+	// HoldDuration is the reservation hold for events without their own hold_seconds.
+	HoldDuration time.Duration
+
+	// OnSoldOut, if set, is called once per sell-out: after the registration that takes an
+	// event's last spot commits. It runs on the registering request, so keep it quick.
+	OnSoldOut func(ctx context.Context, eventID int64)
+
+	// MaxWaitlist caps each event's waitlist unless the event sets its own max_waitlist;
+	// 0 leaves waitlists unbounded.
+	MaxWaitlist int
+
+	// IdempotencyScope decides how far an idempotency key is unique; InitSchema builds
+	// the matching index. The zero value is IdempotencyScopeGlobal.
+	IdempotencyScope IdempotencyScope
+
+	// memoryAnchor holds a shared in-memory database open, see openMemoryAnchor.
+	memoryAnchor *sql.DB
+}
+
+// IdempotencyScope is how widely a registration's idempotency key must be unique.
+type IdempotencyScope string
+
+const (
+	// IdempotencyScopeGlobal allows each key once across all events.
+	IdempotencyScopeGlobal IdempotencyScope = "global"
+	// IdempotencyScopeEvent allows each key once per event, so clients may reuse keys
+	// across events.
+	IdempotencyScopeEvent IdempotencyScope = "event"
+)
+
+// ParseIdempotencyScope validates a -idempotency-scope value.
+func ParseIdempotencyScope(s string) (IdempotencyScope, error) {
+	switch scope := IdempotencyScope(s); scope {
+	case IdempotencyScopeGlobal, IdempotencyScopeEvent:
+		return scope, nil
+	}
+	return "", fmt.Errorf("idempotency scope must be %q or %q, got %q", IdempotencyScopeGlobal, IdempotencyScopeEvent, s)
+}
+
+// Tuning holds optional SQLite performance pragmas and startup behaviour. Pragmas are
+// passed as _pragma DSN parameters, so every pooled connection gets them before it runs
+// its first query.
+type Tuning struct {
+	// CacheSizeKiB sets PRAGMA cache_size (as the negative, KiB form); 0 keeps SQLite's
+	// ~2 MiB default. Tens of MiB keep the events and tickets tables hot for list and
+	// availability reads.
+	CacheSizeKiB int
+	// MmapSize sets PRAGMA mmap_size in bytes; 0 leaves memory-mapped I/O off. A few
+	// hundred MiB lets reads skip a copy through the page cache.
+	MmapSize int64
+	// PingAttempts is how many times the startup check, preparing the database directory
+	// and then a Ping, is tried before NewDB gives up, so a data volume that is mounted a
+	// moment after the process starts is not fatal; 0 or 1 tries once.
+	PingAttempts int
+	// PingBackoff is the wait after the first failed check, doubling after each further
+	// failure up to maxPingBackoff.
+	PingBackoff time.Duration
+}
+
+// maxPingBackoff caps the wait between startup Ping attempts.
+const maxPingBackoff = 30 * time.Second
+
+// pingDatabase is the startup connectivity check and prepareDirectory the directory check
+// before it; tests replace them to simulate a database that only becomes reachable after
+// a few attempts.
+var (
+	pingDatabase     = (*sql.DB).Ping
+	prepareDirectory = prepareDatabaseDir
+)
+
+// ping prepares dsn's directory and pings db, up to t.PingAttempts times, logging each failure.
+func (t Tuning) ping(db *sql.DB, dsn string) error {
+	attempts := max(t.PingAttempts, 1)
+	backoff := t.PingBackoff
+	for attempt := 1; ; attempt++ {
+		err := prepareDirectory(dsn)
+		if err == nil {
+			if err = pingDatabase(db); err != nil {
+				err = fmt.Errorf("failed to ping database: %w", err)
+			}
+		}
+		if err == nil || attempt >= attempts {
+			return err
+		}
+		slog.Warn("database ping failed, retrying", "attempt", attempt, "max_attempts", attempts, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxPingBackoff)
+	}
+}
+
+// dsn appends t's pragmas to dsn.
+func (t Tuning) dsn(dsn string) string {
+	var pragmas []string
+	if t.CacheSizeKiB > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("_pragma=cache_size(%d)", -t.CacheSizeKiB))
+	}
+	if t.MmapSize > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("_pragma=mmap_size(%d)", t.MmapSize))
+	}
+	if len(pragmas) == 0 {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + strings.Join(pragmas, "&")
 }
 
 // NewDB initializes and connects to the SQLite database
 func NewDB(dsn string) (*DB, error) {
-	db, err := sql.Open("sqlite", dsn)
+	return NewDBWithTuning(dsn, Tuning{})
+}
+
+// NewDBWithTuning is NewDB with performance pragmas applied to every connection.
+func NewDBWithTuning(dsn string, tuning Tuning) (*DB, error) {
+	if err := validateDSN(dsn); err != nil {
+		return nil, err
+	}
+
+	// Opening touches nothing on disk yet; the directory is prepared by the retried check
+	db, err := sql.Open("sqlite", tuning.dsn(dsn))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Important settings for SQLite concurrency.
 	// We want to avoid "database is locked" errors during high concurrent writes.
+	// A shared in-memory database depends on it too: shared-cache connections lock whole
+	// tables and fail with SQLITE_LOCKED rather than waiting, so only one may do work.
 	db.SetMaxOpenConns(1)
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	if err := tuning.ping(db, dsn); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	var anchor *sql.DB
+	if isSharedMemoryDSN(dsn) {
+		if anchor, err = openMemoryAnchor(dsn); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, Clock: RealClock{}, HoldDuration: defaultHoldDuration, memoryAnchor: anchor}, nil
 }
 
-// InitSchema sets up the required tables
-func (db *DB) InitSchema(ctx context.Context) error {
-	schema := `
+// dsnParams lists the query parameters a DSN may carry, with their allowed values where
+// the set is closed. The driver ignores or misreads anything else, so a typo would
+// otherwise only show up as a confusing failure much later.
+var dsnParams = map[string][]string{
+	"mode":         {"ro", "rw", "rwc", "memory"},
+	"cache":        {"shared", "private"},
+	"immutable":    {"0", "1"},
+	"nolock":       {"0", "1"},
+	"vfs":          nil,
+	"_pragma":      nil,
+	"_txlock":      {"deferred", "immediate", "exclusive"},
+	"_time_format": {"sqlite"},
+}
+
+// knownPragmas are the pragmas accepted in a DSN's _pragma parameters.
+var knownPragmas = map[string]bool{
+	"analysis_limit": true, "auto_vacuum": true, "automatic_index": true, "busy_timeout": true,
+	"cache_size": true, "cache_spill": true, "case_sensitive_like": true, "cell_size_check": true,
+	"defer_foreign_keys": true, "encoding": true, "foreign_keys": true, "hard_heap_limit": true,
+	"ignore_check_constraints": true, "journal_mode": true, "journal_size_limit": true,
+	"legacy_alter_table": true, "locking_mode": true, "max_page_count": true, "mmap_size": true,
+	"page_size": true, "query_only": true, "read_uncommitted": true, "recursive_triggers": true,
+	"reverse_unordered_selects": true, "secure_delete": true, "soft_heap_limit": true,
+	"synchronous": true, "temp_store": true, "threads": true, "trusted_schema": true,
+	"wal_autocheckpoint": true,
+}
+
+// pragmaPattern matches a _pragma value: a name, optionally followed by "(value)".
+var pragmaPattern = regexp.MustCompile(`^([A-Za-z_]+)(\([^()]*\))?$`)
+
+// validateDSN rejects DSNs the driver would fail on (or silently misread) with an error
+// naming the offending part, before anything is opened.
+func validateDSN(dsn string) error {
+	if strings.TrimSpace(dsn) == "" {
+		return errors.New("invalid DSN: empty; want a file path, a file: URI or :memory:")
+	}
+	path, rawQuery, _ := strings.Cut(dsn, "?")
+	if scheme, _, found := strings.Cut(path, ":"); found && scheme != "file" && scheme != "" && len(scheme) > 1 {
+		return fmt.Errorf("invalid DSN %q: unsupported scheme %q; want a file path, a file: URI or :memory:", dsn, scheme)
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return fmt.Errorf("invalid DSN %q: malformed query: %w", dsn, err)
+	}
+	for key, values := range query {
+		allowed, known := dsnParams[key]
+		if !known {
+			return fmt.Errorf("invalid DSN %q: unknown parameter %q", dsn, key)
+		}
+		for _, v := range values {
+			if allowed != nil && !slices.Contains(allowed, v) {
+				return fmt.Errorf("invalid DSN %q: %s must be one of %s, got %q", dsn, key, strings.Join(allowed, ", "), v)
+			}
+			if key != "_pragma" {
+				continue
+			}
+			m := pragmaPattern.FindStringSubmatch(v)
+			if m == nil {
+				return fmt.Errorf("invalid DSN %q: malformed _pragma %q; want name(value), e.g. busy_timeout(5000)", dsn, v)
+			}
+			if !knownPragmas[strings.ToLower(m[1])] {
+				return fmt.Errorf("invalid DSN %q: unknown pragma %q", dsn, m[1])
+			}
+		}
+	}
+	return nil
+}
+
+// databaseFile extracts the on-disk path from a SQLite DSN such as "events.db" or
+// "file:data/events.db?mode=rwc". ok is false for in-memory databases.
+func databaseFile(dsn string) (path string, query url.Values, ok bool) {
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	query, _ = url.ParseQuery(rawQuery)
+
+	// file:///abs/path URIs carry an empty authority
+	path = strings.TrimPrefix(path, "//")
+
+	if path == "" || path == ":memory:" || strings.HasPrefix(path, ":memory:") || query.Get("mode") == "memory" {
+		return "", query, false
+	}
+	return path, query, true
+}
+
+// prepareDatabaseDir makes sure the directory holding a file-based database exists and
+// is writable, so that a missing data volume fails loudly instead of SQLite quietly
+// creating the file somewhere else or failing with a cryptic open error.
+func prepareDatabaseDir(dsn string) error {
+	path, query, ok := databaseFile(dsn)
+	if !ok || query.Get("mode") == "ro" {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("cannot create database directory %q: %w", dir, err)
+	}
+
+	probe, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("database directory %q is not writable: %w", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+// baseSchema is the schema as of version 4, applied by the first migration. Its IF NOT
+// EXISTS clauses leave tables from older versions alone; later migrations bring those up
+// to date and add everything newer.
+const baseSchema = `
 	CREATE TABLE IF NOT EXISTS events (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL,
 		total_spots INTEGER NOT NULL,
 		available_spots INTEGER NOT NULL,
+		starts_at DATETIME,
+		status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'published', 'cancelled')),
+		organizer_email TEXT,
+		auto_confirm BOOLEAN NOT NULL DEFAULT 0,
+		registration_opens_at DATETIME,
+		registration_closes_at DATETIME,
+		hold_seconds INTEGER CHECK (hold_seconds > 0),
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		price_cents INTEGER NOT NULL DEFAULT 0 CHECK (price_cents >= 0),
+		currency TEXT NOT NULL DEFAULT 'USD',
 		CHECK (available_spots >= 0)
 	);
 
 	CREATE TABLE IF NOT EXISTS tickets (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		event_id INTEGER NOT NULL,
-		user_email TEXT NOT NULL,
-		idempotency_key TEXT UNIQUE NOT NULL,
+		-- Guest tickets have no email and are identified by their claim_token instead;
+		-- UNIQUE(event_id, user_email) ignores them because NULLs never collide
+		user_email TEXT,
+		claim_token TEXT UNIQUE,
+		-- Unique globally or per event, see applyIdempotencyScope
+		idempotency_key TEXT NOT NULL,
 		status TEXT DEFAULT 'reserved' CHECK (status IN ('reserved', 'confirmed', 'cancelled')),
+		quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+		confirmation_code TEXT,
+		amount_due INTEGER NOT NULL DEFAULT 0,
+		currency TEXT NOT NULL DEFAULT 'USD',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		expires_at DATETIME NOT NULL,
 		FOREIGN KEY (event_id) REFERENCES events(id),
+		UNIQUE(event_id, user_email),
+		CHECK (user_email IS NOT NULL OR claim_token IS NOT NULL)
+	);
+
+	-- The reclaim worker scans reserved tickets by expiry every tick
+	CREATE INDEX IF NOT EXISTS idx_tickets_status_expires_at ON tickets(status, expires_at);
+	CREATE INDEX IF NOT EXISTS idx_tickets_event_id ON tickets(event_id);
+
+	-- Tags are stored normalised (lowercase, no commas), one row per event and tag
+	CREATE TABLE IF NOT EXISTS event_tags (
+		event_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (event_id, tag),
+		FOREIGN KEY (event_id) REFERENCES events(id)
+	) WITHOUT ROWID;
+	CREATE INDEX IF NOT EXISTS idx_event_tags_tag ON event_tags(tag, event_id);
+
+	CREATE TABLE IF NOT EXISTS seats (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		seat_label TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'available' CHECK (status IN ('available', 'assigned')),
+		ticket_id INTEGER,
+		FOREIGN KEY (event_id) REFERENCES events(id),
+		FOREIGN KEY (ticket_id) REFERENCES tickets(id),
+		UNIQUE(event_id, seat_label)
+	);
+
+	CREATE TABLE IF NOT EXISTS waitlist (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		user_email TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (event_id) REFERENCES events(id),
 		UNIQUE(event_id, user_email)
 	);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		ticket_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
 	`
-	_, err := db.ExecContext(ctx, schema)
-	return err
+
+// InitSchema sets up the required tables, migrating an existing database to the current
+// schema, and builds the idempotency index for db.IdempotencyScope.
+func (db *DB) InitSchema(ctx context.Context) error {
+	if err := db.migrate(ctx); err != nil {
+		return err
+	}
+	return db.applyIdempotencyScope(ctx)
+}
+
+// applyIdempotencyScope swaps in the unique index for db.IdempotencyScope. Switching from
+// per-event to global fails if some key has since been used at more than one event.
+func (db *DB) applyIdempotencyScope(ctx context.Context) error {
+	if db.IdempotencyScope != IdempotencyScopeEvent {
+		_, err := db.ExecContext(ctx, `
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_tickets_idempotency ON tickets(idempotency_key);
+			DROP INDEX IF EXISTS idx_tickets_idempotency_event;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to apply global idempotency scope: %w", err)
+		}
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_tickets_idempotency_event ON tickets(event_id, idempotency_key);
+		DROP INDEX IF EXISTS idx_tickets_idempotency;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to apply per-event idempotency scope: %w", err)
+	}
+	return nil
 }
 
-// Event represents an event record
+// Event statuses
+const (
+	EventStatusDraft     = "draft"
+	EventStatusPublished = "published"
+	EventStatusCancelled = "cancelled"
+)
+
+// Event represents an event record. Optional attributes are omitted from JSON when unset,
+// so a plain event stays a short payload; id, name, capacity, status and currency are
+// always present.
 type Event struct {
-	ID             int64  `json:"id"`
-	Name           string `json:"name"`
-	TotalSpots     int    `json:"total_spots"`
-	AvailableSpots int    `json:"available_spots"`
+	ID             int64      `json:"id"`
+	Name           string     `json:"name"`
+	TotalSpots     int        `json:"total_spots"`
+	AvailableSpots int        `json:"available_spots"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	Status         string     `json:"status"`
+	OrganizerEmail string     `json:"organizer_email,omitempty"`
+	AutoConfirm    bool       `json:"auto_confirm"`
+	// RegistrationOpensAt and RegistrationClosesAt bound when registration is accepted;
+	// nil leaves that side of the window open.
+	RegistrationOpensAt  *time.Time `json:"registration_opens_at,omitempty"`
+	RegistrationClosesAt *time.Time `json:"registration_closes_at,omitempty"`
+	// HoldSeconds overrides the server-wide reservation hold for this event; nil uses the default.
+	HoldSeconds *int      `json:"hold_seconds,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	// PriceCents is the price of one spot in minor currency units; 0 means free.
+	PriceCents int `json:"price_cents,omitempty"`
+	// Currency is the ISO 4217 code price_cents is denominated in.
+	Currency string `json:"currency"`
+	// Tags are lowercase category labels, sorted; omitted when the event has none.
+	Tags []string `json:"tags,omitempty"`
+	// ExternalID is the organizer's own identifier for the event, unique per organizer.
+	ExternalID string `json:"external_id,omitempty"`
+	// MaxWaitlist overrides the server-wide waitlist cap for this event; nil uses the default.
+	MaxWaitlist *int `json:"max_waitlist,omitempty"`
 }
 
-// CreateEvent creates a new event
-func (db *DB) CreateEvent(ctx context.Context, name string, totalSpots int) (*Event, error) {
-	query := `INSERT INTO events (name, total_spots, available_spots) VALUES (?, ?, ?)`
-	res, err := db.ExecContext(ctx, query, name, totalSpots, totalSpots)
-	if err != nil {
+// eventColumns is the column list scanned by scanEvent, shared by every event query.
+const eventColumns = `id, name, total_spots, available_spots, starts_at, status, organizer_email, auto_confirm, registration_opens_at, registration_closes_at, hold_seconds, created_at, price_cents, currency, external_id, max_waitlist,
+	(SELECT group_concat(tag, ',') FROM event_tags WHERE event_tags.event_id = events.id)`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEvent(row rowScanner) (*Event, error) {
+	var e Event
+	var startsAt, opensAt, closesAt sql.NullTime
+	var organizer sql.NullString
+	var holdSeconds, maxWaitlist sql.NullInt64
+	var createdAt sqliteTime
+	var tags, externalID sql.NullString
+	if err := row.Scan(&e.ID, &e.Name, &e.TotalSpots, &e.AvailableSpots, &startsAt, &e.Status, &organizer, &e.AutoConfirm, &opensAt, &closesAt, &holdSeconds, &createdAt, &e.PriceCents, &e.Currency, &externalID, &maxWaitlist, &tags); err != nil {
 		return nil, err
 	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return nil, err
+	e.ExternalID = externalID.String
+	e.Tags = []string{}
+	if tags.String != "" {
+		e.Tags = strings.Split(tags.String, ",")
+		slices.Sort(e.Tags)
 	}
-	return &Event{
-		ID:             id,
-		Name:           name,
-		TotalSpots:     totalSpots,
-		AvailableSpots: totalSpots,
-	}, nil
+	e.CreatedAt = createdAt.Time
+	if holdSeconds.Valid {
+		seconds := int(holdSeconds.Int64)
+		e.HoldSeconds = &seconds
+	}
+	if maxWaitlist.Valid {
+		limit := int(maxWaitlist.Int64)
+		e.MaxWaitlist = &limit
+	}
+	e.OrganizerEmail = organizer.String
+	e.StartsAt = utcTimePtr(startsAt)
+	e.RegistrationOpensAt = utcTimePtr(opensAt)
+	e.RegistrationClosesAt = utcTimePtr(closesAt)
+	return &e, nil
 }
 
-// ListEvents lists all events
-func (db *DB) ListEvents(ctx context.Context) ([]Event, error) {
-	query := `SELECT id, name, total_spots, available_spots FROM events`
-	rows, err := db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
+// utcTimePtr turns a nullable column into an optional UTC time.
+func utcTimePtr(nt sql.NullTime) *time.Time {
+	if !nt.Valid {
+		return nil
 	}
-	defer rows.Close()
+	t := nt.Time.UTC()
+	return &t
+}
 
-	var events []Event
-	for rows.Next() {
-		var e Event
-		if err := rows.Scan(&e.ID, &e.Name, &e.TotalSpots, &e.AvailableSpots); err != nil {
-			return nil, err
-		}
-		events = append(events, e)
+// nullableTime converts an optional time into a value SQLite stores in datetime() layout.
+func nullableTime(t *time.Time) any {
+	if t == nil {
+		return nil
 	}
-	return events, rows.Err()
+	return t.UTC().Format(sqliteTimeFormat)
 }
 
-var ErrSoldOut = errors.New("event is sold out")
-var ErrAlreadyRegistered = errors.New("user already registered for this event or request already processed")
+// storedTime mirrors what nullableTime persists: UTC at whole-second precision.
+func storedTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	st := t.UTC().Truncate(time.Second)
+	return &st
+}
 
-// RegisterForEvent uses an atomic conditional update inside a transaction to prevent overbooking
-func (db *DB) RegisterForEvent(ctx context.Context, eventID int64, email string, idempotencyKey string) (int64, error) {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, fmt.Errorf("failed to begin tx: %w", err)
+// nullableString stores empty strings as NULL
+func nullableString(s string) any {
+	if s == "" {
+		return nil
 	}
-	defer tx.Rollback() // Safe to call even if committed
+	return s
+}
 
-	// 1. Optimistic Concurrent Update (The Atomic Edge)
-	res, err := tx.ExecContext(ctx, `
-		UPDATE events 
-		SET available_spots = available_spots - 1 
-		WHERE id = ? AND available_spots > 0
-	`, eventID)
+// nullableID stores a zero id as NULL.
+func nullableID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
 
-	if err != nil {
-		return 0, fmt.Errorf("failed to update event capacity: %w", err)
+// MaxTotalSpots is the hard ceiling on an event's capacity. It keeps every spot count,
+// and any sum of them, far away from integer overflow.
+const MaxTotalSpots = 1_000_000
+
+// MaxHoldSeconds is the longest hold_seconds an event may set, 30 days. Anything longer
+// is no longer a hold, and unbounded values overflow time.Duration.
+const MaxHoldSeconds = 30 * 24 * 60 * 60
+
+var ErrCapacityTooLarge = errors.New("total_spots must not exceed " + strconv.Itoa(MaxTotalSpots))
+
+// CreateEvent creates a new event. Name, TotalSpots and the optional StartsAt, Status,
+// OrganizerEmail and AutoConfirm are taken from e; the returned event carries the generated ID.
+// Passing seatLabels makes it a reserved-seating event; TotalSpots must then equal len(seatLabels).
+func (db *DB) CreateEvent(ctx context.Context, e Event, seatLabels ...string) (*Event, error) {
+	if len(seatLabels) > 0 && len(seatLabels) != e.TotalSpots {
+		return nil, fmt.Errorf("event has %d seats but total_spots is %d", len(seatLabels), e.TotalSpots)
 	}
 
-	rowsAffected, err := res.RowsAffected()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
-		return 0, ErrSoldOut
+	if err := db.insertEvent(ctx, tx, &e, seatLabels); err != nil {
+		return nil, err
 	}
 
-	// 2. Insert Ticket with 5-minute expiry
-	// Use SQLite specific datetime modification
-	res, err = tx.ExecContext(ctx, `
-		INSERT INTO tickets (event_id, user_email, idempotency_key, status, expires_at) 
-		VALUES (?, ?, ?, 'reserved', datetime('now', '+5 minutes'))
-	`, eventID, email, idempotencyKey)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return &e, nil
+}
 
+// CreateEvents inserts events all-or-nothing: if any insert fails, none are kept.
+func (db *DB) CreateEvents(ctx context.Context, events []Event) ([]Event, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		// Could be a UNIQUE constraint violation (double booking or duplicate idempotency key)
-		return 0, fmt.Errorf("%w: %v", ErrAlreadyRegistered, err)
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
 	}
+	defer tx.Rollback()
 
-	ticketID, err := res.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("failed getting ticket id: %w", err)
+	created := make([]Event, len(events))
+	for i, e := range events {
+		if err := db.insertEvent(ctx, tx, &e, nil); err != nil {
+			return nil, fmt.Errorf("event %d (%q): %w", i+1, e.Name, err)
+		}
+		created[i] = e
 	}
 
-	// 3. Commit Transaction
 	if err := tx.Commit(); err != nil {
-		return 0, fmt.Errorf("failed to commit tx: %w", err)
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
 	}
-
-	return ticketID, nil
+	return created, nil
 }
 
-// ConfirmReservation finalizes the ticket.
-func (db *DB) ConfirmReservation(ctx context.Context, ticketID int64, userEmail string) error {
-	// Only allow confirming if status is 'reserved' and it hasn't expired
-	res, err := db.ExecContext(ctx, `
-		UPDATE tickets 
-		SET status = 'confirmed' 
-		WHERE id = ? AND user_email = ? AND status = 'reserved' AND expires_at > datetime('now')
-	`, ticketID, userEmail)
+// insertEvent writes e and its optional seat map inside the caller's transaction, filling in
+// the generated ID and the defaults SQLite applied.
+func (db *DB) insertEvent(ctx context.Context, tx *sql.Tx, e *Event, seatLabels []string) error {
+	if e.TotalSpots > MaxTotalSpots {
+		return ErrCapacityTooLarge
+	}
+	if e.Status == "" {
+		e.Status = EventStatusPublished
+	}
+	if e.Currency == "" {
+		e.Currency = defaultCurrency
+	}
 
+	createdAt := db.Clock.Now().UTC().Truncate(time.Second)
+	query := `INSERT INTO events (name, total_spots, available_spots, starts_at, status, organizer_email, auto_confirm, registration_opens_at, registration_closes_at, hold_seconds, created_at, price_cents, currency, external_id, max_waitlist) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := tx.ExecContext(ctx, query, e.Name, e.TotalSpots, e.TotalSpots, nullableTime(e.StartsAt), e.Status, nullableString(e.OrganizerEmail), e.AutoConfirm,
+		nullableTime(e.RegistrationOpensAt), nullableTime(e.RegistrationClosesAt), e.HoldSeconds, sqliteTimestamp(createdAt), e.PriceCents, e.Currency, nullableString(e.ExternalID), e.MaxWaitlist)
 	if err != nil {
-		return fmt.Errorf("failed to confirm ticket: %w", err)
+		if uniqueViolationOn(err, "idx_events_external_id") {
+			return fmt.Errorf("%w: %v", ErrDuplicateExternalID, err)
+		}
+		return err
 	}
-
-	rows, err := res.RowsAffected()
+	id, err := res.LastInsertId()
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
-		return errors.New("ticket is expired, already confirmed, or does not exist")
+
+	for _, label := range seatLabels {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO seats (event_id, seat_label) VALUES (?, ?)`, id, label); err != nil {
+			return fmt.Errorf("failed to add seat %q: %w", label, err)
+		}
+	}
+	for _, tag := range e.Tags {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO event_tags (event_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+			return fmt.Errorf("failed to add tag %q: %w", tag, err)
+		}
+	}
+	if e.Tags == nil {
+		e.Tags = []string{}
 	}
 
+	e.ID = id
+	e.AvailableSpots = e.TotalSpots
+	e.CreatedAt = createdAt
+	e.StartsAt = storedTime(e.StartsAt)
+	e.RegistrationOpensAt = storedTime(e.RegistrationOpensAt)
+	e.RegistrationClosesAt = storedTime(e.RegistrationClosesAt)
 	return nil
 }
 
-// ReclaimExpiredSeats acts as the background worker reclaiming spots
-func (db *DB) ReclaimExpiredSeats(ctx context.Context) (int64, error) {
-	tx, err := db.BeginTx(ctx, nil)
+// GetEvent fetches a single event by id
+func (db *DB) GetEvent(ctx context.Context, id int64) (*Event, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM events WHERE id = ?`, id)
+	e, err := scanEvent(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer tx.Rollback()
+	return e, nil
+}
+
+// Orderings accepted by EventFilter.Sort.
+const (
+	EventSortID        = "id"
+	EventSortCreatedAt = "created_at"
+)
 
-	// 1. Find expired but still reserved tickets
-	// SQLite syntax to update status to cancelled and return event_ids for atomic replenishment
-	// We do this via two steps in SQLite because it lacks UPDATE ... RETURNING out of the box until newer versions.
+// EventFilter narrows ListEvents. Zero values mean "no restriction"; a Limit of 0
+// returns every matching row.
+type EventFilter struct {
+	OrganizerEmail string
+	Limit          int
+	Offset         int
+	// Sort is EventSortID (the default) or EventSortCreatedAt, oldest first either way.
+	Sort string
+	// Tag, when set, keeps only events carrying this (normalised) tag.
+	Tag string
+	// AfterID is a keyset cursor: only events sorting after the event with this id are
+	// listed. Unlike Offset, pages stay stable while events are added between fetches.
+	AfterID int64
+	// From and To, when non-zero, keep only events starting within them, inclusive.
+	// Events without a start time never match a range.
+	From time.Time
+	To   time.Time
+	// IncludeDrafts lists draft events too. Public listings leave it unset, so drafts stay
+	// hidden from everyone but their organizer and admins.
+	IncludeDrafts bool
+	// ExcludeCancelled leaves out cancelled events.
+	ExcludeCancelled bool
+}
 
-	rows, err := tx.QueryContext(ctx, `SELECT id, event_id FROM tickets WHERE status = 'reserved' AND expires_at <= datetime('now')`)
-	if err != nil {
-		return 0, err
+// where returns the WHERE clause selecting the events f matches, and its arguments. The
+// AfterID cursor is applied only when keyset is set, so counts cover the whole listing.
+func (f EventFilter) where(keyset bool) (string, []any) {
+	var conds []string
+	var args []any
+	if !f.IncludeDrafts {
+		conds = append(conds, `status != ?`)
+		args = append(args, EventStatusDraft)
+	}
+	if f.ExcludeCancelled {
+		conds = append(conds, `status != ?`)
+		args = append(args, EventStatusCancelled)
+	}
+	if f.OrganizerEmail != "" {
+		conds = append(conds, `organizer_email = ?`)
+		args = append(args, f.OrganizerEmail)
+	}
+	if f.Tag != "" {
+		conds = append(conds, `id IN (SELECT event_id FROM event_tags WHERE tag = ?)`)
+		args = append(args, f.Tag)
+	}
+	switch {
+	case !f.From.IsZero() && !f.To.IsZero():
+		conds = append(conds, `starts_at BETWEEN ? AND ?`)
+		args = append(args, sqliteTimestamp(f.From), sqliteTimestamp(f.To))
+	case !f.From.IsZero():
+		conds = append(conds, `starts_at >= ?`)
+		args = append(args, sqliteTimestamp(f.From))
+	case !f.To.IsZero():
+		conds = append(conds, `starts_at <= ?`)
+		args = append(args, sqliteTimestamp(f.To))
+	}
+	if keyset && f.AfterID > 0 {
+		if f.Sort == EventSortCreatedAt {
+			conds = append(conds, `(created_at, id) > (SELECT created_at, id FROM events WHERE id = ?)`)
+		} else {
+			conds = append(conds, `id > ?`)
+		}
+		args = append(args, f.AfterID)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(conds, ` AND `), args
+}
+
+// ListEvents lists events matching f, ordered by f.Sort
+func (db *DB) ListEvents(ctx context.Context, f EventFilter) ([]Event, error) {
+	var events []Event
+	err := db.EachEvent(ctx, f, func(e *Event) error {
+		events = append(events, *e)
+		return nil
+	})
+	return events, err
+}
+
+// GetEventsByIDs loads the events with the given ids in one query, returned in the order
+// requested. Ids with no event, and drafts, are left out and repeated ids appear once.
+func (db *DB) GetEventsByIDs(ctx context.Context, ids []int64) ([]Event, error) {
+	events := []Event{}
+	if len(ids) == 0 {
+		return events, nil
 	}
 
-	type reclaimed struct {
-		ticketID int64
-		eventID  int64
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
 	}
-	var expired []reclaimed
+	rows, err := db.QueryContext(ctx, `SELECT `+eventColumns+` FROM events WHERE id IN (`+placeholders+`) AND status != ?`, append(args, EventStatusDraft)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*Event, len(ids))
 	for rows.Next() {
-		var r reclaimed
-		if err := rows.Scan(&r.ticketID, &r.eventID); err == nil {
-			expired = append(expired, r)
+		evt, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
 		}
+		byID[evt.ID] = evt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
 	}
-	rows.Close()
 
-	if len(expired) == 0 {
-		return 0, tx.Commit()
+	for _, id := range ids {
+		if evt, ok := byID[id]; ok {
+			events = append(events, *evt)
+			delete(byID, id)
+		}
 	}
+	return events, nil
+}
 
-	// 2. Mark as Cancelled and Return spot to events table
-	var reclaimedCount int64
-	for _, e := range expired {
-		_, err := tx.ExecContext(ctx, `UPDATE tickets SET status = 'cancelled' WHERE id = ?`, e.ticketID)
+// EachEvent calls fn for every event matching f in f.Sort order, one row at a time, so large
+// listings never have to sit in memory. An error from fn stops the scan and is returned.
+// The rows hold the only connection until the scan ends, so fn should not linger.
+func (db *DB) EachEvent(ctx context.Context, f EventFilter, fn func(*Event) error) error {
+	where, args := f.where(true)
+	query := `SELECT ` + eventColumns + ` FROM events` + where
+	if f.Sort == EventSortCreatedAt {
+		query += ` ORDER BY created_at, id`
+	} else {
+		query += ` ORDER BY id`
+	}
+	if f.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEvent(rows)
 		if err != nil {
-			continue
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
 		}
+	}
+	return rows.Err()
+}
+
+// CountEvents counts the events matching f, ignoring its Limit and Offset.
+func (db *DB) CountEvents(ctx context.Context, f EventFilter) (int, error) {
+	where, args := f.where(false)
+	query := `SELECT COUNT(*) FROM events` + where
+
+	var total int
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count events: %w", err)
+	}
+	return total, nil
+}
+
+// FindEventByName returns organizer's live (not cancelled) event called name, compared
+// case-insensitively after trimming surrounding whitespace.
+func (db *DB) FindEventByName(ctx context.Context, organizer, name string) (*Event, error) {
+	evt, err := scanEvent(db.QueryRowContext(ctx, `
+		SELECT `+eventColumns+` FROM events
+		WHERE organizer_email = ? AND lower(trim(name)) = lower(?) AND status != ?
+		ORDER BY id LIMIT 1
+	`, organizer, strings.TrimSpace(name), EventStatusCancelled))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up event by name: %w", err)
+	}
+	return evt, nil
+}
+
+var ErrEventNotFound = errors.New("event not found")
+var ErrDuplicateExternalID = errors.New("organizer already has an event with this external_id")
+var ErrSoldOut = errors.New("event is sold out")
+var ErrAlreadyRegistered = errors.New("user already registered for this event or request already processed")
+var ErrRegistrationNotOpen = errors.New("registration is not open for this event")
+var ErrEventDraft = errors.New("event is still a draft; publish it before taking registrations")
+var ErrInvalidQuantity = errors.New("quantity must be at least 1")
+
+// FindEventByExternalID returns organizer's event carrying externalID.
+func (db *DB) FindEventByExternalID(ctx context.Context, organizer, externalID string) (*Event, error) {
+	evt, err := scanEvent(db.QueryRowContext(ctx, `
+		SELECT `+eventColumns+` FROM events
+		WHERE COALESCE(organizer_email, '') = ? AND external_id = ?
+	`, organizer, externalID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up event by external id: %w", err)
+	}
+	return evt, nil
+}
+
+// SuggestAlternatives returns up to limit other published events that are open for
+// registration, have not started and still have spots, for someone who just missed
+// eventID. Events sharing a tag with it come first, then those starting closest to it.
+func (db *DB) SuggestAlternatives(ctx context.Context, eventID int64, limit int) ([]Event, error) {
+	now := sqliteTimestamp(db.Clock.Now())
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+eventColumns+` FROM events
+		WHERE id != ? AND status = ? AND available_spots > 0
+		AND (starts_at IS NULL OR starts_at > ?)
+		AND (registration_opens_at IS NULL OR registration_opens_at <= ?)
+		AND (registration_closes_at IS NULL OR registration_closes_at > ?)
+		ORDER BY
+			EXISTS (SELECT 1 FROM event_tags mine JOIN event_tags theirs ON theirs.tag = mine.tag
+				WHERE mine.event_id = ? AND theirs.event_id = events.id) DESC,
+			abs(julianday(starts_at) - julianday((SELECT starts_at FROM events WHERE id = ?))) ASC NULLS LAST,
+			id
+		LIMIT ?
+	`, eventID, EventStatusPublished, now, now, now, eventID, eventID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alternatives: %w", err)
+	}
+	defer rows.Close()
 
-		_, err = tx.ExecContext(ctx, `UPDATE events SET available_spots = available_spots + 1 WHERE id = ?`, e.eventID)
+	events := []Event{}
+	for rows.Next() {
+		evt, err := scanEvent(rows)
 		if err != nil {
-			continue // In reality we'd log this critical error
+			return nil, err
 		}
-		reclaimedCount++
+		events = append(events, *evt)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read alternatives: %w", err)
+	}
+	return events, nil
+}
 
-	return reclaimedCount, tx.Commit()
+// Ticket represents a ticket record. Timestamps are always UTC.
+type Ticket struct {
+	ID        int64     `json:"id"`
+	EventID   int64     `json:"event_id"`
+	UserEmail string    `json:"user_email"`
+	Status    string    `json:"status"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	// AmountDue is quantity times the event's price_cents when the ticket was issued.
+	AmountDue int `json:"amount_due"`
+	// Currency is the event's currency when the ticket was issued.
+	Currency string `json:"currency"`
+	// ConfirmationCode is left out of Ticket's JSON. It reaches the attendee by email and
+	// in the response to the registration that created the ticket, and nowhere else.
+	ConfirmationCode string    `json:"-"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	// OrderID is set when the ticket was reserved as part of a group order.
+	OrderID *int64 `json:"order_id,omitempty"`
+	// AwaitingPayment is true while a reservation's hold is extended for an in-flight payment.
+	AwaitingPayment bool `json:"awaiting_payment,omitempty"`
+	// Metadata is the attendee supplied JSON object, such as dietary or accessibility needs.
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+var ErrTicketNotFound = errors.New("ticket not found")
+
+// GetTicket fetches a single ticket by id
+func (db *DB) GetTicket(ctx context.Context, ticketID int64) (*Ticket, error) {
+	return scanTicket(db.QueryRowContext(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE id = ?`, ticketID))
+}
+
+// FindTicketByIdempotencyKey returns the ticket registered at eventID under key, the
+// original result of a request that is now being retried.
+func (db *DB) FindTicketByIdempotencyKey(ctx context.Context, eventID int64, key string) (*Ticket, error) {
+	return scanTicket(db.QueryRowContext(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE event_id = ? AND idempotency_key = ?`, eventID, key))
+}
+
+// FindActiveTicket returns email's reserved or confirmed ticket for eventID.
+func (db *DB) FindActiveTicket(ctx context.Context, eventID int64, email string) (*Ticket, error) {
+	return scanTicket(db.QueryRowContext(ctx, `
+		SELECT `+ticketColumns+` FROM tickets
+		WHERE event_id = ? AND user_email = ? AND status IN ('reserved', 'confirmed')
+	`, eventID, email))
+}
+
+// ticketColumns is the column list scanned by scanTicket.
+const ticketColumns = `id, event_id, user_email, status, quantity, amount_due, currency, confirmation_code, created_at, expires_at, order_id, payment_pending_at, metadata`
+
+func scanTicket(row rowScanner) (*Ticket, error) {
+	var t Ticket
+	var email, code sql.NullString
+	var createdAt, expiresAt sqliteTime
+	var orderID sql.NullInt64
+	var paymentPendingAt, metadata sql.NullString
+	err := row.Scan(&t.ID, &t.EventID, &email, &t.Status, &t.Quantity, &t.AmountDue, &t.Currency, &code, &createdAt, &expiresAt, &orderID, &paymentPendingAt, &metadata)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ticket: %w", err)
+	}
+	t.UserEmail = email.String
+	t.ConfirmationCode = code.String
+	t.CreatedAt = createdAt.Time
+	t.ExpiresAt = expiresAt.Time
+	if orderID.Valid {
+		t.OrderID = &orderID.Int64
+	}
+	t.AwaitingPayment = paymentPendingAt.Valid && t.Status == "reserved"
+	if metadata.Valid {
+		t.Metadata = json.RawMessage(metadata.String)
+	}
+	return &t, nil
+}
+
+// newConfirmationCode returns a short code attendees can read out at the door. The
+// alphabet has 32 symbols, so mapping random bytes onto it is unbiased, and it leaves out
+// look-alikes such as 0/O and 1/I.
+func newConfirmationCode() string {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	b := make([]byte, 8)
+	rand.Read(b)
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b)
+}
+
+// ExtendHold slides a live reservation's expiry to at least now+extension, but never past
+// created_at+maxHold and never earlier than it already is. Tickets that are not reserved,
+// or whose hold has already lapsed, are left untouched. It returns the resulting expiry.
+func (db *DB) ExtendHold(ctx context.Context, ticketID int64, extension, maxHold time.Duration) (time.Time, error) {
+	now := db.Clock.Now()
+	_, err := db.ExecContext(ctx, `
+		UPDATE tickets
+		SET expires_at = MAX(expires_at, MIN(
+			datetime(created_at, printf('+%d seconds', ?)),
+			?
+		))
+		WHERE id = ? AND status = 'reserved' AND expires_at > ?
+	`, int64(maxHold.Seconds()), sqliteTimestamp(now.Add(extension)), ticketID, sqliteTimestamp(now))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to extend hold: %w", err)
+	}
+
+	var expiresAt sqliteTime
+	if err := db.QueryRowContext(ctx, `SELECT expires_at FROM tickets WHERE id = ?`, ticketID).Scan(&expiresAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read expiry: %w", err)
+	}
+	return expiresAt.Time, nil
+}
+
+// RotateConfirmNonce replaces a live reservation's confirm nonce with nonce, so the old
+// one stops working. It reports false, changing nothing, when the ticket is no longer
+// reserved or its hold has lapsed.
+func (db *DB) RotateConfirmNonce(ctx context.Context, ticketID int64, nonce string) (bool, error) {
+	res, err := db.ExecContext(ctx, `
+		UPDATE tickets SET confirm_nonce = ?
+		WHERE id = ? AND status = 'reserved' AND expires_at > ?
+	`, nonce, ticketID, sqliteTimestamp(db.Clock.Now()))
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate confirm nonce: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// RegisterParams describes a single registration attempt.
+type RegisterParams struct {
+	EventID        int64
+	Email          string
+	IdempotencyKey string
+	// SeatLabel requests a specific seat at a reserved-seating event. When empty, such
+	// events assign the first available seat.
+	SeatLabel string
+	// Quantity is the number of spots wanted on one ticket; 0 means 1 and a negative
+	// value is rejected with ErrInvalidQuantity.
+	Quantity int
+	// ClaimToken registers a guest: Email must then be empty, and the token is what later
+	// proves ownership of the ticket.
+	ClaimToken string
+	// Partial switches from all-or-nothing to best effort: reserve as many of Quantity
+	// as are still available rather than failing with ErrSoldOut.
+	Partial bool
+	// OrderID files the ticket under a group order; 0 for a standalone registration.
+	OrderID int64
+	// Metadata is stored on the ticket as given; callers validate it.
+	Metadata json.RawMessage
+	// ConfirmNonce, when set, must be presented once to confirm a reservation. Auto-confirm
+	// events issue confirmed tickets, which never store it.
+	ConfirmNonce string
+}
+
+// RegisterForEvent uses an atomic conditional update inside a transaction to prevent overbooking.
+// It returns the new ticket as stored; its Quantity is less than the requested quantity only
+// in partial mode.
+func (db *DB) RegisterForEvent(ctx context.Context, p RegisterParams) (*Ticket, error) {
+	if p.Quantity < 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback() // Safe to call even if committed
+
+	ticket, remaining, err := db.register(ctx, tx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	if remaining == 0 && db.OnSoldOut != nil {
+		db.OnSoldOut(ctx, p.EventID)
+	}
+
+	return ticket, nil
+}
+
+// register reserves p's spots and inserts its ticket inside tx, returning the ticket and
+// the spots the event has left. The caller commits.
+func (db *DB) register(ctx context.Context, tx *sql.Tx, p RegisterParams) (*Ticket, int, error) {
+	want := p.Quantity
+	if want == 0 {
+		want = 1
+	}
+
+	// Drafts and registration windows are checked before any capacity is touched
+	var status string
+	var opensAt, closesAt sql.NullTime
+	err := tx.QueryRowContext(ctx, `SELECT status, registration_opens_at, registration_closes_at FROM events WHERE id = ?`, p.EventID).Scan(&status, &opensAt, &closesAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read registration window: %w", err)
+	}
+	if status == EventStatusDraft {
+		return nil, 0, ErrEventDraft
+	}
+	now := db.Clock.Now()
+	if (opensAt.Valid && now.Before(opensAt.Time)) || (closesAt.Valid && !now.Before(closesAt.Time)) {
+		return nil, 0, ErrRegistrationNotOpen
+	}
+
+	// Best effort sizes the request to what is left; the guarded update below still
+	// decides who actually gets the spots
+	if p.Partial {
+		var available int
+		err := tx.QueryRowContext(ctx, `SELECT available_spots FROM events WHERE id = ?`, p.EventID).Scan(&available)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, ErrEventNotFound
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read availability: %w", err)
+		}
+		if available == 0 {
+			return nil, 0, ErrSoldOut
+		}
+		want = min(want, available)
+	}
+
+	// 1. Optimistic Concurrent Update (The Atomic Edge). RETURNING gives the count this
+	// update left behind, so exactly one registration sees the event reach zero.
+	var remaining int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE events 
+		SET available_spots = available_spots - ? 
+		WHERE id = ? AND ? > 0 AND available_spots >= ?
+		RETURNING available_spots
+	`, want, p.EventID, want, want).Scan(&remaining)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		switch classifySQLiteError(err) {
+		case ErrorKindBusy:
+			return nil, 0, fmt.Errorf("%w: %v", ErrDatabaseBusy, err)
+		case ErrorKindCheck:
+			// The guard above should make this impossible, so something else drove
+			// available_spots below zero; refuse the seat rather than fail opaquely
+			slog.ErrorContext(ctx, "available_spots check violated while registering", "event_id", p.EventID, "quantity", want, "error", err)
+			return nil, 0, ErrSoldOut
+		}
+		return nil, 0, fmt.Errorf("failed to update event capacity: %w", err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		// Zero rows means either the event is full or there is no such event; tell them apart
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM events WHERE id = ?)`, p.EventID).Scan(&exists); err != nil {
+			return nil, 0, fmt.Errorf("failed to check event existence: %w", err)
+		}
+		if !exists {
+			return nil, 0, ErrEventNotFound
+		}
+		return nil, 0, ErrSoldOut
+	}
+
+	// 2. Insert Ticket: a timed hold, or straight to confirmed for auto-confirm events
+	var autoConfirm bool
+	var holdSeconds sql.NullInt64
+	var priceCents int
+	var currency string
+	if err := tx.QueryRowContext(ctx, `SELECT auto_confirm, hold_seconds, price_cents, currency FROM events WHERE id = ?`, p.EventID).Scan(&autoConfirm, &holdSeconds, &priceCents, &currency); err != nil {
+		return nil, 0, fmt.Errorf("failed to read event settings: %w", err)
+	}
+
+	var res sql.Result
+	if autoConfirm {
+		// Confirmed tickets are never reclaimed; the far-future expiry just satisfies NOT NULL
+		res, err = tx.ExecContext(ctx, `
+			INSERT INTO tickets (event_id, user_email, claim_token, idempotency_key, status, quantity, amount_due, currency, confirmation_code, created_at, expires_at, order_id, metadata)
+			VALUES (?, ?, ?, ?, 'confirmed', ?, ?, ?, ?, ?, ?, ?, ?)
+		`, p.EventID, nullableString(p.Email), nullableString(p.ClaimToken), p.IdempotencyKey, want, want*priceCents, currency, newConfirmationCode(), sqliteTimestamp(now), noExpiry, nullableID(p.OrderID), nullableString(string(p.Metadata)))
+	} else {
+		res, err = tx.ExecContext(ctx, `
+			INSERT INTO tickets (event_id, user_email, claim_token, idempotency_key, status, quantity, amount_due, currency, confirmation_code, created_at, expires_at, order_id, metadata, confirm_nonce) 
+			VALUES (?, ?, ?, ?, 'reserved', ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, p.EventID, nullableString(p.Email), nullableString(p.ClaimToken), p.IdempotencyKey, want, want*priceCents, currency, newConfirmationCode(), sqliteTimestamp(now), sqliteTimestamp(now.Add(db.holdFor(holdSeconds))), nullableID(p.OrderID), nullableString(string(p.Metadata)), nullableString(p.ConfirmNonce))
+	}
+
+	if err != nil {
+		// A UNIQUE violation is a double booking or a replayed idempotency key
+		if classifySQLiteError(err) == ErrorKindUnique {
+			return nil, 0, fmt.Errorf("%w: %v", ErrAlreadyRegistered, err)
+		}
+		return nil, 0, fmt.Errorf("failed to insert ticket: %w", err)
+	}
+
+	ticketID, err := res.LastInsertId()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed getting ticket id: %w", err)
+	}
+
+	// 3. Reserved-seating events pin the ticket to one seat per spot in the same transaction;
+	// a requested label is used for the first of them
+	for i := 0; i < want; i++ {
+		label := ""
+		if i == 0 {
+			label = p.SeatLabel
+		}
+		if err := assignSeat(ctx, tx, p.EventID, ticketID, label); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	ticket, err := scanTicket(tx.QueryRowContext(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE id = ?`, ticketID))
+	if err != nil {
+		return nil, 0, err
+	}
+	return ticket, remaining, nil
+}
+
+var ErrTicketExpired = errors.New("ticket reservation has expired")
+var ErrAlreadyConfirmed = errors.New("ticket is already confirmed")
+var ErrEventCancelled = errors.New("the event for this ticket has been cancelled")
+var ErrInvalidConfirmNonce = errors.New("confirm nonce is missing, wrong or already used")
+
+// ConfirmReservation finalizes the ticket. When the reservation cannot be confirmed it
+// reports why: ErrTicketNotFound (missing or owned by someone else), ErrAlreadyConfirmed,
+// ErrTicketExpired (the hold lapsed, whether or not the reclaimer has run yet),
+// ErrEventCancelled (the event was cancelled or removed after the reservation), or
+// ErrTicketNotActive (cancelled by its owner before the hold ran out).
+//
+// nonce must match the confirm nonce the ticket was issued with, or be empty when it has
+// none. A nonce is cleared by the confirmation that uses it, so presenting it again, or a
+// wrong one, fails with ErrInvalidConfirmNonce before any other check.
+func (db *DB) ConfirmReservation(ctx context.Context, ticketID int64, userEmail, nonce string) error {
+	return db.confirmReservation(ctx, ticketID, &ticketOwner{column: "user_email", value: userEmail}, &nonce)
+}
+
+// ConfirmGuestReservation is ConfirmReservation for guest tickets, proven by claim token.
+func (db *DB) ConfirmGuestReservation(ctx context.Context, ticketID int64, claimToken, nonce string) error {
+	return db.confirmReservation(ctx, ticketID, &ticketOwner{column: "claim_token", value: claimToken}, &nonce)
+}
+
+// ticketOwner is the proof of ownership a confirmation must match: the ticket's column,
+// user_email or claim_token, holding value.
+type ticketOwner struct {
+	column string
+	value  string
+}
+
+// confirmReservationTrusted confirms ticketID for a caller that has already established it
+// may, skipping the owner and nonce checks.
+func (db *DB) confirmReservationTrusted(ctx context.Context, ticketID int64) error {
+	return db.confirmReservation(ctx, ticketID, nil, nil)
+}
+
+// confirmReservation confirms ticketID if owner matches it and nonce matches its confirm
+// nonce. Only confirmReservationTrusted passes nil for both.
+func (db *DB) confirmReservation(ctx context.Context, ticketID int64, owner *ticketOwner, nonce *string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ownerCond, ownerArgs := "", []any{}
+	if owner != nil {
+		ownerCond, ownerArgs = ` AND `+owner.column+` = ?`, []any{owner.value}
+	}
+
+	// A NULL nonce argument matches any ticket, and a ticket without a nonce matches ''
+	var nonceArg any
+	if nonce != nil {
+		nonceArg = *nonce
+	}
+
+	// Only allow confirming if status is 'reserved', it hasn't expired and its event still stands
+	now := sqliteTimestamp(db.Clock.Now())
+	res, err := tx.ExecContext(ctx, `
+		UPDATE tickets 
+		SET status = 'confirmed', confirm_nonce = NULL
+		WHERE id = ?`+ownerCond+` AND status = 'reserved' AND expires_at > ?
+		AND (? IS NULL OR COALESCE(confirm_nonce, '') = ?)
+		AND EXISTS (SELECT 1 FROM events WHERE events.id = tickets.event_id AND events.status != ?)
+	`, append(append([]any{ticketID}, ownerArgs...), now, nonceArg, nonceArg, EventStatusCancelled)...)
+
+	if err != nil {
+		return fmt.Errorf("failed to confirm ticket: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 1 {
+		return tx.Commit()
+	}
+
+	// Nothing was confirmed; look at the ticket to explain why
+	var status string
+	var lapsed, eventGone, wrongNonce bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, expires_at <= ?,
+			NOT EXISTS (SELECT 1 FROM events WHERE events.id = tickets.event_id AND events.status != ?),
+			? IS NOT NULL AND COALESCE(confirm_nonce, '') != ?
+		FROM tickets WHERE id = ?`+ownerCond+`
+	`, append([]any{now, EventStatusCancelled, nonceArg, nonceArg, ticketID}, ownerArgs...)...).Scan(&status, &lapsed, &eventGone, &wrongNonce)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTicketNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load ticket: %w", err)
+	}
+
+	switch {
+	case wrongNonce:
+		return ErrInvalidConfirmNonce
+	case status == "confirmed":
+		return ErrAlreadyConfirmed
+	case status == "reserved" && eventGone:
+		return ErrEventCancelled
+	case lapsed:
+		return ErrTicketExpired
+	default:
+		return ErrTicketNotActive
+	}
+}
+
+// ReclaimExpiredSeats acts as the background worker reclaiming spots. Like a cancellation,
+// each lapsed hold goes straight to the head of the event's waitlist, so an offer a promoted
+// user let expire falls through to the next person in line instead of back to the pool.
+//
+// All work happens in one transaction bound to ctx: if ctx expires midway, everything
+// done so far is rolled back and the error is returned, so no ticket is ever cancelled
+// without its spots being returned.
+//
+// Like confirmReservation, each cancellation is conditioned on the ticket still being a
+// lapsed reservation, so when a confirmation and the reclaimer race for the same ticket
+// exactly one of the two UPDATEs matches and only a ticket actually cancelled here gives
+// its spots back.
+func (db *DB) ReclaimExpiredSeats(ctx context.Context) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 1. Find expired but still reserved tickets. Each is cancelled on its own below, since
+	// releasing seats and promoting the waitlist happen per ticket.
+	now := sqliteTimestamp(db.Clock.Now())
+	rows, err := tx.QueryContext(ctx, `SELECT id, event_id FROM tickets WHERE status = 'reserved' AND expires_at <= ?`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired tickets: %w", err)
+	}
+
+	type reclaimed struct {
+		ticketID int64
+		eventID  int64
+		quantity int
+	}
+	var expired []reclaimed
+	for rows.Next() {
+		var r reclaimed
+		if err := rows.Scan(&r.ticketID, &r.eventID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read expired ticket: %w", err)
+		}
+		expired = append(expired, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read expired tickets: %w", err)
+	}
+
+	if len(expired) == 0 {
+		return 0, tx.Commit()
+	}
+
+	// 2. Mark as Cancelled and Return spot to events table
+	var reclaimedCount int64
+	for _, e := range expired {
+		err := tx.QueryRowContext(ctx, `
+			UPDATE tickets SET status = 'cancelled', cancelled_at = ?
+			WHERE id = ? AND status = 'reserved' AND expires_at <= ?
+			RETURNING quantity
+		`, now, e.ticketID, now).Scan(&e.quantity)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Confirmed or cancelled since it was listed; it keeps its spots
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to cancel ticket %d: %w", e.ticketID, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE events SET available_spots = available_spots + ? WHERE id = ?`, e.quantity, e.eventID); err != nil {
+			return 0, fmt.Errorf("failed to return spots to event %d: %w", e.eventID, err)
+		}
+		if err := releaseSeat(ctx, tx, e.ticketID); err != nil {
+			return 0, err
+		}
+		promoted, err := db.promoteWaitlist(ctx, tx, e.eventID, e.quantity)
+		if err != nil {
+			return 0, fmt.Errorf("failed to promote waitlist for event %d: %w", e.eventID, err)
+		}
+		if len(promoted) > 0 {
+			slog.InfoContext(ctx, "promoted waitlisted users into lapsed holds", "event_id", e.eventID, "count", len(promoted))
+		}
+		reclaimedCount++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return reclaimedCount, nil
+}
+
+var ErrTicketNotActive = errors.New("ticket is already cancelled")
+
+// CancelTicket cancels a reserved or confirmed ticket owned by userEmail, returns its seat
+// to the event and immediately offers that seat to the head of the event's waitlist.
+func (db *DB) CancelTicket(ctx context.Context, ticketID int64, userEmail string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var eventID int64
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT event_id, status FROM tickets WHERE id = ? AND user_email = ?`, ticketID, userEmail).Scan(&eventID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTicketNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load ticket: %w", err)
+	}
+	if status == "cancelled" {
+		return ErrTicketNotActive
+	}
+
+	if err := db.cancelTicket(ctx, tx, ticketID, eventID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CancelEventRegistrations cancels every reserved or confirmed ticket for an event, frees
+// their seats and resets available_spots to total_spots, all in one transaction. It returns
+// how many tickets were cancelled. The waitlist is left as it is.
+func (db *DB) CancelEventRegistrations(ctx context.Context, eventID int64) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE events SET available_spots = total_spots WHERE id = ?`, eventID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset event capacity: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrEventNotFound
+	}
+
+	res, err = tx.ExecContext(ctx, `UPDATE tickets SET status = 'cancelled', cancelled_at = ? WHERE event_id = ? AND status IN ('reserved', 'confirmed')`, sqliteTimestamp(db.Clock.Now()), eventID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel tickets: %w", err)
+	}
+	cancelled, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE seats SET status = 'available', ticket_id = NULL WHERE event_id = ?`, eventID); err != nil {
+		return 0, fmt.Errorf("failed to release seats: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return cancelled, nil
+}
+
+// AdminCancelTicket cancels any ticket regardless of owner, returns its spot to the event
+// and records actor in the audit log. Cancelling an already-cancelled ticket is a no-op
+// that reports false.
+func (db *DB) AdminCancelTicket(ctx context.Context, ticketID int64, actor string) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var eventID int64
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT event_id, status FROM tickets WHERE id = ?`, ticketID).Scan(&eventID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrTicketNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load ticket: %w", err)
+	}
+	if status == "cancelled" {
+		return false, nil
+	}
+
+	if err := db.cancelTicket(ctx, tx, ticketID, eventID); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO audit_log (actor, action, ticket_id) VALUES (?, 'ticket.force_cancel', ?)`, actor, ticketID); err != nil {
+		return false, fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	return true, tx.Commit()
+}
+
+// AdminSetTicketExpiry moves a reserved ticket's hold expiry on behalf of support and
+// records actor in the audit log. The new expiry is expiresAt when set, otherwise extendBy
+// past the later of the current expiry and now, so a lapsed hold the reclaimer has not
+// reached yet can be revived. Confirmed tickets report ErrAlreadyConfirmed and cancelled
+// ones ErrTicketNotActive.
+func (db *DB) AdminSetTicketExpiry(ctx context.Context, ticketID int64, expiresAt time.Time, extendBy time.Duration, actor string) (*Ticket, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var current sqliteTime
+	err = tx.QueryRowContext(ctx, `SELECT status, expires_at FROM tickets WHERE id = ?`, ticketID).Scan(&status, &current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ticket: %w", err)
+	}
+	switch status {
+	case "confirmed":
+		return nil, ErrAlreadyConfirmed
+	case "cancelled":
+		return nil, ErrTicketNotActive
+	}
+
+	if expiresAt.IsZero() {
+		expiresAt = current.Time
+		if now := db.Clock.Now(); now.After(expiresAt) {
+			expiresAt = now
+		}
+		expiresAt = expiresAt.Add(extendBy)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE tickets SET expires_at = ? WHERE id = ?`, sqliteTimestamp(expiresAt), ticketID); err != nil {
+		return nil, fmt.Errorf("failed to update expiry: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO audit_log (actor, action, ticket_id) VALUES (?, 'ticket.set_expiry', ?)`, actor, ticketID); err != nil {
+		return nil, fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	ticket, err := scanTicket(tx.QueryRowContext(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE id = ?`, ticketID))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return ticket, nil
+}
+
+// cancelTicket marks an active ticket cancelled, returns its spot and seat, and hands the
+// spot to the head of the waitlist, all inside the caller's transaction.
+func (db *DB) cancelTicket(ctx context.Context, tx *sql.Tx, ticketID, eventID int64) error {
+	var quantity int
+	err := tx.QueryRowContext(ctx, `UPDATE tickets SET status = 'cancelled', cancelled_at = ? WHERE id = ? RETURNING quantity`, sqliteTimestamp(db.Clock.Now()), ticketID).Scan(&quantity)
+	if err != nil {
+		return fmt.Errorf("failed to cancel ticket: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE events SET available_spots = available_spots + ? WHERE id = ?`, quantity, eventID); err != nil {
+		return fmt.Errorf("failed to return seat: %w", err)
+	}
+	if err := releaseSeat(ctx, tx, ticketID); err != nil {
+		return err
+	}
+
+	_, err = db.promoteWaitlist(ctx, tx, eventID, quantity)
+	return err
+}
+
+var ErrAlreadyWaitlisted = errors.New("user is already on the waitlist for this event")
+var ErrSpotsAvailable = errors.New("event still has available spots, register instead")
+var ErrWaitlistFull = errors.New("waitlist for this event is full")
+
+// WaitlistFullError is ErrWaitlistFull with the size of the list that turned the caller away.
+type WaitlistFullError struct {
+	Length int
+	Max    int
+}
+
+func (e *WaitlistFullError) Error() string {
+	return fmt.Sprintf("%v (%d of %d)", ErrWaitlistFull, e.Length, e.Max)
+}
+
+func (e *WaitlistFullError) Unwrap() error { return ErrWaitlistFull }
+
+// JoinWaitlist queues userEmail for a sold-out event and returns their 1-based position.
+// A list already at the event's max_waitlist, or DB.MaxWaitlist, fails with a
+// *WaitlistFullError.
+func (db *DB) JoinWaitlist(ctx context.Context, eventID int64, userEmail string) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var available, length int
+	var maxWaitlist sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+		SELECT available_spots, max_waitlist, (SELECT COUNT(*) FROM waitlist WHERE event_id = events.id)
+		FROM events WHERE id = ? AND status != ?
+	`, eventID, EventStatusDraft).Scan(&available, &maxWaitlist, &length)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrEventNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load event: %w", err)
+	}
+	if available > 0 {
+		return 0, ErrSpotsAvailable
+	}
+	limit := db.MaxWaitlist
+	if maxWaitlist.Valid {
+		limit = int(maxWaitlist.Int64)
+	}
+	if limit > 0 && length >= limit {
+		return 0, &WaitlistFullError{Length: length, Max: limit}
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO waitlist (event_id, user_email) VALUES (?, ?)`, eventID, userEmail)
+	if err != nil {
+		if classifySQLiteError(err) == ErrorKindUnique {
+			return 0, fmt.Errorf("%w: %v", ErrAlreadyWaitlisted, err)
+		}
+		return 0, fmt.Errorf("failed to join waitlist: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	var position int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM waitlist WHERE event_id = ? AND id <= ?`, eventID, id).Scan(&position); err != nil {
+		return 0, fmt.Errorf("failed to compute waitlist position: %w", err)
+	}
+
+	return position, tx.Commit()
+}
+
+// PromoteWaitlist atomically moves up to n head-of-line waitlisted users into reserved
+// tickets, never taking more seats than the event has available. It returns the emails
+// that were promoted; everyone else stays queued in their original order.
+func (db *DB) PromoteWaitlist(ctx context.Context, eventID int64, n int) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	promoted, err := db.promoteWaitlist(ctx, tx, eventID, n)
+	if err != nil {
+		return nil, err
+	}
+	return promoted, tx.Commit()
+}
+
+// PromoteAllWaitlists fills free seats from the waitlist of every event that has both.
+// The reclaim worker runs it after returning expired reservations to the pool.
+func (db *DB) PromoteAllWaitlists(ctx context.Context) (map[int64][]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT e.id, e.available_spots
+		FROM events e JOIN waitlist w ON w.event_id = e.id
+		WHERE e.available_spots > 0
+	`)
+	if err != nil {
+		return nil, err
+	}
+	type candidate struct {
+		eventID   int64
+		available int
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.eventID, &c.available); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[int64][]string)
+	for _, c := range candidates {
+		promoted, err := db.PromoteWaitlist(ctx, c.eventID, c.available)
+		if err != nil {
+			return result, fmt.Errorf("failed to promote waitlist for event %d: %w", c.eventID, err)
+		}
+		if len(promoted) > 0 {
+			result[c.eventID] = promoted
+		}
+	}
+	return result, nil
+}
+
+// DefaultHold is the reservation hold for events without their own hold_seconds.
+func (db *DB) DefaultHold() time.Duration {
+	return db.holdFor(sql.NullInt64{})
+}
+
+// holdFor returns the reservation hold for an event whose hold_seconds column is holdSeconds.
+func (db *DB) holdFor(holdSeconds sql.NullInt64) time.Duration {
+	if holdSeconds.Valid {
+		return time.Duration(holdSeconds.Int64) * time.Second
+	}
+	if db.HoldDuration > 0 {
+		return db.HoldDuration
+	}
+	return defaultHoldDuration
+}
+
+// promoteWaitlist does the work of PromoteWaitlist inside the caller's transaction.
+func (db *DB) promoteWaitlist(ctx context.Context, tx *sql.Tx, eventID int64, n int) ([]string, error) {
+	now := db.Clock.Now()
+	var holdSeconds sql.NullInt64
+	var priceCents int
+	var currency string
+	if err := tx.QueryRowContext(ctx, `SELECT hold_seconds, price_cents, currency FROM events WHERE id = ?`, eventID).Scan(&holdSeconds, &priceCents, &currency); err != nil {
+		return nil, fmt.Errorf("failed to read event settings: %w", err)
+	}
+	hold := db.holdFor(holdSeconds)
+	var promoted []string
+	for len(promoted) < n {
+		var entryID int64
+		var email string
+		err := tx.QueryRowContext(ctx, `SELECT id, user_email FROM waitlist WHERE event_id = ? ORDER BY id LIMIT 1`, eventID).Scan(&entryID, &email)
+		if errors.Is(err, sql.ErrNoRows) {
+			break // Waitlist exhausted
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read waitlist: %w", err)
+		}
+
+		// Someone already holding a ticket can't take another; just drop them from the queue.
+		// A cancelled ticket does not count, since its owner may register again.
+		var hasTicket bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM tickets WHERE event_id = ? AND user_email = ? AND status IN ('reserved', 'confirmed'))`, eventID, email).Scan(&hasTicket); err != nil {
+			return nil, fmt.Errorf("failed to check existing ticket: %w", err)
+		}
+
+		if !hasTicket {
+			res, err := tx.ExecContext(ctx, `UPDATE events SET available_spots = available_spots - 1 WHERE id = ? AND available_spots > 0`, eventID)
+			if classifySQLiteError(err) == ErrorKindCheck {
+				slog.ErrorContext(ctx, "available_spots check violated while promoting waitlist", "event_id", eventID, "error", err)
+				break // Treat as sold out; the user keeps their place in line
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to update event capacity: %w", err)
+			}
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return nil, err
+			}
+			if rows == 0 {
+				break // No seats left; the user keeps their place in line
+			}
+
+			// The ':' keeps promotion keys out of reach of clients, whose keys cannot contain it
+			res, err = tx.ExecContext(ctx, `
+				INSERT INTO tickets (event_id, user_email, idempotency_key, status, amount_due, currency, confirmation_code, created_at, expires_at)
+				VALUES (?, ?, ?, 'reserved', ?, ?, ?, ?, ?)
+			`, eventID, email, fmt.Sprintf("waitlist:%d", entryID), priceCents, currency, newConfirmationCode(), sqliteTimestamp(now), sqliteTimestamp(now.Add(hold)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to reserve ticket for waitlisted user: %w", err)
+			}
+			ticketID, err := res.LastInsertId()
+			if err != nil {
+				return nil, err
+			}
+			if err := assignSeat(ctx, tx, eventID, ticketID, ""); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM waitlist WHERE id = ?`, entryID); err != nil {
+			return nil, fmt.Errorf("failed to dequeue waitlist entry: %w", err)
+		}
+		if !hasTicket {
+			promoted = append(promoted, email)
+		}
+	}
+	return promoted, nil
+}
+
+// Seat statuses
+const (
+	SeatAvailable = "available"
+	SeatAssigned  = "assigned"
+)
+
+// Seat is one labelled place in a reserved-seating event's seat map.
+type Seat struct {
+	Label  string `json:"label"`
+	Status string `json:"status"`
+}
+
+var ErrNoSeatMap = errors.New("event does not have reserved seating")
+var ErrSeatNotFound = errors.New("seat does not exist for this event")
+var ErrSeatTaken = errors.New("seat is already taken")
+
+// ListSeats returns an event's seat map in label order. Events without reserved seating
+// return an empty map; drafts report ErrEventNotFound.
+func (db *DB) ListSeats(ctx context.Context, eventID int64) ([]Seat, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM events WHERE id = ? AND status != ?)`, eventID, EventStatusDraft).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrEventNotFound
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT seat_label, status FROM seats WHERE event_id = ? ORDER BY seat_label`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seats := []Seat{}
+	for rows.Next() {
+		var seat Seat
+		if err := rows.Scan(&seat.Label, &seat.Status); err != nil {
+			return nil, err
+		}
+		seats = append(seats, seat)
+	}
+	return seats, rows.Err()
+}
+
+// assignSeat pins ticketID to a seat when the event has a seat map. The seat is claimed
+// with the same conditional-update pattern as event capacity, so two transactions racing
+// for one label cannot both win. An empty label takes the first free seat.
+func assignSeat(ctx context.Context, tx *sql.Tx, eventID, ticketID int64, label string) error {
+	var seated bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM seats WHERE event_id = ?)`, eventID).Scan(&seated); err != nil {
+		return fmt.Errorf("failed to check seat map: %w", err)
+	}
+	if !seated {
+		if label != "" {
+			return ErrNoSeatMap
+		}
+		return nil
+	}
+
+	var res sql.Result
+	var err error
+	if label != "" {
+		res, err = tx.ExecContext(ctx, `
+			UPDATE seats SET status = 'assigned', ticket_id = ?
+			WHERE event_id = ? AND seat_label = ? AND status = 'available'
+		`, ticketID, eventID, label)
+	} else {
+		res, err = tx.ExecContext(ctx, `
+			UPDATE seats SET status = 'assigned', ticket_id = ?
+			WHERE id = (SELECT id FROM seats WHERE event_id = ? AND status = 'available' ORDER BY id LIMIT 1)
+		`, ticketID, eventID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to assign seat: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+	if label == "" {
+		// Capacity said there was room but no seat is free; treat it as full
+		return ErrSoldOut
+	}
+
+	var labelExists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM seats WHERE event_id = ? AND seat_label = ?)`, eventID, label).Scan(&labelExists); err != nil {
+		return fmt.Errorf("failed to check seat: %w", err)
+	}
+	if !labelExists {
+		return ErrSeatNotFound
+	}
+	return ErrSeatTaken
+}
+
+// releaseSeat frees whatever seat ticketID held, if any.
+func releaseSeat(ctx context.Context, tx *sql.Tx, ticketID int64) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE seats SET status = 'available', ticket_id = NULL WHERE ticket_id = ?`, ticketID); err != nil {
+		return fmt.Errorf("failed to release seat: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterForEventMissingEvent(t *testing.T) {
+	db := NewTestDB(t)
+
+	_, err := db.RegisterForEvent(context.Background(), RegisterParams{EventID: 9999, Email: "ghost@example.com", IdempotencyKey: "key_ghost"})
+	if !errors.Is(err, ErrEventNotFound) {
+		t.Fatalf("Expected ErrEventNotFound for missing event, got %v", err)
+	}
+}
+
+func TestRegisterForEventFullEvent(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Tiny Meetup", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "first@example.com", IdempotencyKey: "key_first"}); err != nil {
+		t.Fatalf("First registration failed: %v", err)
+	}
+
+	_, err = db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "second@example.com", IdempotencyKey: "key_second"})
+	if !errors.Is(err, ErrSoldOut) {
+		t.Fatalf("Expected ErrSoldOut for full event, got %v", err)
+	}
+}
+
+func TestNewDBCreatesParentDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data", "nested", "volume")
+	dbPath := filepath.Join(dir, "events.db")
+
+	db, err := NewDB("file:" + dbPath + "?mode=rwc")
+	if err != nil {
+		t.Fatalf("NewDB failed for nested path: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(context.Background()); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("Expected database file at %s: %v", dbPath, err)
+	}
+}
+
+func TestNewDBReportsUnusableDirectory(t *testing.T) {
+	// A regular file where a directory is expected can never be created as a directory
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatalf("Failed to create blocker file: %v", err)
+	}
+
+	_, err := NewDB("file:" + filepath.Join(blocker, "events.db"))
+	if err == nil || !strings.Contains(err.Error(), "cannot create database directory") {
+		t.Fatalf("Expected a clear directory error, got %v", err)
+	}
+}
+
+func TestNewDBWaitsForLateDirectory(t *testing.T) {
+	// The data volume is not there on the first attempt: a file blocks the directory
+	mount := filepath.Join(t.TempDir(), "mnt")
+	if err := os.WriteFile(mount, nil, 0o644); err != nil {
+		t.Fatalf("Failed to create blocker file: %v", err)
+	}
+	calls := 0
+	prepareDirectory = func(dsn string) error {
+		calls++
+		err := prepareDatabaseDir(dsn)
+		if calls == 1 {
+			os.Remove(mount) // Mounted just after the first check
+		}
+		return err
+	}
+	t.Cleanup(func() { prepareDirectory = prepareDatabaseDir })
+
+	db, err := NewDBWithTuning("file:"+filepath.Join(mount, "data", "events.db"), Tuning{PingAttempts: 3, PingBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Expected NewDB to succeed once the directory appeared, got %v", err)
+	}
+	defer db.Close()
+	if calls != 2 {
+		t.Errorf("Expected 2 directory checks, got %d", calls)
+	}
+	if err := db.InitSchema(context.Background()); err != nil {
+		t.Errorf("Expected a usable database, got %v", err)
+	}
+}
+
+func TestInitSchemaCreatesHotPathIndexes(t *testing.T) {
+	db := NewTestDB(t)
+
+	rows, err := db.Query(`PRAGMA index_list('tickets')`)
+	if err != nil {
+		t.Fatalf("Failed to list indexes: %v", err)
+	}
+	defer rows.Close()
+
+	found := map[string]bool{}
+	for rows.Next() {
+		var seq, unique, partial int
+		var name, origin string
+		if err := rows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			t.Fatalf("Failed to scan index row: %v", err)
+		}
+		found[name] = true
+	}
+
+	for _, want := range []string{"idx_tickets_status_expires_at", "idx_tickets_event_id"} {
+		if !found[want] {
+			t.Errorf("Expected index %s on tickets, found %v", want, found)
+		}
+	}
+}
+
+func TestReclaimQueryUsesStatusExpiryIndex(t *testing.T) {
+	db := NewTestDB(t)
+
+	if plan := reclaimQueryPlan(t, db); !strings.Contains(plan, "idx_tickets_status_expires_at") {
+		t.Errorf("Expected reclaim scan to use idx_tickets_status_expires_at, plan: %s", plan)
+	}
+}
+
+// reclaimQueryPlan returns SQLite's plan for the reclaim worker's scan.
+func reclaimQueryPlan(tb testing.TB, db *DB) string {
+	tb.Helper()
+	rows, err := db.Query(`EXPLAIN QUERY PLAN SELECT id, event_id FROM tickets WHERE status = 'reserved' AND expires_at <= datetime('now')`)
+	if err != nil {
+		tb.Fatalf("Failed to explain reclaim query: %v", err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			tb.Fatalf("Failed to scan plan: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	return strings.Join(plan, "; ")
+}
+
+func BenchmarkReclaimExpiredSeats(b *testing.B) {
+	db := NewTestDB(b)
+	ctx := context.Background()
+
+	// A large, mostly settled tickets table with a handful of fresh reservations
+	evt, err := db.CreateEvent(ctx, Event{Name: "Benchmark Bash", TotalSpots: 1_000_000})
+	if err != nil {
+		b.Fatalf("Failed to create event: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 20000)
+		INSERT INTO tickets (event_id, user_email, idempotency_key, status, expires_at)
+		SELECT ?, 'bench' || n || '@example.com', 'bench_' || n,
+			CASE WHEN n % 100 = 0 THEN 'reserved' ELSE 'confirmed' END,
+			datetime('now', '+5 minutes')
+		FROM seq
+	`, evt.ID); err != nil {
+		b.Fatalf("Failed to seed tickets: %v", err)
+	}
+
+	plan := reclaimQueryPlan(b, db)
+	if !strings.Contains(plan, "idx_tickets_status_expires_at") {
+		b.Fatalf("Expected reclaim scan to use idx_tickets_status_expires_at, plan: %s", plan)
+	}
+	b.Logf("reclaim query plan: %s", plan)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.ReclaimExpiredSeats(ctx); err != nil {
+			b.Fatalf("ReclaimExpiredSeats failed: %v", err)
+		}
+	}
+}
+
+func TestAutoConfirmTicketsAreNeverReclaimed(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Free Webinar", TotalSpots: 2, AutoConfirm: true})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "walkin@example.com", IdempotencyKey: "key_walkin"})
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	ticketID := registered.ID
+
+	ticket, err := db.GetTicket(ctx, ticketID)
+	if err != nil {
+		t.Fatalf("GetTicket failed: %v", err)
+	}
+	if ticket.Status != "confirmed" {
+		t.Fatalf("Expected auto-confirmed ticket, got status %q", ticket.Status)
+	}
+
+	// Even if the clock runs far past any hold window, the worker must leave it alone
+	if _, err := db.ExecContext(ctx, `UPDATE tickets SET expires_at = datetime('now', '-1 day') WHERE id = ?`, ticketID); err != nil {
+		t.Fatalf("Failed to age ticket: %v", err)
+	}
+	reclaimed, err := db.ReclaimExpiredSeats(ctx)
+	if err != nil {
+		t.Fatalf("ReclaimExpiredSeats failed: %v", err)
+	}
+	if reclaimed != 0 {
+		t.Errorf("Expected no reclaimed seats, got %d", reclaimed)
+	}
+
+	updated, _ := db.GetEvent(ctx, evt.ID)
+	if updated.AvailableSpots != 1 {
+		t.Errorf("Expected 1 available spot, got %d", updated.AvailableSpots)
+	}
+}
+
+func TestRegistrationWindow(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	opens := time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC)
+	closes := opens.Add(48 * time.Hour)
+	evt, err := db.CreateEvent(ctx, Event{Name: "Early Bird", TotalSpots: 10, RegistrationOpensAt: &opens, RegistrationClosesAt: &closes})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	unbounded, err := db.CreateEvent(ctx, Event{Name: "Always Open", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		eventID int64
+		now     time.Time
+		wantErr error
+	}{
+		{"before open", evt.ID, opens.Add(-time.Second), ErrRegistrationNotOpen},
+		{"at open", evt.ID, opens, nil},
+		{"within window", evt.ID, opens.Add(24 * time.Hour), nil},
+		{"at close", evt.ID, closes, ErrRegistrationNotOpen},
+		{"after close", evt.ID, closes.Add(time.Hour), ErrRegistrationNotOpen},
+		{"no window", unbounded.ID, opens.Add(-365 * 24 * time.Hour), nil},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db.Clock = NewMockClock(tt.now)
+			_, err := db.RegisterForEvent(ctx, RegisterParams{EventID: tt.eventID, Email: fmt.Sprintf("w%d@example.com", i), IdempotencyKey: fmt.Sprintf("window_%d", i)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	got, err := db.GetEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to load event: %v", err)
+	}
+	if got.AvailableSpots != 8 {
+		t.Errorf("Expected only the two in-window registrations to take spots, got %d available", got.AvailableSpots)
+	}
+	if got.RegistrationOpensAt == nil || !got.RegistrationOpensAt.Equal(opens) {
+		t.Errorf("Expected registration_opens_at %v, got %v", opens, got.RegistrationOpensAt)
+	}
+}
+
+// slowClock stalls every reading, standing in for a database that has stopped responding.
+type slowClock struct {
+	Clock
+	delay time.Duration
+}
+
+func (c slowClock) Now() time.Time {
+	time.Sleep(c.delay)
+	return c.Clock.Now()
+}
+
+func TestReclaimTimeoutRollsBackCleanly(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Slow Reclaim", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "late@example.com", IdempotencyKey: "late"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	ticketID := registered.ID
+	clock.Advance(defaultHoldDuration)
+
+	db.Clock = slowClock{Clock: clock, delay: 100 * time.Millisecond}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := db.ReclaimExpiredSeats(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the pass to hit its deadline, got %v", err)
+	}
+	// The worker wrapper logs the timeout and carries on rather than failing
+	reclaimExpiredSeats(ctx, db, 10*time.Millisecond, nil)
+
+	ticket, err := db.GetTicket(ctx, ticketID)
+	if err != nil {
+		t.Fatalf("Failed to load ticket: %v", err)
+	}
+	got, err := db.GetEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to load event: %v", err)
+	}
+	if ticket.Status != "reserved" || got.AvailableSpots != 0 {
+		t.Fatalf("Expected the aborted pass to change nothing, got status %q and %d spots", ticket.Status, got.AvailableSpots)
+	}
+
+	// The next, unhurried pass finishes the job
+	db.Clock = clock
+	if n, err := db.ReclaimExpiredSeats(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected the retry to reclaim 1 ticket, got %d (%v)", n, err)
+	}
+	if got, err := db.GetEvent(ctx, evt.ID); err != nil || got.AvailableSpots != 1 {
+		t.Errorf("Expected the spot back after the retry, got %+v (%v)", got, err)
+	}
+}
+
+func TestNewDBRetriesTransientPingFailures(t *testing.T) {
+	calls := 0
+	pingDatabase = func(db *sql.DB) error {
+		calls++
+		if calls <= 3 {
+			return errors.New("unable to open database file")
+		}
+		return db.Ping()
+	}
+	t.Cleanup(func() { pingDatabase = (*sql.DB).Ping })
+
+	db, err := NewDBWithTuning("file::memory:", Tuning{PingAttempts: 5, PingBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Expected NewDB to connect after transient failures, got %v", err)
+	}
+	db.Close()
+	if calls != 4 {
+		t.Errorf("Expected 4 ping attempts, got %d", calls)
+	}
+
+	calls = 0
+	if _, err := NewDBWithTuning("file::memory:", Tuning{PingAttempts: 2, PingBackoff: time.Millisecond}); err == nil {
+		t.Fatal("Expected NewDB to give up once attempts are exhausted")
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 ping attempts, got %d", calls)
+	}
+}
+
+func TestNewDBWithTuningAppliesPragmas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tuned.db")
+	db, err := NewDBWithTuning("file:"+path+"?mode=rwc", Tuning{CacheSizeKiB: 32 << 10, MmapSize: 64 << 20})
+	if err != nil {
+		t.Fatalf("NewDBWithTuning failed: %v", err)
+	}
+	defer db.Close()
+
+	var cacheSize, mmapSize int64
+	if err := db.QueryRow(`PRAGMA cache_size`).Scan(&cacheSize); err != nil {
+		t.Fatalf("Failed to read cache_size: %v", err)
+	}
+	if err := db.QueryRow(`PRAGMA mmap_size`).Scan(&mmapSize); err != nil {
+		t.Fatalf("Failed to read mmap_size: %v", err)
+	}
+	if cacheSize != -(32 << 10) {
+		t.Errorf("Expected cache_size %d, got %d", -(32 << 10), cacheSize)
+	}
+	if mmapSize != 64<<20 {
+		t.Errorf("Expected mmap_size %d, got %d", 64<<20, mmapSize)
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		scope         IdempotencyScope
+		otherEventErr error
+	}{
+		{IdempotencyScopeGlobal, ErrAlreadyRegistered},
+		{IdempotencyScopeEvent, nil},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			db := NewTestDB(t)
+			db.IdempotencyScope = tt.scope
+			if err := db.InitSchema(ctx); err != nil {
+				t.Fatalf("Failed to apply scope: %v", err)
+			}
+
+			first, err := db.CreateEvent(ctx, Event{Name: "First", TotalSpots: 5})
+			if err != nil {
+				t.Fatalf("Failed to create event: %v", err)
+			}
+			second, err := db.CreateEvent(ctx, Event{Name: "Second", TotalSpots: 5})
+			if err != nil {
+				t.Fatalf("Failed to create event: %v", err)
+			}
+
+			if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: first.ID, Email: "a@example.com", IdempotencyKey: "shared"}); err != nil {
+				t.Fatalf("Failed to register: %v", err)
+			}
+			// A replayed key at the same event collides whatever the scope
+			if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: first.ID, Email: "b@example.com", IdempotencyKey: "shared"}); !errors.Is(err, ErrAlreadyRegistered) {
+				t.Errorf("Expected ErrAlreadyRegistered at the same event, got %v", err)
+			}
+			_, err = db.RegisterForEvent(ctx, RegisterParams{EventID: second.ID, Email: "a@example.com", IdempotencyKey: "shared"})
+			if !errors.Is(err, tt.otherEventErr) {
+				t.Errorf("Expected %v at another event, got %v", tt.otherEventErr, err)
+			}
+		})
+	}
+}
+
+func TestNewDBRejectsMalformedDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"", "empty"},
+		{"postgres://localhost/events", `unsupported scheme "postgres"`},
+		{"file:events.db?mode=rwc&cahce=shared", `unknown parameter "cahce"`},
+		{"file:events.db?mode=readwrite", "mode must be one of ro, rw, rwc, memory"},
+		{"file:events.db?_pragma=busy_timeout=5000", "malformed _pragma"},
+		{"file:events.db?_pragma=jounral_mode(WAL)", `unknown pragma "jounral_mode"`},
+		{"file:events.db?mode=%zz", "malformed query"},
+	}
+	for _, tt := range tests {
+		_, err := NewDB(tt.dsn)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewDB(%q): expected an error mentioning %q, got %v", tt.dsn, tt.want, err)
+		}
+	}
+
+	if err := validateDSN("file:events.db?cache=shared&mode=rwc&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"); err != nil {
+		t.Errorf("Expected a well-formed DSN to pass, got %v", err)
+	}
+}
+
+func TestCancelledTicketDoesNotBlockReRegistration(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	evt, err := db.CreateEvent(ctx, Event{Name: "Second Thoughts", TotalSpots: 2})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	first, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "fickle@example.com", IdempotencyKey: "fickle_1"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "fickle@example.com", IdempotencyKey: "fickle_2"}); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("Expected ErrAlreadyRegistered while the first ticket is active, got %v", err)
+	}
+	if err := db.CancelTicket(ctx, first.ID, "fickle@example.com"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+
+	again, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "fickle@example.com", IdempotencyKey: "fickle_2"})
+	if err != nil {
+		t.Fatalf("Expected to register again after cancelling, got %v", err)
+	}
+	if again.ID == first.ID || again.Status != "reserved" {
+		t.Errorf("Expected a fresh reservation, got %+v", again)
+	}
+
+	// The cancelled ticket is kept as it was
+	old, err := db.GetTicket(ctx, first.ID)
+	if err != nil || old.Status != "cancelled" {
+		t.Errorf("Expected the first ticket to stay cancelled, got %+v (%v)", old, err)
+	}
+	got, err := db.GetEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to load event: %v", err)
+	}
+	if got.AvailableSpots != 1 {
+		t.Errorf("Expected 1 spot left, got %d", got.AvailableSpots)
+	}
+}
+
+func TestMemoryModeKeepsDataAcrossConnections(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDB(MemoryDSN)
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if err := db.InitSchema(ctx); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+
+	// Make database/sql drop its connection after every call, as it may after a driver
+	// error; without the anchor each call would start from an empty database
+	db.SetMaxIdleConns(0)
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Ephemeral Demo", TotalSpots: 4})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "demo@example.com", IdempotencyKey: "demo"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	got, err := db.GetEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Expected the event to survive a new connection, got %v", err)
+	}
+	if got.Name != "Ephemeral Demo" || got.AvailableSpots != 3 {
+		t.Errorf("Expected the event with 3 spots left, got %+v", got)
+	}
+}
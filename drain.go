@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// drainRetryAfterSeconds is what draining instances advertise in Retry-After; by then the
+// load balancer should have moved the client onto a healthy instance.
+const drainRetryAfterSeconds = "30"
+
+// Draining reports whether the instance is refusing new registrations.
+func (h *Handlers) Draining() bool {
+	return h.draining.Load()
+}
+
+// RejectWhileDraining answers 503 instead of calling next while drain mode is on.
+// Reads stay available so in-flight clients can finish what they started.
+func (h *Handlers) RejectWhileDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Draining() {
+			w.Header().Set("Retry-After", drainRetryAfterSeconds)
+			SendJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "Server is draining, please retry shortly"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleDrain handles POST /admin/drain
+func (h *Handlers) HandleDrain(w http.ResponseWriter, r *http.Request) {
+	h.draining.Store(true)
+	slog.Warn("drain mode enabled, rejecting new registrations")
+	SendJSON(w, http.StatusOK, map[string]bool{"draining": true})
+}
+
+// HandleUndrain handles POST /admin/undrain
+func (h *Handlers) HandleUndrain(w http.ResponseWriter, r *http.Request) {
+	h.draining.Store(false)
+	slog.Info("drain mode disabled, accepting registrations")
+	SendJSON(w, http.StatusOK, map[string]bool{"draining": false})
+}
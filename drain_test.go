@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDrainModeRejectsRegistrationButServesReads(t *testing.T) {
+	db := NewTestDB(t)
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Deploy Day", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	do := func(method, path, role, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if role != "" {
+			req.Header.Set("X-Role", role)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	registerPath := fmt.Sprintf("/events/%d/register", evt.ID)
+
+	if rec := do(http.MethodPost, "/admin/drain", "admin", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected drain to succeed, got %d", rec.Code)
+	}
+
+	rec := do(http.MethodPost, registerPath, "user", `{"email":"late@example.com","idempotency_key":"key_late"}`)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while draining, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header while draining")
+	}
+
+	if rec := do(http.MethodGet, "/events", "", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected list events to keep working while draining, got %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/admin/undrain", "admin", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected undrain to succeed, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, registerPath, "user", `{"email":"late@example.com","idempotency_key":"key_late"}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected registration to succeed after undrain, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDrainRequiresAdmin(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t), AllowHeaderRole: true}).Routes()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/drain", nil)
+	req.Header.Set("X-Role", "organizer")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-admin drain, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// defaultEmailTimeout bounds one delivery attempt when Handlers.EmailTimeout is unset.
+const defaultEmailTimeout = 5 * time.Second
+
+// EmailSender delivers transactional mail. Apart from the response to the registration
+// that created a ticket, it is the only way a confirmation code reaches anyone, so knowing
+// an email address is not enough to obtain someone else's code.
+type EmailSender interface {
+	SendConfirmationCode(ctx context.Context, to string, ticket *Ticket) error
+	// SendSoldOutNotice tells an organizer their event has just sold out.
+	SendSoldOutNotice(ctx context.Context, to string, event *Event) error
+}
+
+// LogEmailSender is the default sender: it records what would have been mailed. Swap in
+// a real provider when one is configured.
+type LogEmailSender struct{}
+
+func (LogEmailSender) SendConfirmationCode(ctx context.Context, to string, ticket *Ticket) error {
+	slog.InfoContext(ctx, "confirmation code email", "to", to, "ticket_id", ticket.ID, "event_id", ticket.EventID)
+	return nil
+}
+
+func (LogEmailSender) SendSoldOutNotice(ctx context.Context, to string, event *Event) error {
+	slog.InfoContext(ctx, "sold out email", "to", to, "event_id", event.ID, "event_name", event.Name)
+	return nil
+}
+
+func (h *Handlers) emailSender() EmailSender {
+	if h.Email != nil {
+		return h.Email
+	}
+	return LogEmailSender{}
+}
+
+func (h *Handlers) emailTimeout() time.Duration {
+	if h.EmailTimeout > 0 {
+		return h.EmailTimeout
+	}
+	return defaultEmailTimeout
+}
+
+// deliver runs send with a context that expires after emailTimeout and stops waiting for
+// it then, so a hung mail server never holds up a request or worker for longer. A sender
+// that ignores its context keeps running in the background until it returns; its result
+// is dropped, as there is no retry queue.
+func (h *Handlers) deliver(ctx context.Context, send func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, h.emailTimeout())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- send(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("email delivery abandoned after %s: %w", h.emailTimeout(), ctx.Err())
+	}
+}
+
+// sendConfirmationCode mails ticket's code to its owner. Delivery is best effort: the
+// ticket is already valid, so a failure is logged rather than surfaced to the caller.
+func (h *Handlers) sendConfirmationCode(ctx context.Context, ticket *Ticket) {
+	if ticket.UserEmail == "" || ticket.ConfirmationCode == "" {
+		return
+	}
+	err := h.deliver(ctx, func(ctx context.Context) error {
+		return h.emailSender().SendConfirmationCode(ctx, ticket.UserEmail, ticket)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send confirmation code", "ticket_id", ticket.ID, "error", err)
+	}
+}
+
+// NotifySoldOut emails the organizer of an event that has just sold out; it is meant to
+// be installed as DB.OnSoldOut. Events without an organizer are skipped and, as with
+// confirmation codes, failures are only logged.
+func (h *Handlers) NotifySoldOut(ctx context.Context, eventID int64) {
+	evt, err := h.DB.GetEvent(ctx, eventID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load sold out event", "event_id", eventID, "error", err)
+		return
+	}
+	if evt.OrganizerEmail == "" {
+		return
+	}
+	err = h.deliver(ctx, func(ctx context.Context) error {
+		return h.emailSender().SendSoldOutNotice(ctx, evt.OrganizerEmail, evt)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send sold out notice", "event_id", eventID, "error", err)
+	}
+}
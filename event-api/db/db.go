@@ -6,29 +6,57 @@ import (
 	"event-api/models"
 	"fmt"
 	"log"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-var DB *sql.DB
+// Errors returned by RegisterUser when no spot could be taken.
+var (
+	ErrEventNotFound = errors.New("event does not exist")
+	ErrSoldOut       = errors.New("event is sold out")
+)
+
+// sqliteTimeFormat is the layout SQLite's CURRENT_TIMESTAMP produces. Times we store use
+// it too, so a column holds one format whether or not its default filled it in.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// Store is one open database. Every query goes through a Store rather than a package
+// global, so tests can each work on their own database without sharing state.
+type Store struct {
+	DB *sql.DB
+}
 
-// InitDB initializes the SQLite database and creates necessary tables
-func InitDB(dataSourceName string) error {
-	var err error
-	DB, err = sql.Open("sqlite", dataSourceName)
+// InitDB opens the SQLite database, creates the necessary tables and returns a Store for it
+func InitDB(dataSourceName string) (*Store, error) {
+	conn, err := sql.Open("sqlite", dataSourceName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err = DB.Ping(); err != nil {
-		return err
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
 	}
 
-	return createTables()
+	s := &Store{DB: conn}
+	if err := s.createTables(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-func createTables() error {
-	createEventsTable := `
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
+
+// migrations is the ordered schema history. Each entry is applied once and recorded in
+// schema_migrations under its 1-based position, so only ever append to it.
+var migrations = []string{
+	// 1: initial tables
+	`
 	CREATE TABLE IF NOT EXISTS events (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		title TEXT NOT NULL,
@@ -36,9 +64,8 @@ func createTables() error {
 		capacity INTEGER NOT NULL,
 		available_spots INTEGER NOT NULL,
 		date DATETIME NOT NULL
-	);`
+	);
 
-	createRegistrationsTable := `
 	CREATE TABLE IF NOT EXISTS registrations (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		event_id INTEGER NOT NULL,
@@ -46,24 +73,61 @@ func createTables() error {
 		user_email TEXT NOT NULL,
 		registered_date DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY(event_id) REFERENCES events(id)
-	);`
+	);`,
+}
 
-	_, err := DB.Exec(createEventsTable)
+// createTables brings the database up to the latest migration, skipping the ones already
+// recorded in schema_migrations.
+func (s *Store) createTables() error {
+	_, err := s.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`)
 	if err != nil {
-		return fmt.Errorf("could not create events table: %v", err)
+		return fmt.Errorf("could not create schema_migrations table: %v", err)
+	}
+
+	for i, stmt := range migrations {
+		if err := s.applyMigration(i+1, stmt); err != nil {
+			return fmt.Errorf("could not apply migration %d: %v", i+1, err)
+		}
 	}
+	return nil
+}
 
-	_, err = DB.Exec(createRegistrationsTable)
+func (s *Store) applyMigration(version int, stmt string) error {
+	tx, err := s.DB.Begin()
 	if err != nil {
-		return fmt.Errorf("could not create registrations table: %v", err)
+		return err
 	}
+	defer tx.Rollback()
 
+	var applied bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)", version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.Exec(stmt); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations(version) VALUES(?)", version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Applied schema migration %d\n", version)
 	return nil
 }
 
 // CreateEvent inserts a new event into the database
-func CreateEvent(e models.Event) (int64, error) {
-	stmt, err := DB.Prepare("INSERT INTO events(title, description, capacity, available_spots, date) VALUES(?, ?, ?, ?, ?)")
+func (s *Store) CreateEvent(e models.Event) (int64, error) {
+	stmt, err := s.DB.Prepare("INSERT INTO events(title, description, capacity, available_spots, date) VALUES(?, ?, ?, ?, ?)")
 	if err != nil {
 		return 0, err
 	}
@@ -78,8 +142,8 @@ func CreateEvent(e models.Event) (int64, error) {
 }
 
 // GetEvents retrieves all events
-func GetEvents() ([]models.Event, error) {
-	rows, err := DB.Query("SELECT id, title, description, capacity, available_spots, date FROM events")
+func (s *Store) GetEvents() ([]models.Event, error) {
+	rows, err := s.DB.Query("SELECT id, title, description, capacity, available_spots, date FROM events")
 	if err != nil {
 		return nil, err
 	}
@@ -97,11 +161,13 @@ func GetEvents() ([]models.Event, error) {
 }
 
 // RegisterUser handles the concurrent registration logic using atomic updates.
-func RegisterUser(registration models.Registration) error {
+// It returns the stored registration with its new ID and RegisteredDate filled in, or
+// ErrEventNotFound / ErrSoldOut when no spot could be taken.
+func (s *Store) RegisterUser(registration models.Registration) (models.Registration, error) {
 	// Optimization: Start a transaction
-	tx, err := DB.Begin()
+	tx, err := s.DB.Begin()
 	if err != nil {
-		return err
+		return registration, err
 	}
 
 	// The Critical Concurrency Step: Optimistic Concurrency Control using Atomic DB Update.
@@ -110,40 +176,56 @@ func RegisterUser(registration models.Registration) error {
 	res, err := tx.Exec("UPDATE events SET available_spots = available_spots - 1 WHERE id = ? AND available_spots > 0", registration.EventID)
 	if err != nil {
 		tx.Rollback()
-		return err
+		return registration, err
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		tx.Rollback()
-		return err
+		return registration, err
 	}
 
 	// If no rows were affected, the event is either sold out or doesn't exist.
 	if rowsAffected == 0 {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM events WHERE id = ?)", registration.EventID).Scan(&exists)
 		tx.Rollback()
-		return errors.New("event is sold out or does not exist")
+		switch {
+		case err != nil:
+			return registration, err
+		case !exists:
+			return registration, ErrEventNotFound
+		default:
+			return registration, ErrSoldOut
+		}
 	}
 
 	// Insert the registration record
-	stmt, err := tx.Prepare("INSERT INTO registrations(event_id, user_name, user_email) VALUES(?, ?, ?)")
+	stmt, err := tx.Prepare("INSERT INTO registrations(event_id, user_name, user_email, registered_date) VALUES(?, ?, ?, ?)")
 	if err != nil {
 		tx.Rollback()
-		return err
+		return registration, err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(registration.EventID, registration.UserName, registration.UserEmail)
+	registration.RegisteredDate = time.Now().UTC().Truncate(time.Second)
+	res, err = stmt.Exec(registration.EventID, registration.UserName, registration.UserEmail, registration.RegisteredDate.Format(sqliteTimeFormat))
 	if err != nil {
 		tx.Rollback()
-		return err
+		return registration, err
 	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return registration, err
+	}
+	registration.ID = int(id)
 
 	// Commit the transaction
 	if err = tx.Commit(); err != nil {
-		return err
+		return registration, err
 	}
 
 	log.Printf("Successfully registered user %s for event %d\n", registration.UserEmail, registration.EventID)
-	return nil
+	return registration, nil
 }
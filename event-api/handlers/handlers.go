@@ -2,26 +2,90 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"event-api/db"
 	"event-api/models"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
+// Default length limits, in characters, for event text fields
+const (
+	DefaultMaxTitleLength       = 200
+	DefaultMaxDescriptionLength = 5000
+)
+
+// Handlers serves the API from one Store
+type Handlers struct {
+	Store *db.Store
+
+	// MaxTitleLength and MaxDescriptionLength bound event text in characters; zero means the default.
+	MaxTitleLength       int
+	MaxDescriptionLength int
+}
+
+// eventBodyOverhead allows for the JSON around an event's title and description: the
+// other fields, quotes and whitespace.
+const eventBodyOverhead = 1024
+
+// textLimits returns the title and description limits in force.
+func (h *Handlers) textLimits() (maxTitle, maxDescription int) {
+	maxTitle, maxDescription = h.MaxTitleLength, h.MaxDescriptionLength
+	if maxTitle <= 0 {
+		maxTitle = DefaultMaxTitleLength
+	}
+	if maxDescription <= 0 {
+		maxDescription = DefaultMaxDescriptionLength
+	}
+	return maxTitle, maxDescription
+}
+
+// maxEventBodyBytes is the largest POST /events body that can still hold text within the
+// limits. A character takes at most 12 bytes of JSON, as an escaped surrogate pair.
+func (h *Handlers) maxEventBodyBytes() int64 {
+	maxTitle, maxDescription := h.textLimits()
+	return int64(maxTitle+maxDescription)*12 + eventBodyOverhead
+}
+
+// validateEventText rejects event titles and descriptions over their limits, naming the field.
+func (h *Handlers) validateEventText(event models.Event) error {
+	maxTitle, maxDescription := h.textLimits()
+	if utf8.RuneCountInString(event.Title) > maxTitle {
+		return fmt.Errorf("title must be at most %d characters", maxTitle)
+	}
+	if utf8.RuneCountInString(event.Description) > maxDescription {
+		return fmt.Errorf("description must be at most %d characters", maxDescription)
+	}
+	return nil
+}
+
 // CreateEvent handles POST /events
-func CreateEvent(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	// Refuse oversized bodies while reading rather than after decoding all of them
 	var event models.Event
-	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxEventBodyBytes())).Decode(&event); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	if err := h.validateEventText(event); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 
-	id, err := db.CreateEvent(event)
+	id, err := h.Store.CreateEvent(event)
 	if err != nil {
 		http.Error(w, "Failed to create event", http.StatusInternalServerError)
 		return
@@ -35,13 +99,13 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetEvents handles GET /events
-func GetEvents(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetEvents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	events, err := db.GetEvents()
+	events, err := h.Store.GetEvents()
 	if err != nil {
 		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
 		return
@@ -58,7 +122,7 @@ func GetEvents(w http.ResponseWriter, r *http.Request) {
 
 // RegisterForEvent handles POST /events/{id}/register
 // Now using Go 1.22 ServeMux so we can access PathValue.
-func RegisterForEvent(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) RegisterForEvent(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -77,22 +141,34 @@ func RegisterForEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Blank names or emails would only store junk rows; emails are compared case-insensitively
+	reg.UserName = strings.TrimSpace(reg.UserName)
+	reg.UserEmail = strings.ToLower(strings.TrimSpace(reg.UserEmail))
+	if reg.UserName == "" || reg.UserEmail == "" {
+		http.Error(w, "user_name and user_email are required", http.StatusBadRequest)
+		return
+	}
 	reg.EventID = eventID
 
 	// Attempt consistent registration via atomic update
-	err = db.RegisterUser(reg)
+	reg, err = h.Store.RegisterUser(reg)
 	if err != nil {
-		// Differentiate between sold out and other errors
-		if err.Error() == "event is sold out or does not exist" {
+		// Differentiate between sold out, missing events and other errors
+		switch {
+		case errors.Is(err, db.ErrSoldOut):
 			http.Error(w, err.Error(), http.StatusConflict) // 409 Conflict
-		} else {
+		case errors.Is(err, db.ErrEventNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
 			http.Error(w, "Failed to register for event: "+err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Successfully registered!",
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":         "Successfully registered!",
+		"id":              reg.ID,
+		"registered_date": reg.RegisteredDate,
 	})
 }
@@ -3,22 +3,29 @@ package main
 import (
 	"event-api/db"
 	"event-api/handlers"
+	"flag"
 	"log"
 	"net/http"
 )
 
 func main() {
+	maxTitle := flag.Int("max-title-length", handlers.DefaultMaxTitleLength, "Most characters an event title may have")
+	maxDescription := flag.Int("max-description-length", handlers.DefaultMaxDescriptionLength, "Most characters an event description may have")
+	flag.Parse()
+
 	log.Println("Initializing database...")
-	err := db.InitDB("events.db")
+	store, err := db.InitDB("events.db")
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	defer store.Close()
 
+	h := &handlers.Handlers{Store: store, MaxTitleLength: *maxTitle, MaxDescriptionLength: *maxDescription}
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("POST /events", handlers.CreateEvent)
-	mux.HandleFunc("GET /events", handlers.GetEvents)
-	mux.HandleFunc("POST /events/{id}/register", handlers.RegisterForEvent)
+	mux.HandleFunc("POST /events", h.CreateEvent)
+	mux.HandleFunc("GET /events", h.GetEvents)
+	mux.HandleFunc("POST /events/{id}/register", h.RegisterForEvent)
 
 	log.Println("Server starting on :8080...")
 	if err := http.ListenAndServe(":8080", mux); err != nil {
@@ -15,12 +15,13 @@ import (
 
 // TestConcurrentRegistration simulates 100 users trying to register for an event with only 5 spots.
 func TestConcurrentRegistration(t *testing.T) {
-	// Initialize a temporary in-memory database for testing
-	err := db.InitDB("file::memory:?cache=shared")
+	// Initialize a temporary in-memory database of this test's own, so nothing is shared
+	// with other tests through the cache
+	store, err := db.InitDB("file:" + t.Name() + "?mode=memory&cache=shared")
 	if err != nil {
 		t.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer db.DB.Close()
+	defer store.Close()
 
 	// 1. Create an event with exactly 5 capacity
 	event := models.Event{
@@ -31,7 +32,7 @@ func TestConcurrentRegistration(t *testing.T) {
 		Date:           time.Now().Add(48 * time.Hour),
 	}
 
-	eventID, err := db.CreateEvent(event)
+	eventID, err := store.CreateEvent(event)
 	if err != nil {
 		t.Fatalf("Failed to create test event: %v", err)
 	}
@@ -59,7 +60,7 @@ func TestConcurrentRegistration(t *testing.T) {
 			}
 
 			// Core test: Call the RegisterUser function which contains our atomic DB update
-			err := db.RegisterUser(reg)
+			_, err := store.RegisterUser(reg)
 
 			mu.Lock()
 			if err == nil {
@@ -88,7 +89,7 @@ func TestConcurrentRegistration(t *testing.T) {
 
 	// Verify the database state
 	var availableSpots int
-	err = db.DB.QueryRow("SELECT available_spots FROM events WHERE id = ?", eventID).Scan(&availableSpots)
+	err = store.DB.QueryRow("SELECT available_spots FROM events WHERE id = ?", eventID).Scan(&availableSpots)
 	if err != nil {
 		t.Fatalf("Failed to query event: %v", err)
 	}
@@ -99,7 +100,7 @@ func TestConcurrentRegistration(t *testing.T) {
 
 	// Verify exactly 5 registrations were inserted
 	var registrationCount int
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM registrations WHERE event_id = ?", eventID).Scan(&registrationCount)
+	err = store.DB.QueryRow("SELECT COUNT(*) FROM registrations WHERE event_id = ?", eventID).Scan(&registrationCount)
 	if err != nil && err != sql.ErrNoRows {
 		t.Fatalf("Failed to count registrations: %v", err)
 	}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"event-api/db"
+	"event-api/handlers"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCreateEventRejectsOverlongText checks that oversized event text is refused and never stored.
+func TestCreateEventRejectsOverlongText(t *testing.T) {
+	store, err := db.InitDB("file:" + filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer store.Close()
+	h := &handlers.Handlers{Store: store, MaxDescriptionLength: 100}
+
+	create := func(title, description string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"title":%q,"description":%q,"capacity":10,"date":"2030-01-01T10:00:00Z"}`, title, description)
+		rec := httptest.NewRecorder()
+		h.CreateEvent(rec, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := create("Go Meetup", strings.Repeat("x", 101))
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "description") {
+		t.Errorf("Expected 422 naming description, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = create(strings.Repeat("t", handlers.DefaultMaxTitleLength+1), "")
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "title") {
+		t.Errorf("Expected 422 naming title, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A body too big to hold text within the limits is refused before it is decoded
+	rec = create("Go Meetup", strings.Repeat("x", (handlers.DefaultMaxTitleLength+100)*12+2048))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized body, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Limits count characters, not bytes
+	if rec := create("Go Meetup", strings.Repeat("é", 100)); rec.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for a description at the limit, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var count int
+	if err := store.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&count); err != nil {
+		t.Fatalf("Failed to count events: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected only the valid event to be stored, got %d", count)
+	}
+}
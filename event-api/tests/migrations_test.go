@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"database/sql"
+	"event-api/db"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// TestInitDBMigratesExistingDatabase starts from a database created before schema_migrations
+// existed and checks that InitDB adopts it without losing data, and is idempotent.
+func TestInitDBMigratesExistingDatabase(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "legacy.db")
+
+	legacy, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	_, err = legacy.Exec(`
+	CREATE TABLE events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		description TEXT,
+		capacity INTEGER NOT NULL,
+		available_spots INTEGER NOT NULL,
+		date DATETIME NOT NULL
+	);
+	INSERT INTO events(title, description, capacity, available_spots, date) VALUES('Legacy Meetup', '', 10, 7, '2030-01-01 18:00:00');`)
+	legacy.Close()
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	var store *db.Store
+	for i := 0; i < 2; i++ {
+		if store, err = db.InitDB(dsn); err != nil {
+			t.Fatalf("InitDB run %d failed: %v", i+1, err)
+		}
+		if i == 0 {
+			store.Close()
+		}
+	}
+	defer store.Close()
+
+	var versions int
+	if err := store.DB.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&versions); err != nil {
+		t.Fatalf("Failed to read schema_migrations: %v", err)
+	}
+	if versions != 1 {
+		t.Errorf("Expected 1 recorded migration, got %d", versions)
+	}
+
+	events, err := store.GetEvents()
+	if err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	if len(events) != 1 || events[0].Title != "Legacy Meetup" || events[0].AvailableSpots != 7 {
+		t.Errorf("Expected the legacy event to survive, got %+v", events)
+	}
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"event-api/db"
+	"event-api/handlers"
+	"event-api/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRegisterReturnsIDAndDate checks that a registration response identifies the stored row.
+func TestRegisterReturnsIDAndDate(t *testing.T) {
+	store, err := db.InitDB("file:" + filepath.Join(t.TempDir(), "register.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer store.Close()
+	h := &handlers.Handlers{Store: store}
+
+	eventID, err := store.CreateEvent(models.Event{Title: "Go Meetup", Capacity: 3, AvailableSpots: 3, Date: time.Now().Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/events/%d/register", eventID), strings.NewReader(`{"user_name":"Ada","user_email":"ada@example.com"}`))
+	req.SetPathValue("id", fmt.Sprint(eventID))
+	rec := httptest.NewRecorder()
+	before := time.Now().UTC().Truncate(time.Second)
+	h.RegisterForEvent(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Message        string    `json:"message"`
+		ID             int       `json:"id"`
+		RegisteredDate time.Time `json:"registered_date"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.RegisteredDate.Before(before) {
+		t.Errorf("Expected registered_date at or after %v, got %v", before, body.RegisteredDate)
+	}
+
+	var email, stored string
+	if err := store.DB.QueryRow("SELECT user_email, CAST(registered_date AS TEXT) FROM registrations WHERE id = ? AND event_id = ?", body.ID, eventID).Scan(&email, &stored); err != nil {
+		t.Fatalf("Expected registration %d to exist: %v", body.ID, err)
+	}
+	if email != "ada@example.com" {
+		t.Errorf("Expected the row for ada@example.com, got %q", email)
+	}
+	// Stored in the same layout as the column's CURRENT_TIMESTAMP default
+	if want := body.RegisteredDate.UTC().Format("2006-01-02 15:04:05"); stored != want {
+		t.Errorf("Expected registered_date stored as %q, got %q", want, stored)
+	}
+}
+
+// TestRegisterDistinguishesSoldOutFromMissing checks both sentinel errors and the status
+// codes they map to.
+func TestRegisterDistinguishesSoldOutFromMissing(t *testing.T) {
+	store, err := db.InitDB("file:" + filepath.Join(t.TempDir(), "sentinels.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer store.Close()
+	h := &handlers.Handlers{Store: store}
+
+	eventID, err := store.CreateEvent(models.Event{Title: "Tiny Workshop", Capacity: 1, AvailableSpots: 1, Date: time.Now().Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+	if _, err := store.RegisterUser(models.Registration{EventID: int(eventID), UserName: "First", UserEmail: "first@example.com"}); err != nil {
+		t.Fatalf("Failed to take the only spot: %v", err)
+	}
+
+	if _, err := store.RegisterUser(models.Registration{EventID: int(eventID), UserName: "Late", UserEmail: "late@example.com"}); !errors.Is(err, db.ErrSoldOut) {
+		t.Errorf("Expected ErrSoldOut, got %v", err)
+	}
+	if _, err := store.RegisterUser(models.Registration{EventID: 9999, UserName: "Lost", UserEmail: "lost@example.com"}); !errors.Is(err, db.ErrEventNotFound) {
+		t.Errorf("Expected ErrEventNotFound, got %v", err)
+	}
+
+	register := func(id int64) int {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/events/%d/register", id), strings.NewReader(`{"user_name":"Bo","user_email":"bo@example.com"}`))
+		req.SetPathValue("id", fmt.Sprint(id))
+		rec := httptest.NewRecorder()
+		h.RegisterForEvent(rec, req)
+		return rec.Code
+	}
+	if code := register(eventID); code != http.StatusConflict {
+		t.Errorf("Expected 409 for a sold-out event, got %d", code)
+	}
+	if code := register(9999); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing event, got %d", code)
+	}
+}
+
+// TestRegisterRejectsBlankNameOrEmail checks that empty and whitespace-only fields are
+// refused without taking a spot, and that emails are stored normalized.
+func TestRegisterRejectsBlankNameOrEmail(t *testing.T) {
+	store, err := db.InitDB("file:" + filepath.Join(t.TempDir(), "blank.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer store.Close()
+	h := &handlers.Handlers{Store: store}
+
+	eventID, err := store.CreateEvent(models.Event{Title: "Strict Meetup", Capacity: 5, AvailableSpots: 5, Date: time.Now().Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+
+	register := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/events/%d/register", eventID), strings.NewReader(body))
+		req.SetPathValue("id", fmt.Sprint(eventID))
+		rec := httptest.NewRecorder()
+		h.RegisterForEvent(rec, req)
+		return rec
+	}
+
+	for _, body := range []string{
+		`{}`,
+		`{"user_name":"","user_email":"ann@example.com"}`,
+		`{"user_name":"Ann","user_email":""}`,
+		`{"user_name":"   ","user_email":"ann@example.com"}`,
+		`{"user_name":"Ann","user_email":" \t "}`,
+	} {
+		if rec := register(body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, rec.Code)
+		}
+	}
+
+	var available, rows int
+	if err := store.DB.QueryRow("SELECT available_spots, (SELECT COUNT(*) FROM registrations) FROM events WHERE id = ?", eventID).Scan(&available, &rows); err != nil {
+		t.Fatalf("Failed to query event: %v", err)
+	}
+	if available != 5 || rows != 0 {
+		t.Errorf("Expected rejected requests to leave 5 spots and no rows, got %d spots and %d rows", available, rows)
+	}
+
+	if rec := register(`{"user_name":"  Ann ","user_email":"  Ann@Example.COM "}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var name, email string
+	if err := store.DB.QueryRow("SELECT user_name, user_email FROM registrations WHERE event_id = ?", eventID).Scan(&name, &email); err != nil {
+		t.Fatalf("Failed to load registration: %v", err)
+	}
+	if name != "Ann" || email != "ann@example.com" {
+		t.Errorf("Expected the trimmed name and normalized email, got %q and %q", name, email)
+	}
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"event-api/db"
+	"event-api/models"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestStoresAreIsolated runs registrations against two stores at once and checks that
+// neither sees the other's events or spots.
+func TestStoresAreIsolated(t *testing.T) {
+	stores := make([]*db.Store, 2)
+	for i := range stores {
+		store, err := db.InitDB(fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", t.Name(), i))
+		if err != nil {
+			t.Fatalf("Failed to initialize store %d: %v", i, err)
+		}
+		defer store.Close()
+		stores[i] = store
+	}
+
+	capacities := []int{3, 7}
+	var wg sync.WaitGroup
+	successes := make([]int, len(stores))
+	for i, store := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			eventID, err := store.CreateEvent(models.Event{Title: fmt.Sprintf("Store %d Event", i), Capacity: capacities[i], Date: time.Now().Add(24 * time.Hour)})
+			if err != nil {
+				t.Errorf("Store %d: failed to create event: %v", i, err)
+				return
+			}
+			for u := 0; u < 10; u++ {
+				if _, err := store.RegisterUser(models.Registration{EventID: int(eventID), UserName: "User", UserEmail: fmt.Sprintf("user%d@example.com", u)}); err == nil {
+					successes[i]++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, store := range stores {
+		if successes[i] != capacities[i] {
+			t.Errorf("Store %d: expected %d registrations, got %d", i, capacities[i], successes[i])
+		}
+		events, err := store.GetEvents()
+		if err != nil {
+			t.Fatalf("Store %d: failed to list events: %v", i, err)
+		}
+		if len(events) != 1 || events[0].Title != fmt.Sprintf("Store %d Event", i) || events[0].AvailableSpots != 0 {
+			t.Errorf("Store %d: expected only its own sold-out event, got %+v", i, events)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// trickyBodies are seed inputs known to stress JSON decoding.
+var trickyBodies = []string{
+	``,
+	`null`,
+	`[]`,
+	`{"name":"x","total_spots":99999999999999999999999999}`,
+	`{"name":"x","total_spots":-9223372036854775808}`,
+	`{"name":"x","total_spots":1e308}`,
+	`{"name":"x","total_spots":"5"}`,
+	`{"name":"\u0000","total_spots":1}`,
+	`{"name":"x","total_spots":1,"starts_at":"0000-00-00T00:00:00Z"}`,
+	`{"name":"x","total_spots":1}{"name":"y","total_spots":1}`,
+	`{"email":"a@b.c","idempotency_key":"k"}`,
+	`{"email":["a"],"idempotency_key":{}}`,
+	strings.Repeat("[", 100000) + strings.Repeat("]", 100000),
+	`{"a":` + strings.Repeat(`{"a":`, 20000) + `1` + strings.Repeat("}", 20001),
+}
+
+func acceptableStatus(code int, allowed ...int) bool {
+	for _, c := range allowed {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+func FuzzHandleCreateEvent(f *testing.F) {
+	for _, body := range trickyBodies {
+		f.Add([]byte(body))
+	}
+	f.Add([]byte(`{"name":"GopherCon","total_spots":10,"starts_at":"2026-12-01T10:00:00Z","status":"draft"}`))
+
+	h := &Handlers{DB: NewTestDB(f)}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.HandleCreateEvent(rec, req)
+
+		if !acceptableStatus(rec.Code, http.StatusCreated, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity) {
+			t.Fatalf("Unexpected status %d for body %q: %s", rec.Code, body, rec.Body.String())
+		}
+	})
+}
+
+func FuzzHandleRegister(f *testing.F) {
+	for _, body := range trickyBodies {
+		f.Add([]byte(body))
+	}
+
+	db := NewTestDB(f)
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Fuzz Fest", TotalSpots: MaxTotalSpots})
+	if err != nil {
+		f.Fatalf("Failed to create event: %v", err)
+	}
+	h := &Handlers{DB: db}
+	id := strconv.FormatInt(evt.ID, 10)
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/events/"+id+"/register", bytes.NewReader(body))
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+
+		if !acceptableStatus(rec.Code, http.StatusCreated, http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity) {
+			t.Fatalf("Unexpected status %d for body %q: %s", rec.Code, body, rec.Body.String())
+		}
+	})
+}
@@ -1,48 +1,328 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
 	"net/http"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type Handlers struct {
 	DB *DB
+
+	// MinCapacity and MaxCapacity bound total_spots on new events; 0 disables a bound.
+	MinCapacity int
+	MaxCapacity int
+
+	// MaxEventsPerOrganizer caps how many events one organizer may own; 0 disables it.
+	// Admins are exempt.
+	MaxEventsPerOrganizer int
+
+	// MaxTicketQuantity caps the spots one registration may request; 0 uses
+	// defaultMaxTicketQuantity.
+	MaxTicketQuantity int
+
+	// RejectDuplicateNames refuses a new event whose name matches one of the same
+	// organizer's live events, ignoring case and surrounding whitespace.
+	RejectDuplicateNames bool
+
+	// RejectOrganizerSelfRegistration refuses tickets for an event to its own organizer's
+	// email, so organizers cannot pad their attendance.
+	RejectOrganizerSelfRegistration bool
+
+	// RequireConfirmNonce issues each reservation a one-time nonce that
+	// POST /tickets/{id}/confirm must present, so a captured confirm request cannot be replayed.
+	RequireConfirmNonce bool
+
+	// HoldExtension, when positive, slides a reserved ticket's expiry forward each time its
+	// owner polls GET /tickets/{id}, like a session; MaxHold caps the total hold measured
+	// from when the reservation was made.
+	HoldExtension time.Duration
+	MaxHold       time.Duration
+
+	// PaymentGrace is the minimum hold left on a paid reservation once its payment is
+	// reported pending; 0 uses defaultPaymentGrace.
+	PaymentGrace time.Duration
+
+	// TicketRetention is how old a cancelled ticket must be before POST /admin/purge
+	// deletes it when no older_than is given; 0 uses defaultTicketRetention.
+	TicketRetention time.Duration
+
+	// EventCache lets GET /events serve a recent response while the database is failing;
+	// Routes creates one with defaultEventListCacheTTL if unset.
+	EventCache *EventListCache
+
+	// Stats serves GET /events/{id}/stats; Routes creates one with defaultStatsMaxAge if unset.
+	Stats *StatsAggregator
+
+	// Reclaim is updated by the reclaim worker and served by GET /admin/reclaim/status;
+	// nil reports that no pass has run.
+	Reclaim *ReclaimStatus
+
+	// LowAvailabilityPercent is the share of spots left, in percent, below which stats
+	// report low_availability; 0 uses defaultLowAvailabilityPercent.
+	LowAvailabilityPercent float64
+
+	// Email delivers confirmation codes; nil falls back to LogEmailSender.
+	Email EmailSender
+
+	// EmailTimeout is how long a request or worker waits for one email to be handed
+	// over before giving up on it; 0 uses defaultEmailTimeout.
+	EmailTimeout time.Duration
+
+	// BaseCurrency prices events created without a currency; empty means USD.
+	BaseCurrency string
+
+	// JWTSecret verifies HS256 bearer tokens; empty disables JWT authentication.
+	JWTSecret []byte
+
+	// APIKeys authenticate server-to-server callers by X-API-Key; empty disables them.
+	APIKeys []APIKey
+
+	// AllowHeaderRole trusts the forgeable X-Role / X-User-Email headers on requests
+	// without verified JWT claims. Off, the default, roles come only from tokens and API
+	// keys; main sets it only with -allow-header-role.
+	AllowHeaderRole bool
+
+	// CORS configures cross-origin browser access; the zero value disables it.
+	CORS CORSConfig
+
+	// HTTPS sets HSTS and optionally redirects plain-HTTP requests; the zero value disables it.
+	HTTPS HTTPSConfig
+
+	// EnablePprof mounts the admin-only /debug/pprof/ endpoints
+	EnablePprof bool
+
+	draining atomic.Bool
 }
 
-// SendJSON is a helper for sending JSON responses
+// SendJSON is a helper for sending JSON responses. Error bodies, maps with an "error" key
+// sent with a 4xx or 5xx status, also get a retryable flag derived from the status; a
+// map[string]interface{} body that already sets "retryable" keeps its own value.
 func SendJSON(w http.ResponseWriter, status int, data interface{}) {
+	if status >= http.StatusBadRequest {
+		data = withRetryable(status, data)
+	}
+
+	// Encode up front so a marshalling failure can still become a clean 500
+	body, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("failed to encode response", "error", err)
+		status = http.StatusInternalServerError
+		body = []byte(`{"error":"Failed to encode response","retryable":false}`)
+	}
+
+	// A second WriteHeader would only trigger a superfluous-call warning and corrupt the body
+	if tw, ok := w.(interface{ WroteHeader() bool }); ok && tw.WroteHeader() {
+		slog.Warn("response already started, dropping JSON body", "status", status)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		// Log error in real app, but for now we just return
-		http.Error(w, `{"error": "Failed to encode response"}`, http.StatusInternalServerError)
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		// Headers are gone; all that is left to do is note it, typically a client disconnect
+		slog.Debug("failed to write response body", "error", err)
+	}
+}
+
+// isRetryableStatus reports whether a request that failed with status may succeed if sent
+// again unchanged: the server was busy, timed out or is draining, or the caller was rate
+// limited. Validation failures, conflicts such as sold-out, and plain 500s are final.
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// withRetryable returns data with "retryable" added when it is an error body that does not
+// set it already, copying the map so callers' values are never modified.
+func withRetryable(status int, data interface{}) interface{} {
+	var out map[string]interface{}
+	switch body := data.(type) {
+	case map[string]string:
+		if _, ok := body["error"]; !ok {
+			return data
+		}
+		out = make(map[string]interface{}, len(body)+1)
+		for k, v := range body {
+			out[k] = v
+		}
+	case map[string]interface{}:
+		if _, ok := body["error"]; !ok {
+			return data
+		}
+		if _, set := body["retryable"]; set {
+			return data
+		}
+		out = maps.Clone(body)
+	default:
+		return data
+	}
+	out["retryable"] = isRetryableStatus(status)
+	return out
+}
+
+// maxBodyBytes caps JSON request bodies; nothing this API accepts comes close to it.
+const maxBodyBytes = 1 << 20
+
+// decodeJSON decodes a size-limited JSON request body into dst. Any trailing data after
+// the first JSON value is rejected so that concatenated payloads are not half-accepted.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after JSON body")
+	}
+	return nil
+}
+
+// sendDecodeError reports a body that failed decodeJSON, distinguishing oversized bodies.
+func sendDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		SendJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "Request body too large"})
+		return
 	}
+	SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON body"})
 }
 
 // Request/Response DTOs
 type CreateEventRequest struct {
-	Name       string `json:"name"`
-	TotalSpots int    `json:"total_spots"`
+	Name        string     `json:"name"`
+	TotalSpots  int        `json:"total_spots"`
+	StartsAt    *time.Time `json:"starts_at"`
+	Status      string     `json:"status"`
+	AutoConfirm bool       `json:"auto_confirm"`
+	// RegistrationOpensAt and RegistrationClosesAt optionally bound the registration window
+	RegistrationOpensAt  *time.Time `json:"registration_opens_at"`
+	RegistrationClosesAt *time.Time `json:"registration_closes_at"`
+	// HoldSeconds optionally overrides how long reservations are held before they lapse
+	HoldSeconds *int `json:"hold_seconds"`
+	// PriceCents is the price of one spot in minor currency units; omitted means free
+	PriceCents int `json:"price_cents"`
+	// Currency is the ISO 4217 code of price_cents; omitted means -base-currency
+	Currency string `json:"currency"`
+	// Tags are category labels; they are lowercased and deduplicated
+	Tags []string `json:"tags"`
+	// Seats, when given, makes this a reserved-seating event with one seat per label
+	Seats []string `json:"seats"`
+	// ExternalID makes creation idempotent: re-posting it returns the organizer's existing event
+	ExternalID string `json:"external_id"`
+	// MaxWaitlist optionally overrides -max-waitlist for this event
+	MaxWaitlist *int `json:"max_waitlist"`
+}
+
+// maxExternalIDLength bounds organizer supplied external ids
+const maxExternalIDLength = 128
+
+// maxIdempotencyKeyLength bounds client supplied idempotency keys
+const maxIdempotencyKeyLength = 64
+
+// idempotencyKeyPattern keeps keys to URL-safe tokens, which covers UUIDs, so the UNIQUE
+// index on tickets.idempotency_key (global or per event) only ever holds short,
+// predictable values.
+var idempotencyKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// defaultMaxTicketQuantity is the per-registration spot cap when none is configured.
+const defaultMaxTicketQuantity = 10
+
+func (h *Handlers) maxTicketQuantity() int {
+	if h.MaxTicketQuantity > 0 {
+		return h.MaxTicketQuantity
+	}
+	return defaultMaxTicketQuantity
+}
+
+// registeredTicket is the ticket as returned to the caller who just registered, the one
+// response that includes the confirmation code. Replays leave it out.
+type registeredTicket struct {
+	*Ticket
+	ConfirmationCode string `json:"confirmation_code,omitempty"`
 }
 
 type RegisterRequest struct {
 	Email          string `json:"email"`
 	IdempotencyKey string `json:"idempotency_key"`
+	SeatLabel      string `json:"seat_label"`
+	Quantity       *int   `json:"quantity"`
+	Mode           string `json:"mode"`
+	// Guest registers without an email; the response carries a claim_token instead
+	Guest bool `json:"guest"`
+	// Metadata is an optional JSON object of attendee notes, at most maxMetadataBytes
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// maxMetadataBytes bounds the compacted JSON stored as a ticket's metadata
+const maxMetadataBytes = 2048
+
+var errInvalidMetadata = fmt.Errorf("metadata must be a JSON object of at most %d bytes", maxMetadataBytes)
+
+// compactMetadata validates attendee metadata and returns it compacted for storage. An
+// absent or null value yields nil.
+func compactMetadata(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, errInvalidMetadata
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil || buf.Len() > maxMetadataBytes {
+		return nil, errInvalidMetadata
+	}
+	return buf.Bytes(), nil
+}
+
+// newToken returns an unguessable random token, such as a guest ticket's claim token or a
+// confirm nonce.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
+// Registration modes: all-or-nothing (the default) or best-effort partial fills.
+const (
+	RegisterModeAll     = "all"
+	RegisterModePartial = "partial"
+)
+
 // HandleCreateEvent handles POST /events
 func (h *Handlers) HandleCreateEvent(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		SendJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
+	var req CreateEventRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		sendDecodeError(w, err)
 		return
 	}
 
-	var req CreateEventRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON body"})
-		return
+	if len(req.Seats) > 0 {
+		if req.TotalSpots == 0 {
+			req.TotalSpots = len(req.Seats)
+		}
+		if err := validateSeatLabels(req.Seats, req.TotalSpots); err != nil {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
 	}
 
 	if req.Name == "" || req.TotalSpots <= 0 {
@@ -50,124 +330,1123 @@ func (h *Handlers) HandleCreateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	evt, err := h.DB.CreateEvent(r.Context(), req.Name, req.TotalSpots)
+	if err := h.checkCapacityBounds(req.TotalSpots); err != nil {
+		SendJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Status != "" && req.Status != EventStatusDraft && req.Status != EventStatusPublished {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "status must be 'draft' or 'published'"})
+		return
+	}
+
+	// A re-posted external_id is a sync retry: answer with the event it already created,
+	// before any check that would treat it as a new event
+	if len(req.ExternalID) > maxExternalIDLength {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("external_id must be at most %d characters", maxExternalIDLength)})
+		return
+	}
+	if req.ExternalID != "" && h.sendExistingExternalEvent(w, r, req.ExternalID) {
+		return
+	}
+
+	if !h.enforceEventQuota(w, r, 1) {
+		return
+	}
+
+	if req.RegistrationOpensAt != nil && req.RegistrationClosesAt != nil && !req.RegistrationClosesAt.After(*req.RegistrationOpensAt) {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "registration_closes_at must be after registration_opens_at"})
+		return
+	}
+
+	if h.RejectDuplicateNames {
+		existing, err := h.DB.FindEventByName(r.Context(), EmailFromContext(r.Context()), req.Name)
+		if err == nil {
+			SendJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":             "You already have an event with this name",
+				"existing_event_id": existing.ID,
+			})
+			return
+		}
+		if !errors.Is(err, ErrEventNotFound) {
+			SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.PriceCents < 0 {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "price_cents must not be negative"})
+		return
+	}
+
+	tags, err := normalizeTags(req.Tags)
 	if err != nil {
-		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	SendJSON(w, http.StatusCreated, evt)
-}
+	currency := h.baseCurrency()
+	if req.Currency != "" {
+		parsed, err := ParseCurrency(req.Currency)
+		if err != nil {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		currency = parsed
+	}
 
-// HandleListEvents handles GET /events
-func (h *Handlers) HandleListEvents(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		SendJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
+	if req.HoldSeconds != nil && (*req.HoldSeconds <= 0 || *req.HoldSeconds > MaxHoldSeconds) {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("hold_seconds must be a positive integer of at most %d (30 days)", MaxHoldSeconds)})
+		return
+	}
+	if req.MaxWaitlist != nil && *req.MaxWaitlist <= 0 {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "max_waitlist must be a positive integer"})
 		return
 	}
 
-	events, err := h.DB.ListEvents(r.Context())
+	evt, err := h.DB.CreateEvent(r.Context(), Event{
+		Name:           req.Name,
+		TotalSpots:     req.TotalSpots,
+		StartsAt:       req.StartsAt,
+		Status:         req.Status,
+		OrganizerEmail: EmailFromContext(r.Context()),
+		AutoConfirm:    req.AutoConfirm,
+
+		RegistrationOpensAt:  req.RegistrationOpensAt,
+		RegistrationClosesAt: req.RegistrationClosesAt,
+		HoldSeconds:          req.HoldSeconds,
+		PriceCents:           req.PriceCents,
+		Currency:             currency,
+		Tags:                 tags,
+		ExternalID:           req.ExternalID,
+		MaxWaitlist:          req.MaxWaitlist,
+	}, req.Seats...)
+	// Lost a race with a concurrent post of the same external_id
+	if errors.Is(err, ErrDuplicateExternalID) && h.sendExistingExternalEvent(w, r, req.ExternalID) {
+		return
+	}
 	if err != nil {
 		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	h.EventCache.Invalidate()
 
-	// Returning an empty array instead of null if no events
-	if events == nil {
-		events = []Event{}
+	SendJSON(w, http.StatusCreated, evt)
+}
+
+// sendExistingExternalEvent answers 200 with the caller's event carrying externalID and
+// reports true, or reports false when there is none yet.
+func (h *Handlers) sendExistingExternalEvent(w http.ResponseWriter, r *http.Request, externalID string) bool {
+	existing, err := h.DB.FindEventByExternalID(r.Context(), EmailFromContext(r.Context()), externalID)
+	if errors.Is(err, ErrEventNotFound) {
+		return false
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return true
 	}
+	SendJSON(w, http.StatusOK, existing)
+	return true
+}
 
-	SendJSON(w, http.StatusOK, events)
+// validateSeatLabels checks a seat map supplied on event creation.
+func validateSeatLabels(labels []string, totalSpots int) error {
+	if len(labels) != totalSpots {
+		return fmt.Errorf("total_spots (%d) must match the number of seats (%d)", totalSpots, len(labels))
+	}
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		if label == "" {
+			return errors.New("seat labels must not be empty")
+		}
+		if seen[label] {
+			return fmt.Errorf("duplicate seat label %q", label)
+		}
+		seen[label] = true
+	}
+	return nil
 }
 
-// HandleRegister handles POST /events/{id}/register
-func (h *Handlers) HandleRegister(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		SendJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
-		return
+// maxEventTags bounds how many tags one event may carry.
+const maxEventTags = 10
+
+// tagPattern keeps tags to short lowercase slugs such as "music" or "open-air".
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// normalizeTags lowercases, trims and deduplicates tags, returning them sorted.
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("tag %q must be 1-32 letters, digits or hyphens", tag)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxEventTags {
+		return nil, fmt.Errorf("an event may have at most %d tags", maxEventTags)
 	}
+	slices.Sort(normalized)
+	return normalized, nil
+}
 
-	// Extract {id} manually since we are using Go 1.22's exact match or manual parsing.
-	// Go 1.22 NewServeMux handles wildcard routes: "POST /events/{id}/register"
-	idStr := r.PathValue("id")
-	if idStr == "" {
-		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing event ID"})
-		return
+// checkCapacityBounds enforces the operator-configured -min-capacity / -max-capacity, and
+// MaxTotalSpots regardless of configuration.
+func (h *Handlers) checkCapacityBounds(totalSpots int) error {
+	if totalSpots > MaxTotalSpots {
+		return fmt.Errorf("total_spots %d exceeds the maximum capacity of %d", totalSpots, MaxTotalSpots)
 	}
-	eventID, err := strconv.ParseInt(idStr, 10, 64)
+	if h.MinCapacity > 0 && totalSpots < h.MinCapacity {
+		return fmt.Errorf("total_spots %d is below the minimum capacity of %d", totalSpots, h.MinCapacity)
+	}
+	if h.MaxCapacity > 0 && totalSpots > h.MaxCapacity {
+		return fmt.Errorf("total_spots %d exceeds the maximum capacity of %d", totalSpots, h.MaxCapacity)
+	}
+	return nil
+}
+
+// ListEnvelope wraps one page of a list response with pagination metadata.
+type ListEnvelope struct {
+	Data interface{} `json:"data"`
+	Meta ListMeta    `json:"meta"`
+}
+
+type ListMeta struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	// NextCursor is the after= value for the following page of a cursor-paged listing;
+	// omitted once the listing is exhausted.
+	NextCursor *int64 `json:"next_cursor,omitempty"`
+}
+
+// enforceEventQuota checks that the calling organizer may create another adding events,
+// writing the error response and returning false when they may not. Drafts count towards
+// the quota; cancelled events do not.
+func (h *Handlers) enforceEventQuota(w http.ResponseWriter, r *http.Request, adding int) bool {
+	if h.MaxEventsPerOrganizer <= 0 || RoleFromContext(r.Context()) == "admin" {
+		return true
+	}
+
+	organizer := EmailFromContext(r.Context())
+	if organizer == "" {
+		SendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Missing X-User-Email identity"})
+		return false
+	}
+
+	owned, err := h.DB.CountEvents(r.Context(), EventFilter{OrganizerEmail: organizer, IncludeDrafts: true, ExcludeCancelled: true})
 	if err != nil {
-		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
-		return
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return false
+	}
+	if owned+adding > h.MaxEventsPerOrganizer {
+		SendJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("Organizers may own at most %d events (you have %d)", h.MaxEventsPerOrganizer, owned)})
+		return false
 	}
+	return true
+}
 
-	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON body"})
+// HandleListEvents handles GET /events. The response is a bare array unless the client
+// opts into ?envelope=true, which pages the list and adds ListMeta.
+func (h *Handlers) HandleListEvents(w http.ResponseWriter, r *http.Request) {
+	if ids := r.URL.Query().Get("ids"); ids != "" {
+		h.listEventsByID(w, r, ids)
 		return
 	}
 
-	if req.Email == "" || req.IdempotencyKey == "" {
-		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email and idempotency_key are required"})
-		return
+	envelope := false
+	if v := r.URL.Query().Get("envelope"); v != "" {
+		var err error
+		if envelope, err = strconv.ParseBool(v); err != nil {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": "envelope must be true or false"})
+			return
+		}
 	}
 
-	ticketID, err := h.DB.RegisterForEvent(r.Context(), eventID, req.Email, req.IdempotencyKey)
+	var filter EventFilter
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "", EventSortID, EventSortCreatedAt:
+		filter.Sort = sort
+	default:
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "sort must be 'id' or 'created_at'"})
+		return
+	}
+	filter.Tag = strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag")))
+	from, to, err := parseStartRange(r)
 	if err != nil {
-		if errors.Is(err, ErrSoldOut) {
-			SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	filter.From, filter.To = from, to
+	if envelope {
+		limit, offset, err := parsePagination(r)
+		if err != nil {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		if errors.Is(err, ErrAlreadyRegistered) {
-			SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		filter.Limit, filter.Offset = limit, offset
+
+		if v := r.URL.Query().Get("after"); v != "" {
+			after, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || after < 1 {
+				SendJSON(w, http.StatusBadRequest, map[string]string{"error": "after must be an event id"})
+				return
+			}
+			if offset != 0 {
+				SendJSON(w, http.StatusBadRequest, map[string]string{"error": "after and offset cannot be combined"})
+				return
+			}
+			filter.AfterID = after
+		}
+	}
+
+	// HEAD /events answers with just the count, for monitors and paginators
+	if r.Method == http.MethodHead {
+		total, err := h.DB.CountEvents(r.Context(), filter)
+		if err != nil {
+			SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during registration"})
+	cacheKey := fmt.Sprintf("%t|%+v", envelope, filter)
+	body, total, err := h.listEvents(r, filter, envelope)
+	if err != nil {
+		// A brief outage is better answered with the last good list than with a 500
+		if cached, ok := h.EventCache.Get(cacheKey); ok {
+			slog.WarnContext(r.Context(), "serving stale event list", "error", err)
+			w.Header().Set("X-Cache", "stale")
+			SendJSON(w, http.StatusOK, cached)
+			return
+		}
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	SendJSON(w, http.StatusCreated, map[string]interface{}{
-		"message":   "Seat reserved! Please confirm within 5 minutes.",
-		"ticket_id": ticketID,
-	})
+	h.EventCache.Put(cacheKey, body)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	SendJSON(w, http.StatusOK, body)
 }
 
-// HandleConfirm handles POST /tickets/{id}/confirm
-func (h *Handlers) HandleConfirm(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		SendJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
-		return
+// listEvents builds the GET /events response body, the bare array or a ListEnvelope, and
+// returns it with the number of events matching filter across all pages.
+func (h *Handlers) listEvents(r *http.Request, filter EventFilter, envelope bool) (any, int, error) {
+	events, err := h.DB.ListEvents(r.Context(), filter)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	idStr := r.PathValue("id")
-	if idStr == "" {
-		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing ticket ID"})
+	// Returning an empty array instead of null if no events
+	if events == nil {
+		events = []Event{}
+	}
+
+	total, err := h.DB.CountEvents(r.Context(), filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !envelope {
+		return events, total, nil
+	}
+
+	meta := ListMeta{Total: total, Limit: filter.Limit, Offset: filter.Offset}
+	// Cursor paging starts from a plain first page; a full page may have a successor
+	if filter.Offset == 0 && len(events) == filter.Limit {
+		next := events[len(events)-1].ID
+		meta.NextCursor = &next
+	}
+	return ListEnvelope{Data: events, Meta: meta}, total, nil
+}
+
+// listEventsByID answers GET /events?ids=1,2,3 with those events in the order given,
+// leaving out ids that do not exist. Other list parameters do not apply.
+func (h *Handlers) listEventsByID(w http.ResponseWriter, r *http.Request, raw string) {
+	ids, err := parseEventIDs(raw)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	ticketID, err := strconv.ParseInt(idStr, 10, 64)
+
+	events, err := h.DB.GetEventsByIDs(r.Context(), ids)
 	if err != nil {
-		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ticket ID format"})
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	SendJSON(w, http.StatusOK, events)
+}
 
-	var req struct {
-		Email string `json:"email"`
+// Pagination defaults for list endpoints
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// parsePagination reads ?limit= and ?offset= with sane defaults and bounds.
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// parseStartRange reads the optional ?from= and ?to= RFC3339 bounds on event start times.
+func parseStartRange(r *http.Request) (from, to time.Time, err error) {
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		v := r.URL.Query().Get(bound.name)
+		if v == "" {
+			continue
+		}
+		if *bound.dst, err = time.Parse(time.RFC3339, v); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%s must be an RFC3339 timestamp", bound.name)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
+// HandleListOrganizerEvents handles GET /organizer/events
+func (h *Handlers) HandleListOrganizerEvents(w http.ResponseWriter, r *http.Request) {
+	organizer := EmailFromContext(r.Context())
+	if other := r.URL.Query().Get("organizer"); other != "" {
+		if RoleFromContext(r.Context()) != "admin" {
+			SendJSON(w, http.StatusForbidden, map[string]string{"error": "Only admins may view another organizer's events"})
+			return
+		}
+		organizer = other
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON body"})
+	if organizer == "" {
+		SendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Missing X-User-Email identity"})
 		return
 	}
 
-	if req.Email == "" {
-		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email is required to confirm"})
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	err = h.DB.ConfirmReservation(r.Context(), ticketID, req.Email)
+	events, err := h.DB.ListEvents(r.Context(), EventFilter{OrganizerEmail: organizer, Limit: limit, Offset: offset, IncludeDrafts: true})
 	if err != nil {
-		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	if events == nil {
+		events = []Event{}
+	}
 
-	SendJSON(w, http.StatusOK, map[string]string{"message": "Ticket successfully confirmed"})
+	SendJSON(w, http.StatusOK, events)
+}
+
+// HandleEventICal handles GET /events/{id}/ical
+func (h *Handlers) HandleEventICal(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
+		return
+	}
+
+	evt, err := h.DB.GetEvent(r.Context(), eventID)
+	if err != nil {
+		if errors.Is(err, ErrEventNotFound) {
+			SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Drafts and cancelled events are not something attendees should be adding to calendars
+	if evt.Status != EventStatusPublished {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": ErrEventNotFound.Error()})
+		return
+	}
+	if evt.StartsAt == nil {
+		SendJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Event has no start time"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d.ics"`, evt.ID))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(RenderICal(evt, h.DB.Clock.Now())))
+}
+
+// HandleListSeats handles GET /events/{id}/seats
+func (h *Handlers) HandleListSeats(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
+		return
+	}
+
+	seats, err := h.DB.ListSeats(r.Context(), eventID)
+	if err != nil {
+		if errors.Is(err, ErrEventNotFound) {
+			SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	available := 0
+	for _, seat := range seats {
+		if seat.Status == SeatAvailable {
+			available++
+		}
+	}
+
+	SendJSON(w, http.StatusOK, map[string]interface{}{
+		"event_id":  eventID,
+		"total":     len(seats),
+		"available": available,
+		"seats":     seats,
+	})
+}
+
+// HandleRegister handles POST /events/{id}/register
+func (h *Handlers) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	// Extract {id} manually since we are using Go 1.22's exact match or manual parsing.
+	// Go 1.22 NewServeMux handles wildcard routes: "POST /events/{id}/register"
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing event ID"})
+		return
+	}
+	eventID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
+		return
+	}
+
+	var req RegisterRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		sendDecodeError(w, err)
+		return
+	}
+
+	// The standard Idempotency-Key header takes precedence over the legacy body field
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		req.IdempotencyKey = key
+	}
+
+	if req.Guest && req.Email != "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Guest registrations must not include an email"})
+		return
+	}
+	if (req.Email == "" && !req.Guest) || req.IdempotencyKey == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email (or guest: true) and an Idempotency-Key header or idempotency_key are required"})
+		return
+	}
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLength {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Idempotency key must be at most %d characters", maxIdempotencyKeyLength)})
+		return
+	}
+	if !idempotencyKeyPattern.MatchString(req.IdempotencyKey) {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Idempotency key may only contain letters, digits, '-' and '_' (a UUID works)"})
+		return
+	}
+
+	quantity := 1
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+	if maxQuantity := h.maxTicketQuantity(); quantity < 1 || quantity > maxQuantity {
+		SendJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": fmt.Sprintf("Quantity must be between 1 and %d", maxQuantity)})
+		return
+	}
+	if req.Mode != "" && req.Mode != RegisterModeAll && req.Mode != RegisterModePartial {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Mode must be 'all' or 'partial'"})
+		return
+	}
+	if req.SeatLabel != "" && quantity > 1 {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "seat_label can only be used with a quantity of 1"})
+		return
+	}
+	metadata, err := compactMetadata(req.Metadata)
+	if err != nil {
+		SendJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if !req.Guest && h.rejectOrganizerSelfRegistration(w, r, eventID, req.Email) {
+		return
+	}
+
+	var claimToken, confirmNonce string
+	if req.Guest {
+		if claimToken, err = newToken(); err != nil {
+			SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during registration"})
+			return
+		}
+	}
+	if h.RequireConfirmNonce {
+		if confirmNonce, err = newToken(); err != nil {
+			SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during registration"})
+			return
+		}
+	}
+
+	ticket, err := h.DB.RegisterForEvent(r.Context(), RegisterParams{
+		EventID:        eventID,
+		Email:          req.Email,
+		ClaimToken:     claimToken,
+		IdempotencyKey: req.IdempotencyKey,
+		SeatLabel:      req.SeatLabel,
+		Quantity:       quantity,
+		Partial:        req.Mode == RegisterModePartial,
+		Metadata:       metadata,
+		ConfirmNonce:   confirmNonce,
+	})
+
+	// A retried request gets the ticket its key already produced rather than a 409, as
+	// long as it is for the same attendee and still holds its spots. Guests cannot be told
+	// apart, and a cancelled or lapsed ticket holds nothing, so those still conflict.
+	replayed := false
+	if errors.Is(err, ErrAlreadyRegistered) && !req.Guest {
+		if prior, lookupErr := h.DB.FindTicketByIdempotencyKey(r.Context(), eventID, req.IdempotencyKey); lookupErr == nil && strings.EqualFold(prior.UserEmail, req.Email) && h.ticketHolds(prior) {
+			ticket, err, replayed = prior, nil, true
+			w.Header().Set("Idempotency-Replayed", "true")
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidQuantity) {
+			SendJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrEventNotFound) || errors.Is(err, ErrSeatNotFound) {
+			SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrEventDraft) {
+			h.sendEventDraft(w, r, eventID)
+			return
+		}
+		if errors.Is(err, ErrSoldOut) {
+			if r.URL.Query().Get("suggest") == "true" {
+				h.sendSoldOutWithAlternatives(w, r, eventID, err)
+				return
+			}
+			SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrDatabaseBusy) {
+			w.Header().Set("Retry-After", "1")
+			SendJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrRegistrationNotOpen) {
+			SendJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrAlreadyRegistered) || errors.Is(err, ErrSeatTaken) {
+			SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrNoSeatMap) {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during registration"})
+		return
+	}
+
+	message := fmt.Sprintf("Seat reserved! Please confirm by %s.", ticket.ExpiresAt.UTC().Format(time.RFC3339))
+	if ticket.Status == "confirmed" {
+		message = "Registration confirmed! No further action is needed."
+		if !replayed {
+			h.sendConfirmationCode(r.Context(), ticket)
+		}
+	}
+
+	code := ticket.ConfirmationCode
+	if replayed {
+		code = "" // Only the response that created the ticket carries it
+	}
+	resp := map[string]interface{}{
+		"message":   message,
+		"ticket_id": ticket.ID,
+		"ticket":    registeredTicket{Ticket: ticket, ConfirmationCode: code},
+		"granted":   ticket.Quantity,
+		"shortfall": quantity - ticket.Quantity,
+	}
+	if claimToken != "" {
+		// Shown exactly once; it is the only way a guest can confirm the ticket
+		resp["claim_token"] = claimToken
+	}
+	if confirmNonce != "" && ticket.Status == "reserved" {
+		// A replay may be retrying a lost 201, so it gets a fresh nonce; rotating it means
+		// only the latest one shown can confirm the ticket
+		issued := !replayed
+		if replayed {
+			if issued, err = h.DB.RotateConfirmNonce(r.Context(), ticket.ID, confirmNonce); err != nil {
+				SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during registration"})
+				return
+			}
+		}
+		if issued {
+			resp["confirm_nonce"] = confirmNonce
+		}
+	}
+	SendJSON(w, http.StatusCreated, resp)
+}
+
+// ticketHolds reports whether t still holds its spots: confirmed, or reserved with time
+// left on its hold.
+func (h *Handlers) ticketHolds(t *Ticket) bool {
+	return t.Status == "confirmed" || (t.Status == "reserved" && h.DB.Clock.Now().Before(t.ExpiresAt))
+}
+
+// sendEventDraft answers a registration for a draft event. Its organizer, or an admin,
+// gets 409 explaining that the event must be published first; anyone else gets the same
+// 404 as for a missing event, so drafts cannot be discovered by probing ids.
+func (h *Handlers) sendEventDraft(w http.ResponseWriter, r *http.Request, eventID int64) {
+	evt, err := h.DB.GetEvent(r.Context(), eventID)
+	if errors.Is(err, ErrEventNotFound) {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during registration"})
+		return
+	}
+	caller := EmailFromContext(r.Context())
+	if RoleFromContext(r.Context()) == "admin" || (caller != "" && strings.EqualFold(caller, evt.OrganizerEmail)) {
+		SendJSON(w, http.StatusConflict, map[string]string{"error": ErrEventDraft.Error(), "code": "event_draft"})
+		return
+	}
+	SendJSON(w, http.StatusNotFound, map[string]string{"error": ErrEventNotFound.Error()})
+}
+
+// rejectOrganizerSelfRegistration answers 409 and reports true when the
+// RejectOrganizerSelfRegistration rule is on and one of emails is eventID's organizer.
+// A missing event is left for the registration itself to report.
+func (h *Handlers) rejectOrganizerSelfRegistration(w http.ResponseWriter, r *http.Request, eventID int64, emails ...string) bool {
+	if !h.RejectOrganizerSelfRegistration {
+		return false
+	}
+	evt, err := h.DB.GetEvent(r.Context(), eventID)
+	if errors.Is(err, ErrEventNotFound) {
+		return false
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during registration"})
+		return true
+	}
+	for _, email := range emails {
+		if evt.OrganizerEmail != "" && strings.EqualFold(strings.TrimSpace(email), evt.OrganizerEmail) {
+			SendJSON(w, http.StatusConflict, map[string]string{"error": "Organizers cannot register for their own event", "code": "organizer_self_registration"})
+			return true
+		}
+	}
+	return false
+}
+
+// maxSoldOutSuggestions caps the alternatives offered with a sold-out answer.
+const maxSoldOutSuggestions = 3
+
+// sendSoldOutWithAlternatives answers a sold-out registration with up to
+// maxSoldOutSuggestions open events alongside the error. The suggestions are a courtesy:
+// if they cannot be loaded the plain 409 still goes out.
+func (h *Handlers) sendSoldOutWithAlternatives(w http.ResponseWriter, r *http.Request, eventID int64, soldOut error) {
+	alternatives, err := h.DB.SuggestAlternatives(r.Context(), eventID, maxSoldOutSuggestions)
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to suggest alternatives", "event_id", eventID, "error", err)
+		SendJSON(w, http.StatusConflict, map[string]string{"error": soldOut.Error()})
+		return
+	}
+	SendJSON(w, http.StatusConflict, map[string]interface{}{"error": soldOut.Error(), "alternatives": alternatives})
+}
+
+// HandleGetTicket handles GET /tickets/{id}?email=
+func (h *Handlers) HandleGetTicket(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ticket ID format"})
+		return
+	}
+
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email is required to view a ticket"})
+		return
+	}
+
+	ticket, err := h.DB.GetTicket(r.Context(), ticketID)
+	// Someone else's ticket is reported exactly like a missing one
+	if errors.Is(err, ErrTicketNotFound) || (err == nil && ticket.UserEmail != email) {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": ErrTicketNotFound.Error()})
+		return
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Polling a live reservation keeps it alive, up to the MaxHold cap
+	if h.HoldExtension > 0 && ticket.Status == "reserved" && ticket.ExpiresAt.After(h.DB.Clock.Now()) {
+		expiresAt, err := h.DB.ExtendHold(r.Context(), ticket.ID, h.HoldExtension, h.MaxHold)
+		if err != nil {
+			SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		ticket.ExpiresAt = expiresAt
+	}
+
+	SendJSON(w, http.StatusOK, ticket)
+}
+
+// HandleConfirm handles POST /tickets/{id}/confirm
+func (h *Handlers) HandleConfirm(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing ticket ID"})
+		return
+	}
+	ticketID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ticket ID format"})
+		return
+	}
+
+	var req struct {
+		Email      string `json:"email"`
+		ClaimToken string `json:"claim_token"`
+		// ConfirmNonce is the one-time nonce from registration, when one was issued
+		ConfirmNonce string `json:"confirm_nonce"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		sendDecodeError(w, err)
+		return
+	}
+
+	if req.Email == "" && req.ClaimToken == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email or claim_token is required to confirm"})
+		return
+	}
+
+	if req.ClaimToken != "" {
+		err = h.DB.ConfirmGuestReservation(r.Context(), ticketID, req.ClaimToken, req.ConfirmNonce)
+	} else {
+		err = h.DB.ConfirmReservation(r.Context(), ticketID, req.Email, req.ConfirmNonce)
+	}
+	if errors.Is(err, ErrTicketNotFound) {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error(), "code": "ticket_not_found"})
+		return
+	}
+	if errors.Is(err, ErrInvalidConfirmNonce) {
+		SendJSON(w, http.StatusForbidden, map[string]string{"error": err.Error(), "code": "invalid_confirm_nonce"})
+		return
+	}
+	if errors.Is(err, ErrTicketExpired) {
+		SendJSON(w, http.StatusGone, map[string]string{"error": err.Error(), "code": "ticket_expired"})
+		return
+	}
+	if errors.Is(err, ErrEventCancelled) {
+		SendJSON(w, http.StatusGone, map[string]string{"error": err.Error(), "code": "event_cancelled"})
+		return
+	}
+	if errors.Is(err, ErrAlreadyConfirmed) {
+		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error(), "code": "already_confirmed"})
+		return
+	}
+	if errors.Is(err, ErrTicketNotActive) {
+		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error(), "code": "ticket_cancelled"})
+		return
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if ticket, err := h.DB.GetTicket(r.Context(), ticketID); err == nil {
+		h.sendConfirmationCode(r.Context(), ticket)
+	}
+
+	SendJSON(w, http.StatusOK, map[string]string{"message": "Ticket successfully confirmed"})
+}
+
+// recoverResponse is the only body POST /tickets/recover ever returns, so callers cannot
+// tell whether an address holds a ticket.
+var recoverResponse = map[string]string{"message": "If a ticket exists for that email, its confirmation code has been sent"}
+
+// HandleRecoverTicket handles POST /tickets/recover. It re-sends the confirmation code for
+// the email's active ticket to that address, and answers identically whether or not one exists.
+func (h *Handlers) HandleRecoverTicket(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email   string `json:"email"`
+		EventID int64  `json:"event_id"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		sendDecodeError(w, err)
+		return
+	}
+	if req.Email == "" || req.EventID <= 0 {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email and event_id are required"})
+		return
+	}
+
+	ticket, err := h.DB.FindActiveTicket(r.Context(), req.EventID, req.Email)
+	switch {
+	case err == nil:
+		h.sendConfirmationCode(r.Context(), ticket)
+	case !errors.Is(err, ErrTicketNotFound):
+		// Still answer 200: an error status here would leak that the lookup got further.
+		slog.ErrorContext(r.Context(), "ticket recovery lookup failed", "event_id", req.EventID, "error", err)
+	}
+
+	SendJSON(w, http.StatusOK, recoverResponse)
+}
+
+// HandleCancelTicket handles POST /tickets/{id}/cancel
+func (h *Handlers) HandleCancelTicket(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ticket ID format"})
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		sendDecodeError(w, err)
+		return
+	}
+	if req.Email == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email is required to cancel"})
+		return
+	}
+
+	err = h.DB.CancelTicket(r.Context(), ticketID, req.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTicketNotFound):
+			SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, ErrTicketNotActive):
+			SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during cancellation"})
+		}
+		return
+	}
+
+	SendJSON(w, http.StatusOK, map[string]string{"message": "Ticket cancelled"})
+}
+
+// HandleAdminCancelTicket handles DELETE /admin/tickets/{id}
+func (h *Handlers) HandleAdminCancelTicket(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ticket ID format"})
+		return
+	}
+
+	actor := EmailFromContext(r.Context())
+	if actor == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "X-User-Email header is required for audited actions"})
+		return
+	}
+
+	cancelled, err := h.DB.AdminCancelTicket(r.Context(), ticketID, actor)
+	if errors.Is(err, ErrTicketNotFound) {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during cancellation"})
+		return
+	}
+
+	if !cancelled {
+		SendJSON(w, http.StatusOK, map[string]string{"message": "Ticket was already cancelled"})
+		return
+	}
+	slog.Warn("ticket force-cancelled", "ticket_id", ticketID, "actor", actor)
+	SendJSON(w, http.StatusOK, map[string]string{"message": "Ticket cancelled"})
+}
+
+// AdminTicketExpiryRequest moves a reservation's hold: either an absolute expires_at or an
+// extend_by duration such as "30m".
+type AdminTicketExpiryRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	ExtendBy  string     `json:"extend_by"`
+}
+
+// HandleAdminSetTicketExpiry handles PATCH /admin/tickets/{id} for support cases that need a
+// specific user's hold extended.
+func (h *Handlers) HandleAdminSetTicketExpiry(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ticket ID format"})
+		return
+	}
+
+	actor := EmailFromContext(r.Context())
+	if actor == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "X-User-Email header is required for audited actions"})
+		return
+	}
+
+	var req AdminTicketExpiryRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		sendDecodeError(w, err)
+		return
+	}
+	if (req.ExpiresAt == nil) == (req.ExtendBy == "") {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Exactly one of expires_at or extend_by is required"})
+		return
+	}
+
+	var expiresAt time.Time
+	var extendBy time.Duration
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(h.DB.Clock.Now()) {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": "expires_at must be in the future"})
+			return
+		}
+		expiresAt = *req.ExpiresAt
+	} else {
+		extendBy, err = time.ParseDuration(req.ExtendBy)
+		if err != nil || extendBy <= 0 {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": "extend_by must be a positive duration such as 30m"})
+			return
+		}
+	}
+
+	ticket, err := h.DB.AdminSetTicketExpiry(r.Context(), ticketID, expiresAt, extendBy, actor)
+	switch {
+	case errors.Is(err, ErrTicketNotFound):
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, ErrAlreadyConfirmed):
+		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error(), "code": "already_confirmed"})
+		return
+	case errors.Is(err, ErrTicketNotActive):
+		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error(), "code": "ticket_cancelled"})
+		return
+	case err != nil:
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error while updating the hold"})
+		return
+	}
+
+	slog.Warn("ticket hold adjusted", "ticket_id", ticketID, "expires_at", ticket.ExpiresAt, "actor", actor)
+	SendJSON(w, http.StatusOK, ticket)
+}
+
+// authorizeEventOwner lets admins and the event's own organizer through and answers 403
+// for anyone else.
+func authorizeEventOwner(w http.ResponseWriter, r *http.Request, evt *Event) bool {
+	if RoleFromContext(r.Context()) == "admin" {
+		return true
+	}
+	if caller := EmailFromContext(r.Context()); caller == "" || caller != evt.OrganizerEmail {
+		SendJSON(w, http.StatusForbidden, map[string]string{"error": "Only the event's organizer may do this"})
+		return false
+	}
+	return true
+}
+
+// HandleCancelEventRegistrations handles POST /events/{id}/cancel-registrations, releasing
+// every held or confirmed spot at once, e.g. ahead of cancelling the event itself.
+func (h *Handlers) HandleCancelEventRegistrations(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
+		return
+	}
+
+	evt, err := h.DB.GetEvent(r.Context(), eventID)
+	if errors.Is(err, ErrEventNotFound) {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !authorizeEventOwner(w, r, evt) {
+		return
+	}
+
+	cancelled, err := h.DB.CancelEventRegistrations(r.Context(), eventID)
+	if errors.Is(err, ErrEventNotFound) {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during cancellation"})
+		return
+	}
+	h.EventCache.Invalidate()
+
+	slog.Warn("event registrations cancelled", "event_id", eventID, "count", cancelled, "actor", EmailFromContext(r.Context()))
+	SendJSON(w, http.StatusOK, map[string]interface{}{"cancelled": cancelled})
+}
+
+// HandleJoinWaitlist handles POST /events/{id}/waitlist
+func (h *Handlers) HandleJoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		sendDecodeError(w, err)
+		return
+	}
+	if req.Email == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email is required to join the waitlist"})
+		return
+	}
+
+	position, err := h.DB.JoinWaitlist(r.Context(), eventID, req.Email)
+	if err != nil {
+		var full *WaitlistFullError
+		switch {
+		case errors.As(err, &full):
+			SendJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":           ErrWaitlistFull.Error(),
+				"waitlist_length": full.Length,
+				"max_waitlist":    full.Max,
+			})
+		case errors.Is(err, ErrEventNotFound):
+			SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, ErrAlreadyWaitlisted), errors.Is(err, ErrSpotsAvailable):
+			SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error joining waitlist"})
+		}
+		return
+	}
+
+	SendJSON(w, http.StatusCreated, map[string]interface{}{
+		"message":  "Added to the waitlist. A seat will be reserved for you if one frees up.",
+		"position": position,
+	})
 }
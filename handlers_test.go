@@ -0,0 +1,2113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleEventICal(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+
+	startsAt := time.Date(2026, 12, 1, 18, 30, 0, 0, time.FixedZone("CET", 3600))
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "GopherCon, Day 1", TotalSpots: 10, StartsAt: &startsAt})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/events/%d/ical", evt.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(evt.ID))
+	rec := httptest.NewRecorder()
+	h.HandleEventICal(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("Expected text/calendar content type, got %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, fmt.Sprintf("event-%d.ics", evt.ID)) {
+		t.Errorf("Expected filename in Content-Disposition, got %q", cd)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{"BEGIN:VCALENDAR\r\n", "BEGIN:VEVENT\r\n", "DTSTART:20261201T173000Z\r\n", `SUMMARY:GopherCon\, Day 1`, "UID:event-"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected calendar to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestHandleEventICalRejectsDraft(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+
+	startsAt := time.Now().Add(24 * time.Hour)
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Secret", TotalSpots: 10, StartsAt: &startsAt, Status: EventStatusDraft})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/events/%d/ical", evt.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(evt.ID))
+	rec := httptest.NewRecorder()
+	h.HandleEventICal(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for draft event, got %d", rec.Code)
+	}
+}
+
+func TestHandleRegisterMissingEventReturns404(t *testing.T) {
+	h := &Handlers{DB: NewTestDB(t)}
+
+	req := httptest.NewRequest(http.MethodPost, "/events/424242/register",
+		strings.NewReader(`{"email":"ghost@example.com","idempotency_key":"key_ghost"}`))
+	req.SetPathValue("id", "424242")
+	rec := httptest.NewRecorder()
+	h.HandleRegister(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for missing event, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRegisterForDraftEventHidesItFromNonOwners(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Secret", TotalSpots: 10, Status: EventStatusDraft, OrganizerEmail: "org@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	register := func(addr, role, email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/events/%d/register", evt.ID),
+			strings.NewReader(`{"email":"fan@example.com","idempotency_key":"key_draft"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = addr
+		req.Header.Set("X-Role", role)
+		if email != "" {
+			req.Header.Set("X-User-Email", email)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	anon := register("10.0.0.1:1", "user", "")
+	if anon.Code != http.StatusNotFound || !strings.Contains(anon.Body.String(), ErrEventNotFound.Error()) {
+		t.Errorf("Expected the draft to look missing to an anonymous user, got %d: %s", anon.Code, anon.Body.String())
+	}
+	if rec := register("10.0.0.2:1", "user", "someone@example.com"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another user, got %d", rec.Code)
+	}
+
+	owner := register("10.0.0.3:1", "user", "org@example.com")
+	var body map[string]any
+	if err := json.Unmarshal(owner.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if owner.Code != http.StatusConflict || body["code"] != "event_draft" || body["error"] != ErrEventDraft.Error() {
+		t.Errorf("Expected 409 event_draft for the organizer, got %d: %v", owner.Code, body)
+	}
+
+	got, err := db.GetEvent(context.Background(), evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to reload event: %v", err)
+	}
+	if got.AvailableSpots != 10 {
+		t.Errorf("Expected no spots taken from a draft, got %d available", got.AvailableSpots)
+	}
+}
+
+func TestDraftEventsStayOffPublicReads(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	h := &Handlers{DB: db}
+	h.Routes() // Sets up the stats aggregator
+	published, err := db.CreateEvent(ctx, Event{Name: "Public", TotalSpots: 5, OrganizerEmail: "org@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	draft, err := db.CreateEvent(ctx, Event{Name: "Secret", TotalSpots: 5, Status: EventStatusDraft, OrganizerEmail: "org@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create draft: %v", err)
+	}
+
+	get := func(handler http.HandlerFunc, path string, id int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.SetPathValue("id", fmt.Sprint(id))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	for _, path := range []string{"/events", fmt.Sprintf("/events?ids=%d,%d", published.ID, draft.ID)} {
+		rec := get(h.HandleListEvents, path, 0)
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "Secret") || !strings.Contains(rec.Body.String(), "Public") {
+			t.Errorf("Expected %s to list only the published event, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := get(h.HandleEventStats, "/events/x/stats", draft.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a draft's stats, got %d", rec.Code)
+	}
+	if rec := get(h.HandleListSeats, "/events/x/seats", draft.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a draft's seats, got %d", rec.Code)
+	}
+	if _, err := db.JoinWaitlist(ctx, draft.ID, "fan@example.com"); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected a draft's waitlist to look missing, got %v", err)
+	}
+
+	// Its organizer still sees it
+	owned, err := db.ListEvents(ctx, EventFilter{OrganizerEmail: "org@example.com", IncludeDrafts: true})
+	if err != nil || len(owned) != 2 {
+		t.Errorf("Expected the organizer to see both events, got %d (%v)", len(owned), err)
+	}
+}
+
+func TestHandleListOrganizerEventsScopesToCaller(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	ctx := context.Background()
+
+	for _, e := range []Event{
+		{Name: "A1", TotalSpots: 5, OrganizerEmail: "alice@example.com"},
+		{Name: "B1", TotalSpots: 5, OrganizerEmail: "bob@example.com"},
+		{Name: "A2", TotalSpots: 5, OrganizerEmail: "alice@example.com"},
+	} {
+		if _, err := db.CreateEvent(ctx, e); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+	}
+
+	handler := RBACMiddleware("organizer", true)(http.HandlerFunc(h.HandleListOrganizerEvents))
+
+	list := func(role, email, query string) (int, []Event) {
+		req := httptest.NewRequest(http.MethodGet, "/organizer/events"+query, nil)
+		req.Header.Set("X-Role", role)
+		req.Header.Set("X-User-Email", email)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var events []Event
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&events); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec.Code, events
+	}
+
+	code, events := list("organizer", "alice@example.com", "")
+	if code != http.StatusOK || len(events) != 2 {
+		t.Fatalf("Expected alice to see 2 events, got %d (status %d)", len(events), code)
+	}
+	for _, e := range events {
+		if e.OrganizerEmail != "alice@example.com" {
+			t.Errorf("Alice saw event %q owned by %q", e.Name, e.OrganizerEmail)
+		}
+	}
+
+	if _, events := list("organizer", "alice@example.com", "?limit=1&offset=1"); len(events) != 1 || events[0].Name != "A2" {
+		t.Errorf("Expected second page to contain only A2, got %+v", events)
+	}
+
+	if code, _ := list("organizer", "alice@example.com", "?organizer=bob@example.com"); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for organizer peeking at another organizer, got %d", code)
+	}
+
+	if code, events := list("admin", "root@example.com", "?organizer=bob@example.com"); code != http.StatusOK || len(events) != 1 || events[0].Name != "B1" {
+		t.Errorf("Expected admin to see bob's single event, got %+v (status %d)", events, code)
+	}
+}
+
+func TestHandleGetTicketReturnsRFC3339UTC(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Timezones Anonymous", TotalSpots: 3})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "tz@example.com", IdempotencyKey: "key_tz"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	ticketID := registered.ID
+	if _, err := db.ExecContext(ctx, `UPDATE tickets SET expires_at = '2026-03-01 12:34:56' WHERE id = ?`, ticketID); err != nil {
+		t.Fatalf("Failed to set expiry: %v", err)
+	}
+
+	get := func(email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/tickets/%d?email=%s", ticketID, email), nil)
+		req.SetPathValue("id", fmt.Sprint(ticketID))
+		rec := httptest.NewRecorder()
+		h.HandleGetTicket(rec, req)
+		return rec
+	}
+
+	rec := get("tz@example.com")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode ticket: %v", err)
+	}
+	if body["expires_at"] != "2026-03-01T12:34:56Z" {
+		t.Errorf("Expected expires_at 2026-03-01T12:34:56Z, got %v", body["expires_at"])
+	}
+	createdAt, _ := body["created_at"].(string)
+	if parsed, err := time.Parse(time.RFC3339, createdAt); err != nil || !strings.HasSuffix(createdAt, "Z") || parsed.IsZero() {
+		t.Errorf("Expected created_at as RFC3339 UTC, got %q", createdAt)
+	}
+
+	if rec := get("someone-else@example.com"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another user's ticket, got %d", rec.Code)
+	}
+}
+
+func TestHandleCreateEventCapacityBounds(t *testing.T) {
+	h := &Handlers{DB: NewTestDB(t), MinCapacity: 10, MaxCapacity: 1000}
+
+	cases := []struct {
+		spots   int
+		want    int
+		message string
+	}{
+		{9, http.StatusUnprocessableEntity, "minimum capacity of 10"},
+		{10, http.StatusCreated, ""},
+		{1000, http.StatusCreated, ""},
+		{1001, http.StatusUnprocessableEntity, "maximum capacity of 1000"},
+	}
+	for _, tc := range cases {
+		body := fmt.Sprintf(`{"name":"Bounded","total_spots":%d}`, tc.spots)
+		rec := httptest.NewRecorder()
+		h.HandleCreateEvent(rec, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+
+		if rec.Code != tc.want {
+			t.Errorf("total_spots=%d: expected %d, got %d (%s)", tc.spots, tc.want, rec.Code, rec.Body.String())
+		}
+		if tc.message != "" && !strings.Contains(rec.Body.String(), tc.message) {
+			t.Errorf("total_spots=%d: expected error naming %q, got %s", tc.spots, tc.message, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleCreateEventBoundsHoldSeconds(t *testing.T) {
+	h := &Handlers{DB: NewTestDB(t)}
+
+	cases := []struct {
+		holdSeconds int64
+		want        int
+	}{
+		{0, http.StatusBadRequest},
+		{MaxHoldSeconds, http.StatusCreated},
+		{MaxHoldSeconds + 1, http.StatusBadRequest},
+		{math.MaxInt64, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		body := fmt.Sprintf(`{"name":"Held","total_spots":5,"hold_seconds":%d}`, tc.holdSeconds)
+		rec := httptest.NewRecorder()
+		h.HandleCreateEvent(rec, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+
+		if rec.Code != tc.want {
+			t.Errorf("hold_seconds=%d: expected %d, got %d (%s)", tc.holdSeconds, tc.want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleCreateEventRejectsHugeCapacity(t *testing.T) {
+	// No operator bounds configured; the hard ceiling still applies
+	h := &Handlers{DB: NewTestDB(t)}
+
+	cases := []struct {
+		spots   int
+		want    int
+		message string
+	}{
+		{MaxTotalSpots, http.StatusCreated, ""},
+		{MaxTotalSpots + 1, http.StatusUnprocessableEntity, "maximum capacity"},
+		{math.MaxInt64 - 1, http.StatusUnprocessableEntity, "maximum capacity"},
+		{math.MaxInt64, http.StatusUnprocessableEntity, "maximum capacity"},
+	}
+	for _, tc := range cases {
+		body := fmt.Sprintf(`{"name":"Bounded","total_spots":%d}`, tc.spots)
+		rec := httptest.NewRecorder()
+		h.HandleCreateEvent(rec, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+
+		if rec.Code != tc.want {
+			t.Errorf("total_spots=%d: expected %d, got %d (%s)", tc.spots, tc.want, rec.Code, rec.Body.String())
+		}
+		if tc.message != "" && !strings.Contains(rec.Body.String(), tc.message) {
+			t.Errorf("total_spots=%d: expected error naming %q, got %s", tc.spots, tc.message, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleRegisterIdempotencyKeyHeader(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Retry Storm", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	register := func(headerKey, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/events/x/register", strings.NewReader(body))
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		if headerKey != "" {
+			req.Header.Set("Idempotency-Key", headerKey)
+		}
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+		return rec.Code
+	}
+
+	// Header path
+	headerFirst := register("hdr-key-1", `{"email":"header@example.com"}`)
+	headerRetry := register("hdr-key-1", `{"email":"header@example.com"}`)
+
+	// Legacy body path
+	bodyFirst := register("", `{"email":"body@example.com","idempotency_key":"body-key-1"}`)
+	bodyRetry := register("", `{"email":"body@example.com","idempotency_key":"body-key-1"}`)
+
+	if headerFirst != http.StatusCreated || bodyFirst != http.StatusCreated {
+		t.Fatalf("Expected first registrations to succeed, got header=%d body=%d", headerFirst, bodyFirst)
+	}
+	if headerRetry != bodyRetry {
+		t.Errorf("Expected header and body retries to behave the same, got header=%d body=%d", headerRetry, bodyRetry)
+	}
+
+	var tickets int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tickets WHERE idempotency_key = 'hdr-key-1'`).Scan(&tickets); err != nil {
+		t.Fatalf("Failed to count tickets: %v", err)
+	}
+	if tickets != 1 {
+		t.Errorf("Expected exactly one ticket for the header key, got %d", tickets)
+	}
+
+	if code := register(strings.Repeat("k", maxIdempotencyKeyLength+1), `{"email":"long@example.com"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for over-long header key, got %d", code)
+	}
+}
+
+func TestHandleRegisterValidatesIdempotencyKeyFormat(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Key Discipline", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"uuid", "3f2b8c1e-9a4d-4b7e-8f1a-2c6d5e4f3a2b", http.StatusCreated},
+		{"uppercase uuid", "3F2B8C1E-9A4D-4B7E-8F1A-2C6D5E4F3A2C", http.StatusCreated},
+		{"token", "checkout_42-retry", http.StatusCreated},
+		{"max length", strings.Repeat("k", maxIdempotencyKeyLength), http.StatusCreated},
+		{"over-long", strings.Repeat("k", maxIdempotencyKeyLength+1), http.StatusBadRequest},
+		{"whitespace", "key with spaces", http.StatusBadRequest},
+		{"punctuation", "key/../../etc", http.StatusBadRequest},
+		{"braced uuid", "{3f2b8c1e-9a4d-4b7e-8f1a-2c6d5e4f3a2d}", http.StatusBadRequest},
+		{"non-ascii", "clé", http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"email":"user%d@example.com","idempotency_key":%q}`, i, tt.key)
+			req := httptest.NewRequest(http.MethodPost, "/events/x/register", strings.NewReader(body))
+			req.SetPathValue("id", fmt.Sprint(evt.ID))
+			rec := httptest.NewRecorder()
+			h.HandleRegister(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("Expected %d for key %q, got %d: %s", tt.want, tt.key, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleGetTicketExtendsHoldUntilCap(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	h := &Handlers{DB: db, HoldExtension: 10 * time.Minute, MaxHold: 20 * time.Minute}
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Slow Checkout", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "slow@example.com", IdempotencyKey: "key_slow"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	ticketID := registered.ID
+	createdAt := clock.Now()
+
+	poll := func() Ticket {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/tickets/x?email=slow@example.com", nil)
+		req.SetPathValue("id", fmt.Sprint(ticketID))
+		rec := httptest.NewRecorder()
+		h.HandleGetTicket(rec, req)
+		var ticket Ticket
+		if err := json.NewDecoder(rec.Body).Decode(&ticket); err != nil {
+			t.Fatalf("Failed to decode ticket: %v", err)
+		}
+		return ticket
+	}
+
+	if ticket := poll(); !ticket.ExpiresAt.Equal(clock.Now().Add(10 * time.Minute)) {
+		t.Fatalf("Expected first poll to extend hold to now+10m, got %v", ticket.ExpiresAt)
+	}
+
+	clock.Advance(9 * time.Minute)
+	if ticket := poll(); !ticket.ExpiresAt.Equal(clock.Now().Add(10 * time.Minute)) {
+		t.Fatalf("Expected hold to slide forward again, got %v", ticket.ExpiresAt)
+	}
+
+	clock.Advance(9 * time.Minute)
+	if ticket := poll(); !ticket.ExpiresAt.Equal(createdAt.Add(20 * time.Minute)) {
+		t.Fatalf("Expected hold to be capped at created_at+20m, got %v", ticket.ExpiresAt)
+	}
+
+	clock.Advance(3 * time.Minute)
+	if ticket := poll(); ticket.ExpiresAt.After(clock.Now()) {
+		t.Fatalf("Expected capped hold to lapse, expires_at %v", ticket.ExpiresAt)
+	}
+	if reclaimed, err := db.ReclaimExpiredSeats(ctx); err != nil || reclaimed != 1 {
+		t.Errorf("Expected the lapsed hold to be reclaimed, got %d (%v)", reclaimed, err)
+	}
+}
+
+func TestHandleConfirmDistinguishesFailures(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Checkout", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	register := func(email string) int64 {
+		t.Helper()
+		registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: email, IdempotencyKey: "key_" + email})
+		if err != nil {
+			t.Fatalf("Failed to register %s: %v", email, err)
+		}
+		id := registered.ID
+		return id
+	}
+	confirm := func(ticketID int64, email string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/tickets/x/confirm", strings.NewReader(fmt.Sprintf(`{"email":%q}`, email)))
+		req.SetPathValue("id", fmt.Sprint(ticketID))
+		rec := httptest.NewRecorder()
+		h.HandleConfirm(rec, req)
+		var body map[string]string
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body["code"]
+	}
+
+	live := register("live@example.com")
+	if code, _ := confirm(live, "live@example.com"); code != http.StatusOK {
+		t.Fatalf("Expected first confirm to succeed, got %d", code)
+	}
+
+	expired := register("late@example.com")
+	if _, err := db.ExecContext(ctx, `UPDATE tickets SET expires_at = datetime('now', '-1 minute') WHERE id = ?`, expired); err != nil {
+		t.Fatalf("Failed to expire ticket: %v", err)
+	}
+	reclaimed := register("reclaimed@example.com")
+	if _, err := db.ExecContext(ctx, `UPDATE tickets SET expires_at = datetime('now', '-1 minute') WHERE id = ?`, reclaimed); err != nil {
+		t.Fatalf("Failed to expire ticket: %v", err)
+	}
+	if _, err := db.ReclaimExpiredSeats(ctx); err != nil {
+		t.Fatalf("Failed to reclaim: %v", err)
+	}
+	cancelled := register("quitter@example.com")
+	if err := db.CancelTicket(ctx, cancelled, "quitter@example.com"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		ticketID int64
+		email    string
+		status   int
+		code     string
+	}{
+		{"already confirmed", live, "live@example.com", http.StatusConflict, "already_confirmed"},
+		{"expired before reclaim", expired, "late@example.com", http.StatusGone, "ticket_expired"},
+		{"expired and reclaimed", reclaimed, "reclaimed@example.com", http.StatusGone, "ticket_expired"},
+		{"cancelled by owner", cancelled, "quitter@example.com", http.StatusConflict, "ticket_cancelled"},
+		{"wrong owner", live, "someone@example.com", http.StatusNotFound, "ticket_not_found"},
+		{"missing ticket", 9999, "live@example.com", http.StatusNotFound, "ticket_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := confirm(tt.ticketID, tt.email)
+			if status != tt.status || code != tt.code {
+				t.Errorf("Expected %d %q, got %d %q", tt.status, tt.code, status, code)
+			}
+		})
+	}
+}
+
+func TestConfirmRejectsCancelledEvent(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	h := &Handlers{DB: db}
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Called Off", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "hopeful@example.com", IdempotencyKey: "key_hopeful"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE events SET status = ? WHERE id = ?`, EventStatusCancelled, evt.ID); err != nil {
+		t.Fatalf("Failed to cancel event: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/tickets/x/confirm", strings.NewReader(`{"email":"hopeful@example.com"}`))
+	req.SetPathValue("id", fmt.Sprint(registered.ID))
+	rec := httptest.NewRecorder()
+	h.HandleConfirm(rec, req)
+
+	var body map[string]string
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusGone || body["code"] != "event_cancelled" {
+		t.Errorf("Expected 410 event_cancelled, got %d %q", rec.Code, body["code"])
+	}
+	if ticket, _ := db.GetTicket(ctx, registered.ID); ticket.Status != "reserved" {
+		t.Errorf("Expected the ticket to stay unconfirmed, got %q", ticket.Status)
+	}
+}
+
+func TestAdminCancelTicketReturnsSpot(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Support Desk", TotalSpots: 2})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "buyer@example.com", IdempotencyKey: "key_buyer"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	ticketID := registered.ID
+	if err := db.ConfirmReservation(ctx, ticketID, "buyer@example.com", ""); err != nil {
+		t.Fatalf("Failed to confirm: %v", err)
+	}
+
+	forceCancel := func() int {
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/admin/tickets/%d", ticketID), nil)
+		req.Header.Set("X-Role", "admin")
+		req.Header.Set("X-User-Email", "support@example.com")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := forceCancel(); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	got, err := db.GetEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to load event: %v", err)
+	}
+	if got.AvailableSpots != 2 {
+		t.Errorf("Expected availability to return to 2, got %d", got.AvailableSpots)
+	}
+
+	// Repeating the cancellation must not hand out the spot twice
+	if code := forceCancel(); code != http.StatusOK {
+		t.Fatalf("Expected idempotent 200, got %d", code)
+	}
+	if got, _ := db.GetEvent(ctx, evt.ID); got.AvailableSpots != 2 {
+		t.Errorf("Expected availability to stay at 2, got %d", got.AvailableSpots)
+	}
+
+	var actor string
+	var entries int
+	if err := db.QueryRowContext(ctx, `SELECT actor, COUNT(*) FROM audit_log WHERE ticket_id = ?`, ticketID).Scan(&actor, &entries); err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	if actor != "support@example.com" || entries != 1 {
+		t.Errorf("Expected one audit entry by support@example.com, got %d by %q", entries, actor)
+	}
+}
+
+func TestAdminExtendTicketHold(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 5, 1, 10, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Support Desk", TotalSpots: 2})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	held, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "slow@example.com", IdempotencyKey: "key_slow"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	confirmed, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "done@example.com", IdempotencyKey: "key_done"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if err := db.ConfirmReservation(ctx, confirmed.ID, "done@example.com", ""); err != nil {
+		t.Fatalf("Failed to confirm: %v", err)
+	}
+
+	patch := func(ticketID int64, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/admin/tickets/%d", ticketID), strings.NewReader(body))
+		req.Header.Set("X-Role", "admin")
+		req.Header.Set("X-User-Email", "support@example.com")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := patch(held.ID, `{"extend_by":"1h"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var ticket Ticket
+	if err := json.NewDecoder(rec.Body).Decode(&ticket); err != nil {
+		t.Fatalf("Failed to decode ticket: %v", err)
+	}
+	if want := held.ExpiresAt.Add(time.Hour); !ticket.ExpiresAt.Equal(want) {
+		t.Errorf("Expected expires_at %v, got %v", want, ticket.ExpiresAt)
+	}
+
+	// Well past the original hold, the reclaimer must leave the extended ticket alone
+	clock.Advance(30 * time.Minute)
+	if reclaimed, err := db.ReclaimExpiredSeats(ctx); err != nil || reclaimed != 0 {
+		t.Fatalf("Expected nothing reclaimed, got %d (%v)", reclaimed, err)
+	}
+	if got, _ := db.GetTicket(ctx, held.ID); got.Status != "reserved" {
+		t.Errorf("Expected the ticket to stay reserved, got %q", got.Status)
+	}
+
+	if rec := patch(confirmed.ID, `{"extend_by":"1h"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a confirmed ticket, got %d", rec.Code)
+	}
+	if rec := patch(held.ID, `{"extend_by":"1h","expires_at":"2030-05-02T00:00:00Z"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when both fields are set, got %d", rec.Code)
+	}
+
+	var entries int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log WHERE ticket_id = ? AND action = 'ticket.set_expiry'`, held.ID).Scan(&entries); err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	if entries != 1 {
+		t.Errorf("Expected one audit entry, got %d", entries)
+	}
+}
+
+// flakyWriter counts WriteHeader calls and can fail every body write, like a client that
+// has already hung up.
+type flakyWriter struct {
+	*httptest.ResponseRecorder
+	headerWrites int
+	failWrites   bool
+}
+
+func (fw *flakyWriter) WriteHeader(code int) {
+	fw.headerWrites++
+	fw.ResponseRecorder.WriteHeader(code)
+}
+
+func (fw *flakyWriter) Write(b []byte) (int, error) {
+	if fw.failWrites {
+		return 0, errors.New("broken pipe")
+	}
+	return fw.ResponseRecorder.Write(b)
+}
+
+func TestSendJSONWritesHeaderOnce(t *testing.T) {
+	t.Run("body write fails after headers are flushed", func(t *testing.T) {
+		fw := &flakyWriter{ResponseRecorder: httptest.NewRecorder(), failWrites: true}
+		SendJSON(wrapResponseWriter(fw), http.StatusCreated, map[string]string{"message": "ok"})
+		if fw.headerWrites != 1 || fw.Code != http.StatusCreated {
+			t.Errorf("Expected a single 201, got %d header writes and status %d", fw.headerWrites, fw.Code)
+		}
+	})
+
+	t.Run("response already started", func(t *testing.T) {
+		fw := &flakyWriter{ResponseRecorder: httptest.NewRecorder()}
+		w := wrapResponseWriter(fw)
+		w.WriteHeader(http.StatusAccepted)
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "late"})
+		if fw.headerWrites != 1 || fw.Code != http.StatusAccepted || fw.Body.Len() != 0 {
+			t.Errorf("Expected the first 202 to stand alone, got %d header writes, status %d, body %q", fw.headerWrites, fw.Code, fw.Body.String())
+		}
+	})
+
+	t.Run("unencodable value", func(t *testing.T) {
+		fw := &flakyWriter{ResponseRecorder: httptest.NewRecorder()}
+		SendJSON(wrapResponseWriter(fw), http.StatusOK, map[string]any{"ch": make(chan int)})
+		if fw.headerWrites != 1 || fw.Code != http.StatusInternalServerError {
+			t.Errorf("Expected a single 500, got %d header writes and status %d", fw.headerWrites, fw.Code)
+		}
+		var body map[string]any
+		if err := json.Unmarshal(fw.Body.Bytes(), &body); err != nil || body["error"] == "" {
+			t.Errorf("Expected a JSON error body, got %q", fw.Body.String())
+		}
+	})
+}
+
+func TestErrorResponsesReportRetryable(t *testing.T) {
+	path := t.TempDir() + "/retryable.db"
+	holder, err := NewDB(fmt.Sprintf("file:%s?mode=rwc", path))
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer holder.Close()
+	if err := holder.InitSchema(context.Background()); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	contender, err := NewDB(fmt.Sprintf("file:%s?mode=rwc", path))
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer contender.Close()
+	h := &Handlers{DB: contender}
+
+	register := func(eventID int64, email string) map[string]any {
+		t.Helper()
+		body := fmt.Sprintf(`{"email":%q,"idempotency_key":%q}`, email, "key_"+strings.Split(email, "@")[0])
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("id", strconv.FormatInt(eventID, 10))
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+		var got map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		got["status"] = rec.Code
+		return got
+	}
+
+	ctx := context.Background()
+	soldOut, err := holder.CreateEvent(ctx, Event{Name: "Packed", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	open, err := holder.CreateEvent(ctx, Event{Name: "Roomy", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	register(soldOut.ID, "first@example.com")
+
+	// Sold out will not change by asking again
+	if got := register(soldOut.ID, "late@example.com"); got["status"] != http.StatusConflict || got["retryable"] != false {
+		t.Errorf("Expected a non-retryable 409, got %v", got)
+	}
+
+	// Another connection holding the write lock makes the database report busy
+	tx, err := holder.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE events SET name = name WHERE id = ?`, open.ID); err != nil {
+		t.Fatalf("Failed to take write lock: %v", err)
+	}
+	if got := register(open.ID, "blocked@example.com"); got["status"] != http.StatusServiceUnavailable || got["retryable"] != true {
+		t.Errorf("Expected a retryable 503, got %v", got)
+	}
+
+	// A handler that knows better can override the flag the status implies
+	rec := httptest.NewRecorder()
+	SendJSON(rec, http.StatusConflict, map[string]interface{}{"error": "seat is being released", "retryable": true})
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got["retryable"] != true {
+		t.Errorf("Expected the handler's retryable to stand, got %s", rec.Body.String())
+	}
+}
+
+func TestHandleListEventsEnvelope(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	for i := 1; i <= 3; i++ {
+		if _, err := db.CreateEvent(context.Background(), Event{Name: fmt.Sprintf("Event %d", i), TotalSpots: 5}); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+	}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.HandleListEvents(rec, httptest.NewRequest(http.MethodGet, "/events"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %q, got %d", query, rec.Code)
+		}
+		return rec
+	}
+
+	var bare []Event
+	if err := json.NewDecoder(list("").Body).Decode(&bare); err != nil {
+		t.Fatalf("Expected a bare array by default: %v", err)
+	}
+	if len(bare) != 3 {
+		t.Errorf("Expected all 3 events, got %d", len(bare))
+	}
+
+	var page struct {
+		Data []Event `json:"data"`
+		Meta ListMeta
+	}
+	if err := json.NewDecoder(list("?envelope=true&limit=2&offset=1").Body).Decode(&page); err != nil {
+		t.Fatalf("Expected an envelope object: %v", err)
+	}
+	if len(page.Data) != 2 || page.Data[0].Name != "Event 2" {
+		t.Errorf("Expected events 2 and 3, got %+v", page.Data)
+	}
+	if page.Meta != (ListMeta{Total: 3, Limit: 2, Offset: 1}) {
+		t.Errorf("Unexpected meta: %+v", page.Meta)
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleListEvents(rec, httptest.NewRequest(http.MethodGet, "/events?envelope=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed envelope flag, got %d", rec.Code)
+	}
+}
+
+func TestHandleListEventsCursorPaging(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 5, 1, 10, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	h := &Handlers{DB: db}
+	ctx := context.Background()
+
+	var original []int64
+	for i := 1; i <= 5; i++ {
+		clock.Advance(time.Minute)
+		evt, err := db.CreateEvent(ctx, Event{Name: fmt.Sprintf("Event %d", i), TotalSpots: 5})
+		if err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+		original = append(original, evt.ID)
+	}
+
+	type page struct {
+		Data []Event `json:"data"`
+		Meta ListMeta
+	}
+	fetch := func(query string) page {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.HandleListEvents(rec, httptest.NewRequest(http.MethodGet, "/events?envelope=true&limit=2&sort=created_at"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %q, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var p page
+		if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+			t.Fatalf("Failed to decode page: %v", err)
+		}
+		return p
+	}
+
+	seen := map[int64]int{}
+	query := ""
+	backdated := time.Date(2030, 4, 1, 0, 0, 0, 0, time.UTC)
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("Cursor paging did not terminate")
+		}
+		p := fetch(query)
+		for _, e := range p.Data {
+			seen[e.ID]++
+		}
+		if p.Meta.NextCursor == nil {
+			break
+		}
+		query = fmt.Sprintf("&after=%d", *p.Meta.NextCursor)
+
+		// Events landing on pages already read would shift every later offset page
+		backdated = backdated.Add(time.Minute)
+		db.Clock = NewMockClock(backdated)
+		if _, err := db.CreateEvent(ctx, Event{Name: "Backfilled", TotalSpots: 5}); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+		db.Clock = clock
+	}
+
+	for _, id := range original {
+		if seen[id] != 1 {
+			t.Errorf("Expected event %d exactly once, saw it %d times", id, seen[id])
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleListEvents(rec, httptest.NewRequest(http.MethodGet, "/events?envelope=true&after=2&offset=2", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when combining after and offset, got %d", rec.Code)
+	}
+}
+
+func TestGuestRegistrationConfirmsWithClaimToken(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Walk-up Kiosk", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	register := func(key string) (int64, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/events/x/register", strings.NewReader(fmt.Sprintf(`{"guest":true,"idempotency_key":%q}`, key)))
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 for a guest, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			TicketID   int64  `json:"ticket_id"`
+			ClaimToken string `json:"claim_token"`
+		}
+		json.NewDecoder(rec.Body).Decode(&resp)
+		if resp.ClaimToken == "" {
+			t.Fatal("Expected a claim_token in the response")
+		}
+		return resp.TicketID, resp.ClaimToken
+	}
+	confirm := func(ticketID int64, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/tickets/x/confirm", strings.NewReader(body))
+		req.SetPathValue("id", fmt.Sprint(ticketID))
+		rec := httptest.NewRecorder()
+		h.HandleConfirm(rec, req)
+		return rec.Code
+	}
+
+	// Two guests at the same event must not trip UNIQUE(event_id, user_email)
+	first, firstToken := register("kiosk-1")
+	second, secondToken := register("kiosk-2")
+	if firstToken == secondToken {
+		t.Fatal("Expected distinct claim tokens")
+	}
+
+	if code := confirm(first, fmt.Sprintf(`{"claim_token":%q}`, secondToken)); code != http.StatusNotFound {
+		t.Errorf("Expected another guest's token to be rejected with 404, got %d", code)
+	}
+	if code := confirm(first, fmt.Sprintf(`{"claim_token":%q}`, firstToken)); code != http.StatusOK {
+		t.Fatalf("Expected confirmation with the claim token, got %d", code)
+	}
+
+	ticket, err := db.GetTicket(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Failed to load ticket: %v", err)
+	}
+	if ticket.Status != "confirmed" || ticket.UserEmail != "" {
+		t.Errorf("Expected a confirmed guest ticket without email, got %+v", ticket)
+	}
+	if second == first {
+		t.Error("Expected separate tickets")
+	}
+}
+
+func TestHandleCreateEventEnforcesOrganizerQuota(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db, MaxEventsPerOrganizer: 2, AllowHeaderRole: true}).Routes()
+
+	calls := 0
+	create := func(role, email string) int {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"name":"Meetup","total_spots":10}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Role", role)
+		req.Header.Set("X-User-Email", email)
+		req.RemoteAddr = fmt.Sprintf("%s-%d", email, calls) // each call gets its own rate-limit bucket
+		calls++
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 1; i <= 2; i++ {
+		if code := create("organizer", "busy@example.com"); code != http.StatusCreated {
+			t.Fatalf("Event %d: expected 201, got %d", i, code)
+		}
+	}
+	if code := create("organizer", "busy@example.com"); code != http.StatusForbidden {
+		t.Errorf("Expected the 3rd event to be rejected with 403, got %d", code)
+	}
+
+	// A cancelled event no longer counts, so it frees a slot
+	if _, err := db.ExecContext(context.Background(), `UPDATE events SET status = ? WHERE id = (SELECT MIN(id) FROM events WHERE organizer_email = ?)`, EventStatusCancelled, "busy@example.com"); err != nil {
+		t.Fatalf("Failed to cancel event: %v", err)
+	}
+	if code := create("organizer", "busy@example.com"); code != http.StatusCreated {
+		t.Errorf("Expected a cancelled event to free a slot, got %d", code)
+	}
+	if code := create("organizer", "busy@example.com"); code != http.StatusForbidden {
+		t.Errorf("Expected the quota to apply again, got %d", code)
+	}
+	if code := create("organizer", "other@example.com"); code != http.StatusCreated {
+		t.Errorf("Expected another organizer to be unaffected, got %d", code)
+	}
+	if code := create("admin", "busy@example.com"); code != http.StatusCreated {
+		t.Errorf("Expected admins to bypass the quota, got %d", code)
+	}
+}
+
+func TestHandleCreateEventRejectsDuplicateNames(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db, RejectDuplicateNames: true, AllowHeaderRole: true}).Routes()
+
+	create := func(email, name string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"name":%q,"total_spots":10}`, name)
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Role", "organizer")
+		req.Header.Set("X-User-Email", email)
+		req.RemoteAddr = email
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := create("host@example.com", "Spring Gala")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", first.Code, first.Body.String())
+	}
+	var original Event
+	if err := json.NewDecoder(first.Body).Decode(&original); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+
+	dup := create("host@example.com", "  spring GALA ")
+	if dup.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 for a duplicate name, got %d", dup.Code)
+	}
+	var conflict struct {
+		ExistingEventID int64 `json:"existing_event_id"`
+	}
+	if err := json.NewDecoder(dup.Body).Decode(&conflict); err != nil {
+		t.Fatalf("Failed to decode conflict: %v", err)
+	}
+	if conflict.ExistingEventID != original.ID {
+		t.Errorf("Expected existing_event_id %d, got %d", original.ID, conflict.ExistingEventID)
+	}
+
+	if rec := create("rival@example.com", "Spring Gala"); rec.Code != http.StatusCreated {
+		t.Errorf("Expected another organizer to reuse the name, got %d", rec.Code)
+	}
+}
+
+func TestEventJSONOmitsUnsetOptionalFields(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+
+	create := func(body string) map[string]json.RawMessage {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.HandleCreateEvent(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		return fields
+	}
+
+	free := create(`{"name":"Free Meetup","total_spots":10}`)
+	for _, key := range []string{"price_cents", "starts_at", "hold_seconds", "tags", "max_waitlist", "registration_opens_at"} {
+		if _, ok := free[key]; ok {
+			t.Errorf("Expected %s to be omitted, got %s", key, free[key])
+		}
+	}
+	for _, key := range []string{"id", "name", "total_spots", "available_spots", "status", "currency"} {
+		if _, ok := free[key]; !ok {
+			t.Errorf("Expected %s to always be present", key)
+		}
+	}
+
+	paid := create(`{"name":"Paid Gala","total_spots":10,"price_cents":2500,"tags":["music"]}`)
+	if string(paid["price_cents"]) != "2500" || string(paid["tags"]) != `["music"]` {
+		t.Errorf("Expected set fields to be present, got price_cents=%s tags=%s", paid["price_cents"], paid["tags"])
+	}
+}
+
+func TestHandleCreateEventIsIdempotentByExternalID(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	create := func(email, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Role", "organizer")
+		req.Header.Set("X-User-Email", email)
+		req.RemoteAddr = email
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) Event {
+		var evt Event
+		if err := json.NewDecoder(rec.Body).Decode(&evt); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		return evt
+	}
+
+	first := create("host@example.com", `{"name":"Harvest Fair","total_spots":10,"external_id":"crm-42"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", first.Code, first.Body.String())
+	}
+	original := decode(first)
+
+	// A retried sync returns the original even if the payload drifted
+	again := create("host@example.com", `{"name":"Harvest Fair (renamed)","total_spots":20,"external_id":"crm-42"}`)
+	if again.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for a repeated external_id, got %d: %s", again.Code, again.Body.String())
+	}
+	if got := decode(again); got.ID != original.ID || got.Name != "Harvest Fair" {
+		t.Errorf("Expected the original event %d back, got %+v", original.ID, got)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM events WHERE external_id = 'crm-42'`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count events: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected a single event row, got %d", count)
+	}
+
+	// The id is only unique per organizer
+	if rec := create("rival@example.com", `{"name":"Harvest Fair","total_spots":10,"external_id":"crm-42"}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected another organizer to reuse the external_id, got %d", rec.Code)
+	}
+}
+
+// recordingSender captures emails instead of sending them.
+type recordingSender struct {
+	sent    []string
+	soldOut []int64
+}
+
+func (s *recordingSender) SendConfirmationCode(ctx context.Context, to string, ticket *Ticket) error {
+	s.sent = append(s.sent, to)
+	return nil
+}
+
+func (s *recordingSender) SendSoldOutNotice(ctx context.Context, to string, event *Event) error {
+	s.soldOut = append(s.soldOut, event.ID)
+	return nil
+}
+
+func TestRecoverTicketDoesNotRevealExistence(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	sender := &recordingSender{}
+	router := (&Handlers{DB: db, Email: sender, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Book Fair", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "reader@example.com", IdempotencyKey: "key_reader"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	ticketID := registered.ID
+	ticket, err := db.GetTicket(ctx, ticketID)
+	if err != nil {
+		t.Fatalf("Failed to load ticket: %v", err)
+	}
+
+	recover := func(email string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"email":%q,"event_id":%d}`, email, evt.ID)
+		req := httptest.NewRequest(http.MethodPost, "/tickets/recover", strings.NewReader(body))
+		req.Header.Set("X-Role", "user")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	known := recover("reader@example.com")
+	if len(sender.sent) != 1 || sender.sent[0] != "reader@example.com" {
+		t.Fatalf("Expected one email to reader@example.com, got %v", sender.sent)
+	}
+	unknown := recover("stranger@example.com")
+	if len(sender.sent) != 1 {
+		t.Fatalf("Expected no email for an unknown address, got %v", sender.sent)
+	}
+
+	if known.Code != http.StatusOK || unknown.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for both, got %d and %d", known.Code, unknown.Code)
+	}
+	if known.Body.String() != unknown.Body.String() {
+		t.Errorf("Responses differ: %q vs %q", known.Body.String(), unknown.Body.String())
+	}
+	if ticket.ConfirmationCode == "" || strings.Contains(known.Body.String(), ticket.ConfirmationCode) {
+		t.Errorf("Response must not carry the confirmation code: %s", known.Body.String())
+	}
+}
+
+func TestSoldOutNoticeFiresOnce(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	sender := &recordingSender{}
+	h := &Handlers{DB: db, Email: sender}
+	db.OnSoldOut = h.NotifySoldOut
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Tiny Venue", TotalSpots: 2, OrganizerEmail: "host@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	for i := 0; i < 6; i++ {
+		_, err := db.RegisterForEvent(ctx, RegisterParams{
+			EventID:        evt.ID,
+			Email:          fmt.Sprintf("fan%d@example.com", i),
+			IdempotencyKey: fmt.Sprintf("fan_%d", i),
+		})
+		if i < 2 && err != nil {
+			t.Fatalf("Registration %d failed: %v", i, err)
+		}
+		if i >= 2 && !errors.Is(err, ErrSoldOut) {
+			t.Fatalf("Registration %d: expected ErrSoldOut, got %v", i, err)
+		}
+		if want := min(i, 1); len(sender.soldOut) != want {
+			t.Fatalf("After registration %d: expected %d notices, got %d", i, want, len(sender.soldOut))
+		}
+	}
+	if sender.soldOut[0] != evt.ID {
+		t.Errorf("Expected notice for event %d, got %d", evt.ID, sender.soldOut[0])
+	}
+}
+
+func TestListEventsSortsByCreatedAt(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	router := (&Handlers{DB: db}).Routes()
+	base := time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	// Insert out of chronological order so id and created_at orderings disagree
+	db.Clock = NewMockClock(base.Add(time.Hour))
+	newer, err := db.CreateEvent(ctx, Event{Name: "Added Second", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	db.Clock = NewMockClock(base)
+	older, err := db.CreateEvent(ctx, Event{Name: "Added First", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	list := func(query string) []Event {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /events%s: expected 200, got %d", query, rec.Code)
+		}
+		var events []Event
+		if err := json.NewDecoder(rec.Body).Decode(&events); err != nil {
+			t.Fatalf("Failed to decode events: %v", err)
+		}
+		return events
+	}
+
+	got := list("?sort=created_at")
+	if len(got) != 2 || got[0].ID != older.ID || got[1].ID != newer.ID {
+		t.Fatalf("Expected oldest first, got %+v", got)
+	}
+	if !got[0].CreatedAt.Equal(base) || !got[1].CreatedAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("Expected created_at %v and %v, got %v and %v", base, base.Add(time.Hour), got[0].CreatedAt, got[1].CreatedAt)
+	}
+	if got := list(""); got[0].ID != newer.ID {
+		t.Errorf("Expected the default order to stay by id")
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?sort=name", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown sort, got %d", rec.Code)
+	}
+}
+
+func TestRegisterRejectsNonPositiveQuantity(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	router := (&Handlers{DB: db, MaxTicketQuantity: 4, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Quantity Check", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	for i, quantity := range []int{0, -3, 5} {
+		body := fmt.Sprintf(`{"email":"q%d@example.com","idempotency_key":"qty_%d","quantity":%d}`, i, i, quantity)
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/events/%d/register", evt.ID), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Role", "user")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("quantity %d: expected 422, got %d", quantity, rec.Code)
+		}
+	}
+
+	// The data layer refuses a negative quantity on its own as well
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "direct@example.com", IdempotencyKey: "direct", Quantity: -3}); !errors.Is(err, ErrInvalidQuantity) {
+		t.Errorf("Expected ErrInvalidQuantity, got %v", err)
+	}
+
+	got, err := db.GetEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to load event: %v", err)
+	}
+	if got.AvailableSpots != 10 {
+		t.Errorf("Expected inventory untouched at 10, got %d", got.AvailableSpots)
+	}
+}
+
+func TestRegisterReturnsFullTicket(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 5, 1, 10, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Paid Workshop", TotalSpots: 5, PriceCents: 2500})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	body := `{"email":"pay@example.com","idempotency_key":"paid_1","quantity":2}`
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/events/%d/register", evt.ID), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Role", "user")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		TicketID int64 `json:"ticket_id"`
+		Ticket   struct {
+			ID               int64     `json:"id"`
+			EventID          int64     `json:"event_id"`
+			Status           string    `json:"status"`
+			Quantity         int       `json:"quantity"`
+			ExpiresAt        time.Time `json:"expires_at"`
+			ConfirmationCode string    `json:"confirmation_code"`
+			AmountDue        int       `json:"amount_due"`
+		} `json:"ticket"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	got := resp.Ticket
+	if got.ID == 0 || got.ID != resp.TicketID || got.EventID != evt.ID || got.Status != "reserved" || got.Quantity != 2 {
+		t.Errorf("Unexpected ticket: %+v", got)
+	}
+	if want := clock.Now().Add(defaultHoldDuration); !got.ExpiresAt.Equal(want) {
+		t.Errorf("Expected expires_at %v (5 minutes out), got %v", want, got.ExpiresAt)
+	}
+	if got.AmountDue != 5000 {
+		t.Errorf("Expected amount_due 5000, got %d", got.AmountDue)
+	}
+	if len(got.ConfirmationCode) != 8 {
+		t.Errorf("Expected an 8 character confirmation code, got %q", got.ConfirmationCode)
+	}
+}
+
+func TestListEventsFilterByTag(t *testing.T) {
+	h := &Handlers{DB: NewTestDB(t), AllowHeaderRole: true}
+	router := h.Routes()
+
+	for _, body := range []string{
+		`{"name":"Jazz Night","total_spots":5,"tags":["Music","jazz","music"]}`,
+		`{"name":"Rock Fest","total_spots":5,"tags":["music","outdoor"]}`,
+		`{"name":"Hackathon","total_spots":5,"tags":[" Tech "]}`,
+		`{"name":"Untagged","total_spots":5}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Role", "organizer")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"name":"Bad","total_spots":5,"tags":["no,commas"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Role", "organizer")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid tag, got %d", rec.Code)
+	}
+
+	list := func(tag string) []Event {
+		rec := httptest.NewRecorder()
+		h.HandleListEvents(rec, httptest.NewRequest(http.MethodGet, "/events?tag="+tag, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		var events []Event
+		if err := json.NewDecoder(rec.Body).Decode(&events); err != nil {
+			t.Fatalf("Failed to decode events: %v", err)
+		}
+		return events
+	}
+
+	music := list("MUSIC")
+	if len(music) != 2 || music[0].Name != "Jazz Night" || music[1].Name != "Rock Fest" {
+		t.Fatalf("Expected Jazz Night and Rock Fest for music, got %+v", music)
+	}
+	if got := strings.Join(music[0].Tags, ","); got != "jazz,music" {
+		t.Errorf("Expected normalised tags jazz,music, got %q", got)
+	}
+	if tech := list("tech"); len(tech) != 1 || tech[0].Name != "Hackathon" {
+		t.Errorf("Expected only Hackathon for tech, got %+v", tech)
+	}
+	if none := list("sports"); len(none) != 0 {
+		t.Errorf("Expected no events for sports, got %+v", none)
+	}
+	if all := list(""); len(all) != 4 {
+		t.Errorf("Expected all 4 events without a tag filter, got %d", len(all))
+	}
+}
+
+func TestEventCurrency(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db, BaseCurrency: "GBP", AllowHeaderRole: true}).Routes()
+
+	post := func(path, role, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Role", role)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/events", "organizer", `{"name":"Bad","total_spots":5,"price_cents":100,"currency":"XYZ"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown currency, got %d", rec.Code)
+	}
+
+	for _, tt := range []struct{ body, want string }{
+		{`{"name":"Berlin","total_spots":5,"price_cents":1500,"currency":"eur"}`, "EUR"},
+		{`{"name":"London","total_spots":5,"price_cents":1500}`, "GBP"},
+	} {
+		rec := post("/events", "organizer", tt.body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var evt Event
+		if err := json.NewDecoder(rec.Body).Decode(&evt); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if evt.Currency != tt.want {
+			t.Fatalf("Expected currency %s, got %q", tt.want, evt.Currency)
+		}
+
+		rec = post(fmt.Sprintf("/events/%d/register", evt.ID), "user", `{"email":"intl@example.com","idempotency_key":"intl_`+tt.want+`","quantity":2}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Ticket struct {
+				AmountDue int    `json:"amount_due"`
+				Currency  string `json:"currency"`
+			} `json:"ticket"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.Ticket.AmountDue != 3000 || resp.Ticket.Currency != tt.want {
+			t.Errorf("Expected 3000 %s due, got %d %q", tt.want, resp.Ticket.AmountDue, resp.Ticket.Currency)
+		}
+	}
+}
+
+func TestCancelEventRegistrations(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Rained Out", TotalSpots: 5, OrganizerEmail: "host@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	var ids []int64
+	for i := 0; i < 4; i++ {
+		ticket, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: fmt.Sprintf("rain%d@example.com", i), IdempotencyKey: fmt.Sprintf("rain_%d", i)})
+		if err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+		ids = append(ids, ticket.ID)
+	}
+	// One confirmed, one reserved, one cancelled by its owner, one lapsed and reclaimed
+	if err := db.ConfirmReservation(ctx, ids[0], "rain0@example.com", ""); err != nil {
+		t.Fatalf("Failed to confirm: %v", err)
+	}
+	if err := db.CancelTicket(ctx, ids[2], "rain2@example.com"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE tickets SET status = 'cancelled' WHERE id = ?`, ids[3]); err != nil {
+		t.Fatalf("Failed to expire ticket: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE events SET available_spots = available_spots + 1 WHERE id = ?`, evt.ID); err != nil {
+		t.Fatalf("Failed to return reclaimed spot: %v", err)
+	}
+
+	cancel := func(email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/events/%d/cancel-registrations", evt.ID), nil)
+		req.Header.Set("X-Role", "organizer")
+		req.Header.Set("X-User-Email", email)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := cancel("intruder@example.com"); rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 for another organizer, got %d", rec.Code)
+	}
+
+	rec := cancel("host@example.com")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Cancelled int `json:"cancelled"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Cancelled != 2 {
+		t.Errorf("Expected only the 2 active tickets cancelled, got %d", resp.Cancelled)
+	}
+
+	for _, id := range ids {
+		ticket, err := db.GetTicket(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to load ticket: %v", err)
+		}
+		if ticket.Status != "cancelled" {
+			t.Errorf("Ticket %d: expected cancelled, got %q", id, ticket.Status)
+		}
+	}
+	got, err := db.GetEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to load event: %v", err)
+	}
+	if got.AvailableSpots != got.TotalSpots {
+		t.Errorf("Expected availability fully restored to %d, got %d", got.TotalSpots, got.AvailableSpots)
+	}
+}
+
+func TestSoldOutRegistrationSuggestsAlternatives(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	ctx := context.Background()
+	day := func(d int) *time.Time {
+		ts := time.Date(2030, 5, d, 18, 0, 0, 0, time.UTC)
+		return &ts
+	}
+	past := db.Clock.Now().Add(-24 * time.Hour)
+
+	full, err := db.CreateEvent(ctx, Event{Name: "Jazz Night", TotalSpots: 1, StartsAt: day(10), Tags: []string{"jazz"}})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	for _, e := range []Event{
+		{Name: "Far Jazz", TotalSpots: 5, StartsAt: day(28), Tags: []string{"jazz"}},
+		{Name: "Near Rock", TotalSpots: 5, StartsAt: day(11), Tags: []string{"rock"}},
+		{Name: "Nearer Folk", TotalSpots: 5, StartsAt: day(10), Tags: []string{"folk"}},
+		{Name: "Also Full", TotalSpots: 1, StartsAt: day(10), Tags: []string{"jazz"}},
+		{Name: "Undated", TotalSpots: 5},
+		{Name: "Past Jazz", TotalSpots: 5, StartsAt: &past, Tags: []string{"jazz"}},
+	} {
+		created, err := db.CreateEvent(ctx, e)
+		if err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+		if e.Name == "Also Full" {
+			if _, err := db.ExecContext(ctx, `UPDATE events SET available_spots = 0 WHERE id = ?`, created.ID); err != nil {
+				t.Fatalf("Failed to fill event: %v", err)
+			}
+		}
+	}
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: full.ID, Email: "first@example.com", IdempotencyKey: "first_key"}); err != nil {
+		t.Fatalf("Failed to fill event: %v", err)
+	}
+
+	register := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events/x/register"+query, strings.NewReader(`{"email":"late@example.com","idempotency_key":"late_key"}`))
+		req.SetPathValue("id", fmt.Sprint(full.ID))
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+		return rec
+	}
+
+	if rec := register(""); rec.Code != http.StatusConflict || strings.Contains(rec.Body.String(), "alternatives") {
+		t.Errorf("Expected a plain 409 without suggest, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := register("?suggest=true")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d", rec.Code)
+	}
+	var body struct {
+		Error        string  `json:"error"`
+		Alternatives []Event `json:"alternatives"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Error != ErrSoldOut.Error() {
+		t.Errorf("Expected the sold-out error, got %q", body.Error)
+	}
+	var names []string
+	for _, e := range body.Alternatives {
+		names = append(names, e.Name)
+	}
+	// Same tag first, then closest start; full and past events and the rest are left out
+	if want := []string{"Far Jazz", "Nearer Folk", "Near Rock"}; fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("Expected alternatives %v, got %v", want, names)
+	}
+}
+
+func TestHandleRegisterMarksIdempotentReplays(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Flaky Network", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	register := func(email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events/x/register", strings.NewReader(fmt.Sprintf(`{"email":%q}`, email)))
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		req.Header.Set("Idempotency-Key", "replay-key-1")
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+		return rec
+	}
+	ticketID := func(rec *httptest.ResponseRecorder) int64 {
+		var body struct {
+			TicketID int64 `json:"ticket_id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return body.TicketID
+	}
+
+	first := register("retry@example.com")
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotency-Replayed") != "" {
+		t.Fatalf("Expected a fresh 201 without the replay header, got %d %q", first.Code, first.Header().Get("Idempotency-Replayed"))
+	}
+
+	replay := register("retry@example.com")
+	if replay.Code != http.StatusCreated || replay.Header().Get("Idempotency-Replayed") != "true" {
+		t.Fatalf("Expected a 201 marked as replayed, got %d %q", replay.Code, replay.Header().Get("Idempotency-Replayed"))
+	}
+	if ticketID(replay) != ticketID(first) {
+		t.Errorf("Expected the replay to return ticket %d, got %d", ticketID(first), ticketID(replay))
+	}
+
+	// The same key for someone else is a conflict, not a replay
+	if other := register("other@example.com"); other.Code != http.StatusConflict || other.Header().Get("Idempotency-Replayed") != "" {
+		t.Errorf("Expected 409 without the replay header for a different email, got %d", other.Code)
+	}
+	if strings.Contains(replay.Body.String(), "confirmation_code") {
+		t.Errorf("Expected the replay to leave out the confirmation code, got %s", replay.Body.String())
+	}
+}
+
+func TestHandleRegisterDoesNotReplayDeadTickets(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	h := &Handlers{DB: db}
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Flaky Network", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	register := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events/x/register", strings.NewReader(`{"email":"retry@example.com"}`))
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+		return rec
+	}
+
+	// A lapsed hold is not replayed as if it were still reserved
+	if rec := register("lapsed-key"); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	clock.Advance(defaultHoldDuration + time.Second)
+	if rec := register("lapsed-key"); rec.Code != http.StatusConflict || rec.Header().Get("Idempotency-Replayed") != "" {
+		t.Errorf("Expected 409 for a lapsed hold, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Nor is a cancelled one, even after the attendee registered again under a new key
+	if _, err := db.ExecContext(context.Background(), `UPDATE tickets SET status = 'cancelled'`); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+	if rec := register("fresh-key"); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 for a new key, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := register("lapsed-key"); rec.Code != http.StatusConflict || rec.Header().Get("Idempotency-Replayed") != "" {
+		t.Errorf("Expected 409 for a cancelled ticket's key, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleListEventsByIDs(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	var ids []int64
+	for _, name := range []string{"Alpha", "Beta", "Gamma"} {
+		evt, err := db.CreateEvent(context.Background(), Event{Name: name, TotalSpots: 5})
+		if err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+		ids = append(ids, evt.ID)
+	}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.HandleListEvents(rec, httptest.NewRequest(http.MethodGet, "/events?ids="+query, nil))
+		return rec
+	}
+
+	rec := list(fmt.Sprintf("%d,9999,%d,%d,%d", ids[2], ids[0], ids[2], ids[1]))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var events []Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatalf("Failed to decode events: %v", err)
+	}
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Name)
+	}
+	if want := []string{"Gamma", "Alpha", "Beta"}; fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("Expected %v in request order without the missing id, got %v", want, names)
+	}
+
+	if rec := list("9998,9999"); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("Expected an empty array when no id exists, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := list("1,abc"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed id, got %d", rec.Code)
+	}
+}
+
+func TestHandleListEventsFiltersByStartRange(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	day := func(d int) *time.Time {
+		at := time.Date(2031, 3, d, 18, 0, 0, 0, time.UTC)
+		return &at
+	}
+	for _, seed := range []struct {
+		name     string
+		startsAt *time.Time
+	}{
+		{"Before", day(1)}, {"First Day", day(5)}, {"Middle", day(10)}, {"Last Day", day(15)}, {"After", day(20)}, {"Unscheduled", nil},
+	} {
+		if _, err := db.CreateEvent(context.Background(), Event{Name: seed.name, TotalSpots: 5, StartsAt: seed.startsAt}); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+	}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.HandleListEvents(rec, httptest.NewRequest(http.MethodGet, "/events"+query, nil))
+		return rec
+	}
+	names := func(rec *httptest.ResponseRecorder) string {
+		var page struct {
+			Data []Event `json:"data"`
+			Meta ListMeta
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Failed to decode events: %v", err)
+		}
+		var got []string
+		for _, e := range page.Data {
+			got = append(got, e.Name)
+		}
+		return fmt.Sprintf("%v total=%d", got, page.Meta.Total)
+	}
+
+	// Both bounds are inclusive and combine with paging
+	rec := list("?envelope=true&from=2031-03-05T18:00:00Z&to=2031-03-15T18:00:00Z")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, want := names(rec), "[First Day Middle Last Day] total=3"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if got, want := names(list("?envelope=true&limit=1&from=2031-03-05T18:00:00Z&to=2031-03-15T18:00:00Z")), "[First Day] total=3"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if got, want := names(list("?envelope=true&from=2031-03-12T00:00:00%2B02:00")), "[Last Day After] total=2"; got != want {
+		t.Errorf("Expected %s for an open-ended range, got %s", want, got)
+	}
+
+	if rec := list("?from=2031-03-15T00:00:00Z&to=2031-03-05T00:00:00Z"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when from is after to, got %d", rec.Code)
+	}
+	if rec := list("?from=March"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed bound, got %d", rec.Code)
+	}
+}
+
+func TestRejectOrganizerSelfRegistration(t *testing.T) {
+	db := NewTestDB(t)
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Own Show", TotalSpots: 10, OrganizerEmail: "host@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	register := func(h *Handlers, email, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events/x/register", strings.NewReader(fmt.Sprintf(`{"email":%q,"idempotency_key":%q}`, email, key)))
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+		return rec
+	}
+
+	strict := &Handlers{DB: db, RejectOrganizerSelfRegistration: true}
+	rec := register(strict, "Host@Example.com", "self_1")
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "organizer_self_registration") {
+		t.Errorf("Expected 409 organizer_self_registration, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := register(strict, "fan@example.com", "fan_1"); rec.Code != http.StatusCreated {
+		t.Errorf("Expected other attendees to register, got %d", rec.Code)
+	}
+
+	// The rule is off by default
+	if rec := register(&Handlers{DB: db}, "host@example.com", "self_2"); rec.Code != http.StatusCreated {
+		t.Errorf("Expected the organizer to register with the rule disabled, got %d", rec.Code)
+	}
+}
+
+// stalledSender never returns until released, ignoring its context like a hung SMTP client.
+type stalledSender struct {
+	release chan struct{}
+}
+
+func (s stalledSender) SendConfirmationCode(ctx context.Context, to string, ticket *Ticket) error {
+	<-s.release
+	return nil
+}
+
+func (s stalledSender) SendSoldOutNotice(ctx context.Context, to string, event *Event) error {
+	<-s.release
+	return nil
+}
+
+func TestSlowEmailSenderIsAbandonedAfterTimeout(t *testing.T) {
+	sender := stalledSender{release: make(chan struct{})}
+	defer close(sender.release)
+	h := &Handlers{DB: NewTestDB(t), Email: sender, EmailTimeout: 50 * time.Millisecond}
+
+	start := time.Now()
+	h.sendConfirmationCode(context.Background(), &Ticket{ID: 1, UserEmail: "patient@example.com", ConfirmationCode: "ABCD2345"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the send to be abandoned after the timeout, blocked for %s", elapsed)
+	}
+
+	err := h.deliver(context.Background(), func(ctx context.Context) error {
+		return sender.SendConfirmationCode(ctx, "patient@example.com", nil)
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error for the abandoned send, got %v", err)
+	}
+}
+
+func TestRegisterRoundTripsTicketMetadata(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Catered Dinner", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	register := func(key, metadata string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"email":"%s@example.com","idempotency_key":"%s","metadata":%s}`, key, key, metadata)
+		req := httptest.NewRequest(http.MethodPost, "/events/x/register", strings.NewReader(body))
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+		return rec
+	}
+
+	rec := register("diner", `{"dietary": "vegan", "wheelchair": true, "guests": ["Ann"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Ticket Ticket `json:"ticket"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode ticket: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/tickets/x?email=diner@example.com", nil)
+	req.SetPathValue("id", fmt.Sprint(created.Ticket.ID))
+	rec = httptest.NewRecorder()
+	h.HandleGetTicket(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 looking up the ticket, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var fetched struct {
+		Metadata struct {
+			Dietary    string   `json:"dietary"`
+			Wheelchair bool     `json:"wheelchair"`
+			Guests     []string `json:"guests"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &fetched); err != nil {
+		t.Fatalf("Failed to decode ticket: %v", err)
+	}
+	if m := fetched.Metadata; m.Dietary != "vegan" || !m.Wheelchair || len(m.Guests) != 1 || m.Guests[0] != "Ann" {
+		t.Errorf("Expected the metadata back as registered, got %+v", m)
+	}
+
+	// Without metadata the field is left out entirely
+	if rec := register("plain", `null`); rec.Code != http.StatusCreated || strings.Contains(rec.Body.String(), "metadata") {
+		t.Errorf("Expected 201 without a metadata field, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for name, metadata := range map[string]string{
+		"not an object": `["vegan"]`,
+		"too large":     fmt.Sprintf(`{"notes":%q}`, strings.Repeat("x", maxMetadataBytes)),
+	} {
+		if rec := register("bad", metadata); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected 422, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestListEventsReportsTotalCount(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	for i := range 7 {
+		if _, err := db.CreateEvent(context.Background(), Event{Name: fmt.Sprintf("Event %d", i), TotalSpots: 5}); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		method, path string
+	}{
+		{http.MethodHead, "/events"},
+		{http.MethodGet, "/events"},
+		{http.MethodGet, "/events?envelope=true&limit=2"},
+	} {
+		rec := httptest.NewRecorder()
+		h.HandleListEvents(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d", tc.method, tc.path, rec.Code)
+		}
+		if got := rec.Header().Get("X-Total-Count"); got != "7" {
+			t.Errorf("%s %s: expected X-Total-Count 7, got %q", tc.method, tc.path, got)
+		}
+		if tc.method == http.MethodHead && rec.Body.Len() != 0 {
+			t.Errorf("Expected HEAD to have no body, got %q", rec.Body.String())
+		}
+	}
+}
+
+func TestConfirmNonceIsSingleUse(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db, RequireConfirmNonce: true}
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Guarded Gala", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	type registration struct {
+		TicketID     int64  `json:"ticket_id"`
+		ConfirmNonce string `json:"confirm_nonce"`
+	}
+	register := func() registration {
+		req := httptest.NewRequest(http.MethodPost, "/events/x/register", strings.NewReader(`{"email":"nonce@example.com","idempotency_key":"nonce_1"}`))
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		rec := httptest.NewRecorder()
+		h.HandleRegister(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var registered registration
+		if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return registered
+	}
+	lost := register()
+	if lost.ConfirmNonce == "" {
+		t.Fatal("Expected a confirm_nonce in the registration response")
+	}
+	// The client never saw that response and retries; the replay carries a fresh nonce
+	// that replaces the first
+	registered := register()
+	if registered.TicketID != lost.TicketID || registered.ConfirmNonce == "" || registered.ConfirmNonce == lost.ConfirmNonce {
+		t.Fatalf("Expected the replay to re-issue a new nonce for ticket %d, got %+v", lost.TicketID, registered)
+	}
+
+	confirm := func(nonce string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"email":"nonce@example.com","confirm_nonce":%q}`, nonce)
+		req := httptest.NewRequest(http.MethodPost, "/tickets/x/confirm", strings.NewReader(body))
+		req.SetPathValue("id", fmt.Sprint(registered.TicketID))
+		rec := httptest.NewRecorder()
+		h.HandleConfirm(rec, req)
+		return rec
+	}
+
+	for _, nonce := range []string{"", "not-the-nonce", lost.ConfirmNonce} {
+		if rec := confirm(nonce); rec.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 confirming with nonce %q, got %d", nonce, rec.Code)
+		}
+	}
+	if rec := confirm(registered.ConfirmNonce); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with the issued nonce, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The captured request replayed verbatim is refused
+	rec := confirm(registered.ConfirmNonce)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "invalid_confirm_nonce") {
+		t.Errorf("Expected 403 invalid_confirm_nonce on replay, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// Once confirmed, a replayed registration has no nonce to hand out
+	if again := register(); again.ConfirmNonce != "" {
+		t.Errorf("Expected no confirm_nonce replaying a confirmed registration, got %q", again.ConfirmNonce)
+	}
+}
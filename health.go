@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds the database ping made by /ready.
+const readinessTimeout = 2 * time.Second
+
+// HandleHealthz handles GET /healthz, a liveness probe: answering at all is the signal.
+func (h *Handlers) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	SendJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// HandleReady handles GET /ready, a readiness probe. It fails while the instance is
+// draining or cannot reach its database, so load balancers stop routing to it.
+func (h *Handlers) HandleReady(w http.ResponseWriter, r *http.Request) {
+	if h.Draining() {
+		w.Header().Set("Retry-After", drainRetryAfterSeconds)
+		SendJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	if err := h.DB.PingContext(ctx); err != nil {
+		SendJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "database unavailable", "error": err.Error()})
+		return
+	}
+
+	SendJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
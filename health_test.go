@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestReadinessFollowsDrainMode(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	if rec := serve(router, http.MethodGet, "/ready", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 before draining, got %d", rec.Code)
+	}
+
+	if rec := serve(router, http.MethodPost, "/admin/drain", "admin"); rec.Code != http.StatusOK {
+		t.Fatalf("Failed to drain: %d", rec.Code)
+	}
+	rec := serve(router, http.MethodGet, "/ready", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 with Retry-After while draining, got %d", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/healthz", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected liveness to stay 200 while draining, got %d", rec.Code)
+	}
+
+	if rec := serve(router, http.MethodPost, "/admin/undrain", "admin"); rec.Code != http.StatusOK {
+		t.Fatalf("Failed to undrain: %d", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/ready", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 after undraining, got %d", rec.Code)
+	}
+}
+
+func TestReadinessFailsWithoutDatabase(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db}).Routes()
+	db.Close()
+
+	if rec := serve(router, http.MethodGet, "/ready", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with the database closed, got %d", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/healthz", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected liveness to stay 200, got %d", rec.Code)
+	}
+}
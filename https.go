@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHSTSMaxAge is how long browsers remember to use HTTPS only.
+const defaultHSTSMaxAge = 365 * 24 * time.Hour
+
+// HTTPSConfig enforces TLS behind a TLS-terminating proxy, which reports the client's
+// scheme in X-Forwarded-Proto. The zero value changes nothing.
+type HTTPSConfig struct {
+	// HSTSMaxAge is sent as Strict-Transport-Security on HTTPS responses; 0 omits it.
+	// Browsers ignore the header over plain HTTP, so it is never sent there.
+	HSTSMaxAge time.Duration
+	// Require redirects plain-HTTP GET and HEAD requests to their https URL and rejects
+	// other methods with 403, since their body has already crossed the wire unencrypted.
+	Require bool
+}
+
+// isHTTPS reports whether the client reached us over TLS, directly or via the proxy.
+func isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
+// HTTPSMiddleware applies cfg. Health probes are exempt from -require-https, because load
+// balancers usually call them directly over HTTP.
+func HTTPSMiddleware(cfg HTTPSConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if cfg.HSTSMaxAge <= 0 && !cfg.Require {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHTTPS(r) {
+				if cfg.HSTSMaxAge > 0 {
+					w.Header().Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(cfg.HSTSMaxAge.Seconds())))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !cfg.Require || r.URL.Path == "/healthz" || r.URL.Path == "/ready" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				SendJSON(w, http.StatusForbidden, map[string]string{"error": "HTTPS is required"})
+				return
+			}
+			http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireHTTPSRedirectsPlainRequests(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t), HTTPS: HTTPSConfig{Require: true, HSTSMaxAge: defaultHSTSMaxAge}}).Routes()
+
+	send := func(method, path, proto string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Host = "tickets.example.com"
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodGet, "/events?tag=music", "http")
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Fatalf("Expected 308 for plain HTTP, got %d", rec.Code)
+	}
+	if got, want := rec.Header().Get("Location"), "https://tickets.example.com/events?tag=music"; got != want {
+		t.Errorf("Expected redirect to %q, got %q", want, got)
+	}
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("Expected no HSTS header over plain HTTP")
+	}
+
+	// A body has already been sent in the clear, so writes are refused rather than redirected
+	if rec := send(http.MethodPost, "/events/1/register", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a plain HTTP POST, got %d", rec.Code)
+	}
+
+	// Load balancer probes reach the app directly
+	if rec := send(http.MethodGet, "/healthz", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected /healthz to be served over HTTP, got %d", rec.Code)
+	}
+
+	rec = send(http.MethodGet, "/events", "https")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 over HTTPS, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("Expected HSTS max-age=31536000, got %q", got)
+	}
+}
+
+func TestHSTSWithoutRequireHTTPS(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t), HTTPS: HTTPSConfig{HSTSMaxAge: defaultHSTSMaxAge}}).Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected plain HTTP to be served when HTTPS is not required, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Header().Get("Strict-Transport-Security") == "" {
+		t.Errorf("Expected an HSTS header on HTTPS responses")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const icalTimeFormat = "20060102T150405Z"
+
+// icalEscaper escapes TEXT values per RFC 5545 section 3.3.11.
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// RenderICal renders a single-event VCALENDAR for evt. The event must have a start time.
+// now is used for the mandatory DTSTAMP property.
+func RenderICal(evt *Event, now time.Time) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Event Registration API//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:event-%d@event-registration-api", evt.ID),
+		"DTSTAMP:" + now.UTC().Format(icalTimeFormat),
+		"DTSTART:" + evt.StartsAt.UTC().Format(icalTimeFormat),
+		"SUMMARY:" + icalEscaper.Replace(evt.Name),
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(foldICalLine(line))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// foldICalLine splits content lines longer than 75 octets, continuing them with a
+// leading space as required by RFC 5545 section 3.1. It never splits a UTF-8 sequence.
+func foldICalLine(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxImportBytes caps an uploaded CSV, multipart framing included.
+const maxImportBytes = 5 << 20
+
+// ImportRowError reports why one CSV line was rejected. Line is the 1-based line in the
+// file, so the header is line 1 and the first event is line 2.
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// HandleImportEvents handles POST /events/import. The multipart "file" field holds a CSV
+// whose header names the columns name, total_spots and (optionally) starts_at, in any order.
+// Every row is validated first; a single bad row rejects the whole file.
+func (h *Handlers) HandleImportEvents(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			SendJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("Import file must be at most %d bytes", maxImportBytes)})
+			return
+		}
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Expected multipart/form-data with a CSV in the 'file' field"})
+		return
+	}
+	defer file.Close()
+
+	events, rowErrors, err := h.parseEventsCSV(file)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(rowErrors) > 0 {
+		SendJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error": "Import rejected, no events were created",
+			"rows":  rowErrors,
+		})
+		return
+	}
+	if len(events) == 0 {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "CSV contains no events"})
+		return
+	}
+
+	if !h.enforceEventQuota(w, r, len(events)) {
+		return
+	}
+
+	organizer := EmailFromContext(r.Context())
+	for i := range events {
+		events[i].OrganizerEmail = organizer
+		events[i].Currency = h.baseCurrency()
+	}
+
+	created, err := h.DB.CreateEvents(r.Context(), events)
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	h.EventCache.Invalidate()
+
+	SendJSON(w, http.StatusCreated, map[string]interface{}{
+		"imported": len(created),
+		"events":   created,
+	})
+}
+
+// parseEventsCSV reads and validates every row. A non-nil error means the file as a whole
+// is unusable (e.g. a missing header); row problems are collected instead.
+func (h *Handlers) parseEventsCSV(src io.Reader) ([]Event, []ImportRowError, error) {
+	reader := csv.NewReader(src)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "total_spots"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV header must include a %q column", required)
+		}
+	}
+
+	var events []Event
+	var rowErrors []ImportRowError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+			}
+			rowErrors = append(rowErrors, ImportRowError{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		evt, err := h.eventFromRecord(record, columns)
+		if err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Line: line, Error: err.Error()})
+			continue
+		}
+		events = append(events, evt)
+	}
+	return events, rowErrors, nil
+}
+
+// eventFromRecord applies the same rules as HandleCreateEvent to a single CSV row.
+func (h *Handlers) eventFromRecord(record []string, columns map[string]int) (Event, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	evt := Event{Name: field("name")}
+	if evt.Name == "" {
+		return Event{}, errors.New("name is required")
+	}
+
+	spots, err := strconv.Atoi(field("total_spots"))
+	if err != nil || spots <= 0 {
+		return Event{}, fmt.Errorf("total_spots must be a positive integer, got %q", field("total_spots"))
+	}
+	if err := h.checkCapacityBounds(spots); err != nil {
+		return Event{}, err
+	}
+	evt.TotalSpots = spots
+
+	if raw := field("starts_at"); raw != "" {
+		startsAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return Event{}, fmt.Errorf("starts_at must be an RFC3339 timestamp, got %q", raw)
+		}
+		evt.StartsAt = &startsAt
+	}
+	return evt, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func importCSV(t *testing.T, router http.Handler, csvBody string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	part, err := form.CreateFormFile("file", "events.csv")
+	if err != nil {
+		t.Fatalf("Failed to build form: %v", err)
+	}
+	part.Write([]byte(csvBody))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/events/import", &buf)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-Role", "organizer")
+	req.Header.Set("X-User-Email", "migrator@example.com")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImportEventsRollsBackOnInvalidRow(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	rec := importCSV(t, router, "name,total_spots,starts_at\n"+
+		"Spring Meetup,40,2030-04-01T18:00:00Z\n"+
+		"Summer Social,lots,\n"+
+		"Autumn Talk,25,\n")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Rows []ImportRowError `json:"rows"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Rows) != 1 || resp.Rows[0].Line != 3 {
+		t.Fatalf("Expected a single error on line 3, got %+v", resp.Rows)
+	}
+
+	events, err := db.ListEvents(context.Background(), EventFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected the whole import to be rolled back, found %d events", len(events))
+	}
+}
+
+func TestImportEventsCreatesAllRows(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	rec := importCSV(t, router, "total_spots,name\n10,Workshop A\n20,Workshop B\n")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	events, err := db.ListEvents(context.Background(), EventFilter{OrganizerEmail: "migrator@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	if len(events) != 2 || events[1].Name != "Workshop B" || events[1].TotalSpots != 20 {
+		t.Errorf("Expected both workshops owned by the importer, got %+v", events)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testJWTSecret = []byte("test-secret")
+
+// signJWT issues an HS256 token the way an identity provider would.
+func signJWT(t *testing.T, claims Claims, secret []byte) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Failed to encode claims: %v", err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseJWT(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	valid := signJWT(t, Claims{Subject: "u1", Role: "admin", ExpiresAt: now.Add(time.Hour).Unix()}, testJWTSecret)
+
+	claims, err := ParseJWT(valid, testJWTSecret, now)
+	if err != nil || claims.Role != "admin" || claims.Subject != "u1" {
+		t.Fatalf("Expected valid admin claims, got %+v (%v)", claims, err)
+	}
+
+	unsignedNone := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"role":"admin"}`)) + "."
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"wrong secret", signJWT(t, Claims{Role: "admin"}, []byte("other")), ErrTokenSignature},
+		{"alg none", unsignedNone, ErrTokenMalformed},
+		{"expired", signJWT(t, Claims{Role: "admin", ExpiresAt: now.Unix()}, testJWTSecret), ErrTokenExpired},
+		{"garbage", "not-a-token", ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseJWT(tt.token, testJWTSecret, now); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRateLimitExemptsVerifiedPrivilegedRoles(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t), JWTSecret: testJWTSecret}).Routes()
+	adminToken := signJWT(t, Claims{Subject: "admin-1", Role: "admin"}, testJWTSecret)
+	userToken := signJWT(t, Claims{Subject: "user-1", Role: "user"}, testJWTSecret)
+
+	list := func(remoteAddr, token, role string) int {
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if role != "" {
+			req.Header.Set("X-Role", role)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < anonymousRateLimit*4; i++ {
+		if code := list("10.0.0.1:1", adminToken, ""); code != http.StatusOK {
+			t.Fatalf("Admin request %d: expected 200, got %d", i, code)
+		}
+	}
+
+	// Neither an ordinary token nor a self-declared X-Role lifts the anonymous limit
+	for name, call := range map[string]func() int{
+		"user token":     func() int { return list("10.0.0.2:1", userToken, "") },
+		"spoofed header": func() int { return list("10.0.0.3:1", "", "admin") },
+	} {
+		for i := 0; i < anonymousRateLimit; i++ {
+			if code := call(); code != http.StatusOK {
+				t.Fatalf("%s request %d: expected 200, got %d", name, i, code)
+			}
+		}
+		if code := call(); code != http.StatusTooManyRequests {
+			t.Errorf("%s: expected 429 past the anonymous limit, got %d", name, code)
+		}
+	}
+
+	if code := list("10.0.0.4:1", "tampered."+adminToken, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an invalid token, got %d", code)
+	}
+}
+
+func TestRBACHeaderRoleOnlyWhenAllowed(t *testing.T) {
+	adminToken := signJWT(t, Claims{Subject: "admin-1", Role: "admin"}, testJWTSecret)
+	userToken := signJWT(t, Claims{Subject: "user-1", Role: "user"}, testJWTSecret)
+
+	drain := func(router http.Handler, token, role string) int {
+		req := httptest.NewRequest(http.MethodPost, "/admin/undrain", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if role != "" {
+			req.Header.Set("X-Role", role)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tt := range []struct {
+		allowHeaderRole bool
+		headerWant      int
+	}{
+		{allowHeaderRole: false, headerWant: http.StatusUnauthorized},
+		{allowHeaderRole: true, headerWant: http.StatusOK},
+	} {
+		router := (&Handlers{DB: NewTestDB(t), JWTSecret: testJWTSecret, AllowHeaderRole: tt.allowHeaderRole}).Routes()
+		if code := drain(router, "", "admin"); code != tt.headerWant {
+			t.Errorf("AllowHeaderRole=%t: X-Role admin got %d, want %d", tt.allowHeaderRole, code, tt.headerWant)
+		}
+		if code := drain(router, adminToken, ""); code != http.StatusOK {
+			t.Errorf("AllowHeaderRole=%t: admin token got %d, want 200", tt.allowHeaderRole, code)
+		}
+		// A token's role wins over the header even when headers are trusted
+		if code := drain(router, userToken, "admin"); code != http.StatusForbidden {
+			t.Errorf("AllowHeaderRole=%t: user token with X-Role admin got %d, want 403", tt.allowHeaderRole, code)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultEventListCacheTTL is how long a GET /events response may be replayed during an
+// outage when Handlers.EventCache is not configured explicitly.
+const defaultEventListCacheTTL = 30 * time.Second
+
+// maxEventListCacheEntries bounds the cache; every distinct query string is an entry.
+const maxEventListCacheEntries = 128
+
+// EventListCache remembers recent GET /events responses so a brief database outage can
+// be answered with slightly stale data instead of a 500. It is only read when the
+// database fails; healthy requests always go to the database and refresh it.
+// A nil or zero-TTL cache stores nothing.
+type EventListCache struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[string]cachedEventList
+}
+
+type cachedEventList struct {
+	body     any
+	storedAt time.Time
+}
+
+func NewEventListCache(ttl time.Duration) *EventListCache {
+	return &EventListCache{ttl: ttl, clock: RealClock{}, entries: make(map[string]cachedEventList)}
+}
+
+// Get returns the response stored under key if it is younger than the TTL.
+func (c *EventListCache) Get(key string) (any, bool) {
+	if c == nil || c.ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || c.clock.Now().Sub(entry.storedAt) > c.ttl {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+// Put stores a fresh response under key. When the cache is full, expired entries are
+// dropped first; if none have expired the new response is simply not cached.
+func (c *EventListCache) Put(key string, body any) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= maxEventListCacheEntries {
+		for k, entry := range c.entries {
+			if now.Sub(entry.storedAt) > c.ttl {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxEventListCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = cachedEventList{body: body, storedAt: now}
+}
+
+// Invalidate forgets every stored response; call it whenever events are created,
+// changed or removed so an outage never replays a list known to be out of date.
+func (c *EventListCache) Invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestListEventsServesStaleCacheWhenDBFails(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db}).Routes()
+
+	if _, err := db.CreateEvent(context.Background(), Event{Name: "Cached Concert", TotalSpots: 10}); err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	fresh := serve(router, http.MethodGet, "/events", "")
+	if fresh.Code != http.StatusOK || fresh.Header().Get("X-Cache") != "" {
+		t.Fatalf("Expected a fresh 200, got %d with X-Cache %q", fresh.Code, fresh.Header().Get("X-Cache"))
+	}
+
+	db.Close() // Every query fails from here on
+
+	stale := serve(router, http.MethodGet, "/events", "")
+	if stale.Code != http.StatusOK {
+		t.Fatalf("Expected the cached list with 200, got %d", stale.Code)
+	}
+	if stale.Header().Get("X-Cache") != "stale" {
+		t.Errorf("Expected X-Cache: stale, got %q", stale.Header().Get("X-Cache"))
+	}
+	if stale.Body.String() != fresh.Body.String() {
+		t.Errorf("Expected the cached body %q, got %q", fresh.Body.String(), stale.Body.String())
+	}
+
+	// A query that was never cached still fails loudly
+	if rec := serve(router, http.MethodGet, "/events?sort=created_at", ""); rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 for an uncached query, got %d", rec.Code)
+	}
+}
+
+func TestEventListCacheExpiryAndInvalidation(t *testing.T) {
+	clock := NewMockClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewEventListCache(time.Minute)
+	cache.clock = clock
+
+	cache.Put("k", []Event{{Name: "A"}})
+	if _, ok := cache.Get("k"); !ok {
+		t.Fatal("Expected a hit right after Put")
+	}
+
+	cache.Invalidate()
+	if _, ok := cache.Get("k"); ok {
+		t.Error("Expected a miss after Invalidate")
+	}
+
+	cache.Put("k", []Event{{Name: "A"}})
+	clock.Advance(time.Minute + time.Second)
+	if _, ok := cache.Get("k"); ok {
+		t.Error("Expected a miss once the TTL has passed")
+	}
+
+	var disabled *EventListCache
+	disabled.Put("k", nil)
+	if _, ok := disabled.Get("k"); ok {
+		t.Error("Expected a nil cache to never hit")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerRespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "warn", "json")
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+
+	logger.Info("should be suppressed")
+	if buf.Len() != 0 {
+		t.Fatalf("Expected info log to be suppressed at warn level, got %q", buf.String())
+	}
+
+	logger.Warn("should be written")
+	if !strings.Contains(buf.String(), "should be written") {
+		t.Errorf("Expected warn log in output, got %q", buf.String())
+	}
+}
+
+func TestNewLoggerTextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "debug", "text")
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+
+	logger.Debug("hello", "key", "value")
+	if !strings.Contains(buf.String(), "level=DEBUG") || !strings.Contains(buf.String(), "key=value") {
+		t.Errorf("Expected text formatted debug line, got %q", buf.String())
+	}
+}
+
+func TestNewLoggerRejectsUnknownValues(t *testing.T) {
+	if _, err := NewLogger(&bytes.Buffer{}, "loud", "json"); err == nil {
+		t.Error("Expected error for unknown log level")
+	}
+	if _, err := NewLogger(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("Expected error for unknown log format")
+	}
+}
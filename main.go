@@ -13,22 +13,111 @@ import (
 )
 
 func main() {
-	// Setup structured JSON logging
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	slog.SetDefault(logger)
-
 	// We can pass DSN from command line
 	dsn := flag.String("dsn", "file:events.db?cache=shared&mode=rwc", "SQLite DSN")
+	memory := flag.Bool("memory", false, "Keep all data in memory for the life of the process instead of -dsn (demos and tests)")
+	cacheSizeKiB := flag.Int("sqlite-cache-size-kib", 64<<10, "SQLite page cache per connection in KiB (0 = SQLite default of ~2 MiB)")
+	mmapSize := flag.Int64("sqlite-mmap-size", 256<<20, "SQLite memory-mapped I/O size in bytes (0 = disabled)")
+	idempotencyScope := flag.String("idempotency-scope", string(IdempotencyScopeGlobal), "Where registration idempotency keys must be unique: global or event")
+	pingAttempts := flag.Int("db-ping-attempts", 10, "How many times to try preparing the database directory and reaching the database at startup before exiting")
+	pingBackoff := flag.Duration("db-ping-backoff", 500*time.Millisecond, "Wait after the first failed startup ping, doubling per attempt up to 30s")
 	port := flag.String("port", ":8080", "Server Port")
+	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+	logFormat := flag.String("log-format", "json", "Log format (json, text)")
+	minCapacity := flag.Int("min-capacity", 0, "Minimum total_spots for new events (0 = no minimum)")
+	maxCapacity := flag.Int("max-capacity", 0, "Maximum total_spots for new events (0 = no maximum)")
+	maxEventsPerOrganizer := flag.Int("max-events-per-organizer", 0, "Maximum events a single organizer may own (0 = unlimited; admins are exempt)")
+	rejectDuplicateNames := flag.Bool("reject-duplicate-event-names", false, "Reject events whose name matches one of the same organizer's live events (case-insensitive)")
+	rejectSelfRegistration := flag.Bool("reject-organizer-self-registration", false, "Refuse registrations for an event under its organizer's own email")
+	requireConfirmNonce := flag.Bool("require-confirm-nonce", false, "Issue each reservation a one-time confirm_nonce that its confirmation must present")
+	maxWaitlist := flag.Int("max-waitlist", 0, "Maximum people on one event's waitlist, for events without their own max_waitlist (0 = unlimited)")
+	maxTicketQuantity := flag.Int("max-ticket-quantity", defaultMaxTicketQuantity, "Maximum spots a single registration may request")
+	holdDuration := flag.Duration("hold-duration", defaultHoldDuration, "How long a reservation is held before it lapses, for events without their own hold_seconds")
+	strictHold := flag.Bool("strict-hold-duration", false, "Refuse to start, rather than warn, when -hold-duration is under 30s")
+	baseCurrency := flag.String("base-currency", defaultCurrency, "ISO 4217 currency for events created without one")
+	holdExtension := flag.Duration("hold-extension", 0, "Extend a reserved ticket's hold by this much whenever its status is checked (0 = disabled)")
+	maxHold := flag.Duration("max-hold", 15*time.Minute, "Upper bound on a reservation's total hold when -hold-extension is enabled")
+	paymentGrace := flag.Duration("payment-grace-period", defaultPaymentGrace, "How long a reservation is held once its payment is reported pending")
+	corsOrigins := flag.String("cors-origins", "", "Comma separated origins allowed to call the API from a browser, or * (empty = CORS disabled)")
+	corsCredentials := flag.Bool("cors-credentials", false, "Send Access-Control-Allow-Credentials to allowed origins")
+	corsMaxAge := flag.Duration("cors-max-age", 10*time.Minute, "How long browsers may cache CORS preflight responses")
+	requireHTTPS := flag.Bool("require-https", false, "Redirect GET/HEAD requests whose X-Forwarded-Proto is not https to the https URL and reject other methods with 403")
+	hstsMaxAge := flag.Duration("hsts-max-age", defaultHSTSMaxAge, "Strict-Transport-Security max-age sent on HTTPS responses (0 = omit the header)")
+	eventCacheTTL := flag.Duration("event-cache-ttl", defaultEventListCacheTTL, "How old a cached GET /events response may be when served during a database outage (0 = disabled)")
+	ticketRetention := flag.Duration("ticket-retention", defaultTicketRetention, "How long cancelled tickets are kept before purging deletes them")
+	purgeInterval := flag.Duration("purge-interval", 0, "How often cancelled tickets older than -ticket-retention are deleted (0 = only on POST /admin/purge)")
+	snapshotInterval := flag.Duration("snapshot-interval", 0, "How often active events' available spots are recorded for GET /events/{id}/velocity (0 = disabled)")
+	snapshotRetention := flag.Duration("snapshot-retention", defaultSnapshotRetention, "How long capacity snapshots are kept before the snapshot worker prunes them (0 = forever)")
+	reconcileInterval := flag.Duration("reconcile-interval", 0, "How often every event's available_spots is recomputed from its tickets (0 = disabled)")
+	lowAvailability := flag.Float64("low-availability-percent", defaultLowAvailabilityPercent, "Flag events in GET /events/{id}/stats as low_availability below this percentage of spots left")
+	statsInterval := flag.Duration("stats-interval", 30*time.Second, "How often the per-event stats cache is recomputed")
+	emailTimeout := flag.Duration("email-timeout", defaultEmailTimeout, "How long to wait for the mail provider to accept one email before abandoning it")
+	allowHeaderRole := flag.Bool("allow-header-role", false, "Trust the X-Role and X-User-Email headers for requests without a JWT (migration only; never in production)")
+	enablePprof := flag.Bool("enable-pprof", false, "Expose admin-only /debug/pprof/ profiling endpoints")
 	flag.Parse()
 
+	// Setup structured logging; every middleware and worker logs through the default logger
+	logger, err := NewLogger(os.Stdout, *logLevel, *logFormat)
+	if err != nil {
+		slog.Error("invalid logging configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	cors := CORSConfig{
+		AllowedOrigins:   ParseOrigins(*corsOrigins),
+		AllowCredentials: *corsCredentials,
+		MaxAge:           *corsMaxAge,
+	}
+	if err := cors.Validate(); err != nil {
+		slog.Error("invalid CORS configuration", "error", err)
+		os.Exit(1)
+	}
+	apiKeys, err := ParseAPIKeys(os.Getenv("API_KEYS"))
+	if err != nil {
+		slog.Error("invalid API_KEYS", "error", err)
+		os.Exit(1)
+	}
+	if os.Getenv("JWT_SECRET") == "" {
+		slog.Warn("JWT_SECRET is empty; JWT authentication is disabled and bearer tokens are ignored")
+	}
+
 	// Initialize Database
-	db, err := NewDB(*dsn)
+	if *memory {
+		*dsn = MemoryDSN
+		slog.Warn("running on an in-memory database; all data is lost when the process exits")
+	}
+	db, err := NewDBWithTuning(*dsn, Tuning{
+		CacheSizeKiB: *cacheSizeKiB,
+		MmapSize:     *mmapSize,
+		PingAttempts: *pingAttempts,
+		PingBackoff:  *pingBackoff,
+	})
 	if err != nil {
 		slog.Error("failed to connect to db", "error", err)
 		os.Exit(1)
 	}
 
+	if err := Preflight(PreflightConfig{HoldDuration: *holdDuration, StrictHold: *strictHold, SnapshotInterval: *snapshotInterval}); err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	db.HoldDuration = *holdDuration
+	db.MaxWaitlist = *maxWaitlist
+
+	currency, err := ParseCurrency(*baseCurrency)
+	if err != nil {
+		slog.Error("invalid base currency", "error", err)
+		os.Exit(1)
+	}
+
+	scope, err := ParseIdempotencyScope(*idempotencyScope)
+	if err != nil {
+		slog.Error("invalid idempotency scope", "error", err)
+		os.Exit(1)
+	}
+	db.IdempotencyScope = scope
+
 	// Important: We use a short timeout for schema init to avoid pulling down the server on boot
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -44,6 +133,7 @@ func main() {
 	defer workerCancel() // Ensure worker context is cancelled on main exit
 
 	// Background Worker for Reclaiming Seats
+	reclaimStatus := &ReclaimStatus{}
 	go func() {
 		ticker := time.NewTicker(10 * time.Second)
 		defer ticker.Stop()
@@ -53,44 +143,68 @@ func main() {
 				slog.Info("reclaim expired seats worker stopping")
 				return
 			case <-ticker.C:
-				reclaimed, err := db.ReclaimExpiredSeats(context.Background())
-				if err != nil {
-					slog.Error("failed reclaimed seats worker", "error", err)
-				} else if reclaimed > 0 {
-					slog.Info("reclaimed expired seats", "count", reclaimed)
-				}
+				reclaimExpiredSeats(workerCtx, db, reclaimTimeout, reclaimStatus)
+
+				// Lapsed holds already went to the waitlist; this catches seats freed any other way
+				promoteWaitlists(workerCtx, db, reclaimTimeout)
 			}
 		}
 	}()
 
-	// Set up Handlers
-	h := &Handlers{DB: db}
+	// Background Worker keeping the dashboard stats cache warm; reads recompute if it falls behind
+	stats := NewStatsAggregator(db, 2*(*statsInterval))
+	go stats.Run(workerCtx, *statsInterval)
+
+	// Optional self-healing pass for available_spots drift
+	if *reconcileInterval > 0 {
+		go RunReconciler(workerCtx, db, *reconcileInterval)
+	}
 
-	// Standard Library Router
-	mux := http.NewServeMux()
+	// Optional housekeeping for the ever-growing tickets table
+	if *purgeInterval > 0 {
+		go RunPurger(workerCtx, db, *purgeInterval, *ticketRetention)
+	}
+	if *snapshotInterval > 0 {
+		go RunSnapshotter(workerCtx, db, *snapshotInterval, *snapshotRetention)
+	}
 
-	// Create Event (Protected: Organizer/Admin)
-	mux.Handle("POST /events", RBACMiddleware("organizer")(http.HandlerFunc(h.HandleCreateEvent)))
+	// Set up Handlers
+	h := &Handlers{
+		DB:          db,
+		MinCapacity: *minCapacity,
+		MaxCapacity: *maxCapacity,
+		EnablePprof: *enablePprof,
 
-	// List Events (Public)
-	mux.HandleFunc("GET /events", h.HandleListEvents)
+		MaxEventsPerOrganizer: *maxEventsPerOrganizer,
+		RejectDuplicateNames:  *rejectDuplicateNames,
+		MaxTicketQuantity:     *maxTicketQuantity,
 
-	// Register (Protected: User)
-	mux.Handle("POST /events/{id}/register", RBACMiddleware("user")(http.HandlerFunc(h.HandleRegister)))
+		RejectOrganizerSelfRegistration: *rejectSelfRegistration,
+		RequireConfirmNonce:             *requireConfirmNonce,
 
-	// Confirm (Protected: User)
-	mux.Handle("POST /tickets/{id}/confirm", RBACMiddleware("user")(http.HandlerFunc(h.HandleConfirm)))
+		HoldExtension: *holdExtension,
+		MaxHold:       *maxHold,
+		PaymentGrace:  *paymentGrace,
+		CORS:          cors,
+		HTTPS:         HTTPSConfig{HSTSMaxAge: *hstsMaxAge, Require: *requireHTTPS},
+		Stats:         stats,
+		Reclaim:       reclaimStatus,
+		EventCache:    NewEventListCache(*eventCacheTTL),
+		JWTSecret:     []byte(os.Getenv("JWT_SECRET")),
+		APIKeys:       apiKeys,
+		BaseCurrency:  currency,
+		EmailTimeout:  *emailTimeout,
 
-	// Apply Global Middlewares
-	var handler http.Handler = mux
-	handler = RateLimitMiddleware(handler)
-	handler = LoggingMiddleware(handler)
-	handler = RecoveryMiddleware(handler)
+		LowAvailabilityPercent: *lowAvailability,
+		TicketRetention:        *ticketRetention,
+		AllowHeaderRole:        *allowHeaderRole,
+	}
+	db.OnSoldOut = h.NotifySoldOut
 
 	// Configure Server with Timeouts
 	server := &http.Server{
 		Addr:         *port,
-		Handler:      handler,
+		Handler:      h.Routes(),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
@@ -126,3 +240,40 @@ func main() {
 
 	slog.Info("server exited cleanly")
 }
+
+// reclaimTimeout bounds one reclaim or waitlist pass so a hung database cannot stall the
+// worker or hold the single connection other requests are waiting for.
+const reclaimTimeout = 5 * time.Second
+
+// reclaimExpiredSeats runs one reclaim pass under timeout and records its outcome in
+// status. A pass that runs out of time is rolled back as a whole and retried on the next tick.
+func reclaimExpiredSeats(ctx context.Context, db *DB, timeout time.Duration, status *ReclaimStatus) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	reclaimed, err := db.ReclaimExpiredSeats(ctx)
+	status.record(db.Clock.Now(), reclaimed, err)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.Warn("reclaim expired seats timed out, rolled back", "timeout", timeout)
+	case err != nil:
+		slog.Error("failed reclaimed seats worker", "error", err)
+	case reclaimed > 0:
+		slog.Info("reclaimed expired seats", "count", reclaimed)
+	}
+}
+
+// promoteWaitlists runs one waitlist promotion pass under timeout, cancelled with ctx on
+// shutdown like the reclaim pass.
+func promoteWaitlists(ctx context.Context, db *DB, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	promoted, err := db.PromoteAllWaitlists(ctx)
+	if err != nil {
+		slog.Error("failed promoting waitlists", "error", err)
+	}
+	for eventID, emails := range promoted {
+		slog.Info("promoted waitlisted users", "event_id", eventID, "count", len(emails))
+	}
+}
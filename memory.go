@@ -0,0 +1,45 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// MemoryDSN is the database -memory runs on: one in-memory database shared by every
+// connection in the process. Nothing touches disk and everything is lost on exit.
+const MemoryDSN = "file::memory:?cache=shared"
+
+// isSharedMemoryDSN reports whether dsn names an in-memory database that connections in
+// this process share, like MemoryDSN.
+func isSharedMemoryDSN(dsn string) bool {
+	_, query, onDisk := databaseFile(dsn)
+	return !onDisk && query.Get("cache") == "shared"
+}
+
+// openMemoryAnchor opens an idle connection that keeps a shared in-memory database alive.
+// SQLite frees such a database as soon as its last connection closes, and database/sql may
+// close the pool's one connection at any time, e.g. after a driver error, which would
+// silently wipe the schema and every row. The anchor never runs a query, so it takes no
+// locks and the pool keeps its single connection to itself.
+func openMemoryAnchor(dsn string) (*sql.DB, error) {
+	anchor, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open in-memory database: %w", err)
+	}
+	anchor.SetMaxOpenConns(1)
+	anchor.SetMaxIdleConns(1)
+	if err := anchor.Ping(); err != nil {
+		anchor.Close()
+		return nil, fmt.Errorf("failed to open in-memory database: %w", err)
+	}
+	return anchor, nil
+}
+
+// Close closes the database. For a shared in-memory database that also discards its contents.
+func (db *DB) Close() error {
+	err := db.DB.Close()
+	if db.memoryAnchor != nil {
+		db.memoryAnchor.Close()
+	}
+	return err
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"log/slog"
+	"mime"
 	"net/http"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 // responseWriter is a minimal wrapper for http.ResponseWriter that allows the
-// written HTTP status code to be captured for logging.
+// written HTTP status code and body size to be captured for logging.
 type responseWriter struct {
 	http.ResponseWriter
 	status      int
 	wroteHeader bool
+	bytes       int64
 }
 
 func wrapResponseWriter(w http.ResponseWriter) *responseWriter {
@@ -33,49 +42,184 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.wroteHeader = true
 }
 
-// LoggingMiddleware logs the incoming HTTP request & its duration.
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// BytesWritten is the number of body bytes written so far.
+func (rw *responseWriter) BytesWritten() int64 {
+	return rw.bytes
+}
+
+// WroteHeader reports whether the response status has already been sent.
+func (rw *responseWriter) WroteHeader() bool {
+	return rw.wroteHeader
+}
+
+// Write records the implicit 200 the underlying writer sends on a first Write.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// accessIdentity is filled in by RBACMiddleware, deeper in the chain, so that
+// LoggingMiddleware can say who made the request once it returns.
+type accessIdentity struct {
+	role, email string
+}
+
+// hashEmail pseudonymises an address for logs: requests by the same user still
+// correlate, but the address itself is never written out.
+func hashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:8])
+}
+
+// LoggingMiddleware logs the incoming HTTP request & its duration, along with the
+// caller's role and hashed email when the route required one and the response size.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		wrapped := wrapResponseWriter(w)
+		identity := &accessIdentity{}
 
-		next.ServeHTTP(wrapped, r)
+		next.ServeHTTP(wrapped, r.WithContext(context.WithValue(r.Context(), accessIdentityContextKey, identity)))
 
-		slog.Info("http request",
+		attrs := []any{
+			"request_id", RequestIDFromContext(r.Context()),
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", wrapped.status,
+			"size", wrapped.BytesWritten(),
 			"duration", time.Since(start),
-		)
+		}
+		if identity.role != "" {
+			attrs = append(attrs, "role", identity.role)
+		}
+		if identity.email != "" {
+			attrs = append(attrs, "user", hashEmail(identity.email))
+		}
+		slog.Info("http request", attrs...)
 	})
 }
 
-// RBACMiddleware demonstrates Role-Based Access Control.
-func RBACMiddleware(requiredRole string) func(http.Handler) http.Handler {
+type contextKey int
+
+const (
+	roleContextKey contextKey = iota
+	emailContextKey
+	requestIDContextKey
+	claimsContextKey
+	accessIdentityContextKey
+)
+
+// requestIDPattern bounds client supplied X-Request-ID values so they are safe to log.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// RequestIDFromContext returns the correlation id set by RequestIDMiddleware, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// RequestIDMiddleware tags every request with a correlation id, reusing a well-formed
+// incoming X-Request-ID (e.g. from a load balancer) or generating one, and echoes it back.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id)))
+	})
+}
+
+// RequireJSON answers 415 for requests whose Content-Type is not application/json (any
+// charset parameter is fine), so a form or plain-text POST gets a clear error instead of
+// a confusing JSON parse failure.
+func RequireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			SendJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "Content-Type must be application/json"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RoleFromContext returns the caller's role established by RBACMiddleware.
+func RoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(roleContextKey).(string)
+	return role
+}
+
+// EmailFromContext returns the caller's identity established by RBACMiddleware, if any.
+func EmailFromContext(ctx context.Context) string {
+	email, _ := ctx.Value(emailContextKey).(string)
+	return email
+}
+
+// RBACMiddleware demonstrates Role-Based Access Control. The caller's role and email are
+// taken from verified JWT claims when present. The X-Role and X-User-Email headers are a
+// migration aid that anyone can forge, so they are consulted only when allowHeaderRole is
+// set and the request carries no token.
+func RBACMiddleware(requiredRole string, allowHeaderRole bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Mock check: in reality this parses a JWT role claim
-			role := r.Header.Get("X-Role")
+			var role, email string
+			if claims := ClaimsFromContext(r.Context()); claims != nil {
+				role, email = claims.Role, claims.Email
+			} else if allowHeaderRole {
+				role, email = r.Header.Get("X-Role"), r.Header.Get("X-User-Email")
+			}
+			// Recorded before the check so refused attempts are attributed in the access log too
+			if identity, ok := r.Context().Value(accessIdentityContextKey).(*accessIdentity); ok {
+				identity.role, identity.email = role, email
+			}
 			if role == "" {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(`{"error": "Unauthorized: Missing X-Role header"}`))
+				SendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized: Missing role"})
 				return
 			}
 
 			if role != requiredRole && role != "admin" {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusForbidden)
-				w.Write([]byte(`{"error": "Forbidden: Insufficient privileges"}`))
+				SendJSON(w, http.StatusForbidden, map[string]string{"error": "Forbidden: Insufficient privileges"})
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			ctx := context.WithValue(r.Context(), roleContextKey, role)
+			ctx = context.WithValue(ctx, emailContextKey, email)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-// RateLimitMiddleware provides a basic per-IP token bucket/window for bot defense.
+// Requests allowed per rate limit window. Callers holding a verified admin or organizer
+// token (dashboards polling many endpoints) get the higher limit, counted per token
+// subject instead of per IP.
+const (
+	anonymousRateLimit  = 5
+	privilegedRateLimit = 100
+)
+
+// rateLimitWindow is how long request counts accumulate before every caller starts afresh.
+const rateLimitWindow = 10 * time.Second
+
+// RateLimitMiddleware provides a basic per-IP token bucket/window for bot defense. It must
+// run after JWTMiddleware so the caller's verified role is known; the X-Role header is
+// never trusted here, as it would let any bot lift its own limit.
+//
+// Every response carries X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+// (Unix seconds when the window restarts). A refused request also gets Retry-After and
+// the same figures in its JSON body.
 func RateLimitMiddleware(next http.Handler) http.Handler {
 	// Simple fixed window rate limiter (e.g. 5 requests per 10 seconds per IP)
 	// In production, use Redis to share state across server instances.
@@ -89,24 +233,42 @@ func RateLimitMiddleware(next http.Handler) http.Handler {
 		mu.Lock()
 
 		// Reset window every 10 seconds
-		if time.Since(lastReset) > 10*time.Second {
+		now := time.Now()
+		if now.Sub(lastReset) > rateLimitWindow {
 			visitors = make(map[string]int)
-			lastReset = time.Now()
+			lastReset = now
 		}
+		resetAt := lastReset.Add(rateLimitWindow)
 
-		ip := r.RemoteAddr // In prod, rely on X-Forwarded-For usually
-
-		if visitors[ip] >= 5 {
-			mu.Unlock()
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusTooManyRequests)
-			w.Write([]byte(`{"error": "Too Many Requests"}`))
-			return
+		key, limit := r.RemoteAddr, anonymousRateLimit // In prod, rely on X-Forwarded-For usually
+		if claims := ClaimsFromContext(r.Context()); claims != nil && (claims.Role == "admin" || claims.Role == "organizer") {
+			key, limit = "sub:"+claims.Subject, privilegedRateLimit
 		}
 
-		visitors[ip]++
+		allowed := visitors[key] < limit
+		if allowed {
+			visitors[key]++
+		}
+		remaining := limit - visitors[key]
 		mu.Unlock()
 
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+		if !allowed {
+			// Round up so a client that waits exactly this long lands in the new window
+			retryAfter := int((resetAt.Sub(now) + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			SendJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+				"error":               "Too Many Requests",
+				"limit":               limit,
+				"remaining":           remaining,
+				"reset_at":            resetAt.UTC().Truncate(time.Second),
+				"retry_after_seconds": retryAfter,
+			})
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -116,13 +278,19 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				requestID := RequestIDFromContext(r.Context())
 				slog.Error("panic recovered",
 					"error", err,
+					"request_id", requestID,
+					"method", r.Method,
+					"path", r.URL.Path,
 					"trace", string(debug.Stack()),
 				)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(`{"error": "Internal Server Error"}`))
+				// Quote the id back so a client's support ticket can be matched to this log line
+				SendJSON(w, http.StatusInternalServerError, map[string]string{
+					"error":      "Internal Server Error",
+					"request_id": requestID,
+				})
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecoveryMiddlewareReportsRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	panicky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handler := RequestIDMiddleware(RecoveryMiddleware(panicky))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events/7/register", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", rec.Code)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected a JSON body: %v", err)
+	}
+	if body["request_id"] == "" || body["request_id"] != rec.Header().Get("X-Request-ID") {
+		t.Fatalf("Expected the body and header to carry the same request id, got %q and %q", body["request_id"], rec.Header().Get("X-Request-ID"))
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON log line, got %q", logs.String())
+	}
+	if entry["request_id"] != body["request_id"] {
+		t.Errorf("Expected logged request_id %q, got %v", body["request_id"], entry["request_id"])
+	}
+	if entry["method"] != http.MethodPost || entry["path"] != "/events/7/register" {
+		t.Errorf("Expected the route in the log, got method=%v path=%v", entry["method"], entry["path"])
+	}
+}
+
+func TestRequestIDMiddlewareReusesWellFormedHeader(t *testing.T) {
+	var seen string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("X-Request-ID", "lb-1234")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if seen != "lb-1234" || rec.Header().Get("X-Request-ID") != "lb-1234" {
+		t.Errorf("Expected the incoming id to be kept, got %q", seen)
+	}
+
+	req.Header.Set("X-Request-ID", "bad id\nwith newline")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == "" || seen == "bad id\nwith newline" {
+		t.Errorf("Expected a generated id for a malformed header, got %q", seen)
+	}
+}
+
+func TestRBACRefusalsAreJSONErrors(t *testing.T) {
+	handler := RBACMiddleware("organizer", true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range []struct {
+		role string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"user", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/events", nil)
+		if tt.role != "" {
+			req.Header.Set("X-Role", tt.role)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("role %q: expected a JSON body, got %q", tt.role, rec.Body.String())
+		}
+		if rec.Code != tt.want || body["error"] == nil || body["retryable"] != false {
+			t.Errorf("role %q: expected %d with a non-retryable error, got %d %v", tt.role, tt.want, rec.Code, body)
+		}
+	}
+}
+
+func TestAccessLogRecordsCallerAndSize(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	handler := LoggingMiddleware(JWTMiddleware(testJWTSecret)(RBACMiddleware("organizer", false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))))
+
+	req := httptest.NewRequest(http.MethodGet, "/organizer/events", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, Claims{Email: "Org@Example.com", Role: "organizer", ExpiresAt: time.Now().Add(time.Hour).Unix()}, testJWTSecret))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON log line, got %q", logs.String())
+	}
+	if entry["role"] != "organizer" {
+		t.Errorf("Expected role organizer in the log, got %v", entry["role"])
+	}
+	if entry["size"] != float64(len("hello")) {
+		t.Errorf("Expected size %d in the log, got %v", len("hello"), entry["size"])
+	}
+	if entry["user"] != hashEmail("org@example.com") || strings.Contains(logs.String(), "xample.com") {
+		t.Errorf("Expected only the hashed email in the log, got %q", logs.String())
+	}
+}
+
+func TestResponseWriterCountsBytesWritten(t *testing.T) {
+	rw := wrapResponseWriter(httptest.NewRecorder())
+	body := bytes.Repeat([]byte("x"), 1500)
+
+	rw.Write(body[:1000])
+	rw.Write(body[1000:])
+
+	if got := rw.BytesWritten(); got != int64(len(body)) {
+		t.Errorf("Expected %d bytes written, got %d", len(body), got)
+	}
+	if rw.Status() != http.StatusOK {
+		t.Errorf("Expected the implicit 200, got %d", rw.Status())
+	}
+}
+
+func TestRateLimitReportsLimitAndReset(t *testing.T) {
+	handler := RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	call := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req.RemoteAddr = "203.0.113.9:4000"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	start := time.Now()
+	for i := 1; i <= anonymousRateLimit; i++ {
+		rec := call()
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("Request %d: expected to pass, got %d", i, rec.Code)
+		}
+		if got, want := rec.Header().Get("X-RateLimit-Remaining"), strconv.Itoa(anonymousRateLimit-i); got != want {
+			t.Errorf("Request %d: expected X-RateLimit-Remaining %s, got %s", i, want, got)
+		}
+	}
+
+	rec := call()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 past the limit, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-RateLimit-Limit"); got != strconv.Itoa(anonymousRateLimit) {
+		t.Errorf("Expected X-RateLimit-Limit %d, got %s", anonymousRateLimit, got)
+	}
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected X-RateLimit-Remaining 0, got %s", got)
+	}
+	reset, err := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		t.Fatalf("Expected a Unix X-RateLimit-Reset, got %q", rec.Header().Get("X-RateLimit-Reset"))
+	}
+	if resetAt := time.Unix(reset, 0); resetAt.Before(start.Add(rateLimitWindow).Add(-time.Second)) || resetAt.After(time.Now().Add(rateLimitWindow)) {
+		t.Errorf("Expected the reset one window after the first request, got %v", resetAt)
+	}
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > int(rateLimitWindow/time.Second) {
+		t.Errorf("Expected Retry-After within the window, got %q", rec.Header().Get("Retry-After"))
+	}
+
+	var body struct {
+		Error      string    `json:"error"`
+		Retryable  bool      `json:"retryable"`
+		Limit      int       `json:"limit"`
+		Remaining  int       `json:"remaining"`
+		ResetAt    time.Time `json:"reset_at"`
+		RetryAfter int       `json:"retry_after_seconds"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON body: %v", err)
+	}
+	if body.Limit != anonymousRateLimit || body.Remaining != 0 || !body.Retryable || body.RetryAfter != retryAfter || body.ResetAt.Unix() != reset {
+		t.Errorf("Expected the body to match the headers, got %+v", body)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// migration is one step of schema history, applied at most once per database and
+// recorded in schema_migrations. Steps must be safe to run against a database that
+// already has their changes, because a fresh database gets the whole current schema
+// from the first one.
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// rebuildMigrations are the versions that drop and recreate a table others reference.
+// They follow SQLite's table rebuild procedure: foreign keys are switched off around the
+// transaction and foreign_key_check must come back clean before it commits.
+var rebuildMigrations = map[int]bool{11: true}
+
+// migrations lists every schema change in order. Append new steps; never renumber or
+// edit one that has shipped.
+var migrations = []migration{
+	{1, "create tables", execSQL(baseSchema)},
+	{2, "event scheduling, ownership and registration windows", addColumns("events",
+		column{name: "starts_at", definition: "DATETIME"},
+		column{name: "status", definition: "TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'published', 'cancelled'))"},
+		column{name: "organizer_email", definition: "TEXT"},
+		column{name: "auto_confirm", definition: "BOOLEAN NOT NULL DEFAULT 0"},
+		column{name: "registration_opens_at", definition: "DATETIME"},
+		column{name: "registration_closes_at", definition: "DATETIME"},
+	)},
+	{3, "ticket quantity, guest claims and pricing", addColumns("tickets",
+		// ADD COLUMN cannot carry UNIQUE, so older databases get an equivalent index
+		column{name: "claim_token", definition: "TEXT", then: `CREATE UNIQUE INDEX IF NOT EXISTS idx_tickets_claim_token ON tickets(claim_token)`},
+		column{name: "quantity", definition: "INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0)"},
+		column{name: "confirmation_code", definition: "TEXT"},
+		column{name: "amount_due", definition: "INTEGER NOT NULL DEFAULT 0"},
+		column{name: "currency", definition: "TEXT NOT NULL DEFAULT 'USD'"},
+	)},
+	{4, "event holds, pricing and creation time", addColumns("events",
+		column{name: "hold_seconds", definition: "INTEGER CHECK (hold_seconds > 0)"},
+		// ADD COLUMN needs a constant default; existing rows are stamped with the upgrade time
+		column{name: "created_at", definition: "DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00'", then: `UPDATE events SET created_at = CURRENT_TIMESTAMP`},
+		column{name: "price_cents", definition: "INTEGER NOT NULL DEFAULT 0 CHECK (price_cents >= 0)"},
+		column{name: "currency", definition: "TEXT NOT NULL DEFAULT 'USD'"},
+	)},
+	{5, "group orders", steps(
+		execSQL(`
+			CREATE TABLE IF NOT EXISTS orders (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				event_id INTEGER NOT NULL,
+				buyer_email TEXT NOT NULL,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (event_id) REFERENCES events(id)
+			)
+		`),
+		addColumns("tickets", column{name: "order_id", definition: "INTEGER REFERENCES orders(id)"}),
+		execSQL(`CREATE INDEX IF NOT EXISTS idx_tickets_order_id ON tickets(order_id)`),
+	)},
+	{6, "organizer external ids", steps(
+		addColumns("events", column{name: "external_id", definition: "TEXT"}),
+		// Events without an organizer share the '' scope rather than never colliding as NULLs
+		execSQL(`CREATE UNIQUE INDEX IF NOT EXISTS idx_events_external_id ON events(COALESCE(organizer_email, ''), external_id) WHERE external_id IS NOT NULL`),
+	)},
+	{7, "payment pending holds", addColumns("tickets",
+		column{name: "payment_pending_at", definition: "DATETIME"},
+	)},
+	{8, "waitlist caps", addColumns("events",
+		column{name: "max_waitlist", definition: "INTEGER CHECK (max_waitlist > 0)"},
+	)},
+	{9, "ticket metadata", addColumns("tickets",
+		column{name: "metadata", definition: "TEXT"},
+	)},
+	{10, "confirm nonces", addColumns("tickets",
+		column{name: "confirm_nonce", definition: "TEXT"},
+	)},
+	// SQLite cannot drop the inline UNIQUE(event_id, user_email), so tickets is rebuilt with
+	// that uniqueness moved to a partial index over active tickets: a cancelled ticket no
+	// longer keeps its owner from registering again. Ids and the AUTOINCREMENT sequence are
+	// carried over so audit entries and emailed ticket ids stay valid. The idempotency index
+	// is recreated by applyIdempotencyScope after migrating.
+	{11, "re-registration after cancel", execSQL(`
+		CREATE TABLE tickets_v11 (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id INTEGER NOT NULL,
+			user_email TEXT,
+			claim_token TEXT,
+			idempotency_key TEXT NOT NULL,
+			status TEXT DEFAULT 'reserved' CHECK (status IN ('reserved', 'confirmed', 'cancelled')),
+			quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+			confirmation_code TEXT,
+			amount_due INTEGER NOT NULL DEFAULT 0,
+			currency TEXT NOT NULL DEFAULT 'USD',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			order_id INTEGER REFERENCES orders(id),
+			payment_pending_at DATETIME,
+			metadata TEXT,
+			confirm_nonce TEXT,
+			FOREIGN KEY (event_id) REFERENCES events(id),
+			CHECK (user_email IS NOT NULL OR claim_token IS NOT NULL)
+		);
+		INSERT INTO tickets_v11 (id, event_id, user_email, claim_token, idempotency_key, status, quantity, confirmation_code, amount_due, currency, created_at, expires_at, order_id, payment_pending_at, metadata, confirm_nonce)
+			SELECT id, event_id, user_email, claim_token, idempotency_key, status, quantity, confirmation_code, amount_due, currency, created_at, expires_at, order_id, payment_pending_at, metadata, confirm_nonce FROM tickets;
+		DELETE FROM sqlite_sequence WHERE name = 'tickets_v11';
+		UPDATE sqlite_sequence SET name = 'tickets_v11' WHERE name = 'tickets';
+		DROP TABLE tickets;
+		ALTER TABLE tickets_v11 RENAME TO tickets;
+
+		CREATE INDEX idx_tickets_status_expires_at ON tickets(status, expires_at);
+		CREATE INDEX idx_tickets_event_id ON tickets(event_id);
+		CREATE INDEX idx_tickets_order_id ON tickets(order_id);
+		CREATE UNIQUE INDEX idx_tickets_claim_token ON tickets(claim_token);
+		CREATE UNIQUE INDEX idx_tickets_active_email ON tickets(event_id, user_email) WHERE status IN ('reserved', 'confirmed');
+	`)},
+	{12, "capacity snapshots", execSQL(`
+		CREATE TABLE IF NOT EXISTS capacity_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id INTEGER NOT NULL,
+			available_spots INTEGER NOT NULL,
+			captured_at DATETIME NOT NULL,
+			FOREIGN KEY (event_id) REFERENCES events(id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_capacity_snapshots_event ON capacity_snapshots(event_id, captured_at);
+	`)},
+	// Tickets cancelled before this version have no record of when; they are stamped with
+	// the migration time so the purge keeps them for a full retention period.
+	{13, "ticket cancellation times", addColumns("tickets",
+		column{name: "cancelled_at", definition: "DATETIME", then: `UPDATE tickets SET cancelled_at = CURRENT_TIMESTAMP WHERE status = 'cancelled'`},
+	)},
+}
+
+// column is a column a migration adds when the table does not have it yet. then runs
+// only when the column was actually added, e.g. to backfill it.
+type column struct {
+	name, definition, then string
+}
+
+// steps runs each of ups in turn within the migration's transaction.
+func steps(ups ...func(context.Context, *sql.Tx) error) func(context.Context, *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, up := range ups {
+			if err := up(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func execSQL(query string) func(context.Context, *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	}
+}
+
+// addColumns adds each missing column to table, leaving existing ones and their data alone.
+func addColumns(table string, columns ...column) func(context.Context, *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, c := range columns {
+			var exists bool
+			if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pragma_table_info(?) WHERE name = ?)`, table, c.name).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to inspect %s.%s: %w", table, c.name, err)
+			}
+			if exists {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, c.name, c.definition)); err != nil {
+				return fmt.Errorf("failed to add %s.%s: %w", table, c.name, err)
+			}
+			if c.then != "" {
+				if _, err := tx.ExecContext(ctx, c.then); err != nil {
+					return fmt.Errorf("failed to backfill %s.%s: %w", table, c.name, err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// migrate applies every migration the database has not recorded yet, each in its own
+// transaction, so a failure leaves the database at the last good version.
+func (db *DB) migrate(ctx context.Context) error {
+	return db.applyMigrations(ctx, migrations)
+}
+
+// applyMigrations applies those of ms the database has not recorded yet, in order.
+func (db *DB) applyMigrations(ctx context.Context, ms []migration) error {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range ms {
+		if err := db.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) applyMigration(ctx context.Context, m migration) error {
+	// One connection throughout, since the foreign_keys pragma is per connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	rebuild := rebuildMigrations[m.version]
+	if rebuild {
+		// The pragma is a no-op inside a transaction, so it is switched before BEGIN
+		var foreignKeys bool
+		if err := conn.QueryRowContext(ctx, `PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil {
+			return fmt.Errorf("failed to read foreign_keys: %w", err)
+		}
+		if foreignKeys {
+			if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+				return fmt.Errorf("failed to disable foreign keys: %w", err)
+			}
+			defer conn.ExecContext(context.WithoutCancel(ctx), `PRAGMA foreign_keys = ON`)
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Checked inside the transaction so two instances booting together apply it once
+	var applied bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)`, m.version).Scan(&applied); err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	if applied {
+		return nil
+	}
+
+	if err := m.up(ctx, tx); err != nil {
+		return err
+	}
+	if rebuild {
+		if err := checkForeignKeys(ctx, tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	slog.InfoContext(ctx, "applied schema migration", "version", m.version, "name", m.name)
+	return nil
+}
+
+// checkForeignKeys fails if any row references one that does not exist.
+func checkForeignKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return fmt.Errorf("failed to read foreign key violation: %w", err)
+		}
+		return fmt.Errorf("foreign key violation: %s row %d references a missing %s row", table, rowid.Int64, parent)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// originalSchema is the schema the first release created, before any migrations existed.
+const originalSchema = `
+	CREATE TABLE events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		total_spots INTEGER NOT NULL,
+		available_spots INTEGER NOT NULL,
+		CHECK (available_spots >= 0)
+	);
+
+	CREATE TABLE tickets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		user_email TEXT NOT NULL,
+		idempotency_key TEXT UNIQUE NOT NULL,
+		status TEXT DEFAULT 'reserved' CHECK (status IN ('reserved', 'confirmed', 'cancelled')),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		FOREIGN KEY (event_id) REFERENCES events(id),
+		UNIQUE(event_id, user_email)
+	);
+
+	INSERT INTO events (name, total_spots, available_spots) VALUES ('Legacy Launch', 10, 9);
+	INSERT INTO tickets (event_id, user_email, idempotency_key, status, expires_at)
+	VALUES (1, 'early@example.com', 'legacy_key', 'confirmed', '9999-12-31 23:59:59');
+`
+
+func TestMigrateUpgradesOriginalSchema(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDB(fmt.Sprintf("file:%s?mode=rwc", filepath.Join(t.TempDir(), "legacy.db")))
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, originalSchema); err != nil {
+		t.Fatalf("Failed to create the original schema: %v", err)
+	}
+
+	// Running twice must be a no-op the second time
+	for i := 0; i < 2; i++ {
+		if err := db.InitSchema(ctx); err != nil {
+			t.Fatalf("InitSchema run %d failed: %v", i+1, err)
+		}
+	}
+
+	var version, applied int
+	if err := db.QueryRowContext(ctx, `SELECT MAX(version), COUNT(*) FROM schema_migrations`).Scan(&version, &applied); err != nil {
+		t.Fatalf("Failed to read schema_migrations: %v", err)
+	}
+	if want := migrations[len(migrations)-1].version; version != want || applied != len(migrations) {
+		t.Errorf("Expected %d migrations up to version %d, got %d up to %d", len(migrations), want, applied, version)
+	}
+
+	// Existing rows survive with the new columns defaulted
+	evt, err := db.GetEvent(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to load migrated event: %v", err)
+	}
+	if evt.Name != "Legacy Launch" || evt.AvailableSpots != 9 || evt.Status != EventStatusPublished || evt.Currency != "USD" || evt.CreatedAt.IsZero() {
+		t.Errorf("Unexpected migrated event: %+v", evt)
+	}
+	ticket, err := db.GetTicket(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to load migrated ticket: %v", err)
+	}
+	if ticket.UserEmail != "early@example.com" || ticket.Status != "confirmed" || ticket.Quantity != 1 {
+		t.Errorf("Unexpected migrated ticket: %+v", ticket)
+	}
+
+	// And the current code works against the upgraded tables
+	created, err := db.CreateEvent(ctx, Event{Name: "After Upgrade", TotalSpots: 3, PriceCents: 500, Tags: []string{"new"}})
+	if err != nil {
+		t.Fatalf("Failed to create event after migrating: %v", err)
+	}
+	registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: created.ID, Email: "late@example.com", IdempotencyKey: "new_key", Quantity: 2})
+	if err != nil {
+		t.Fatalf("Failed to register after migrating: %v", err)
+	}
+	if registered.AmountDue != 1000 {
+		t.Errorf("Expected amount_due 1000, got %d", registered.AmountDue)
+	}
+
+	// The rebuilt tickets table keeps counting ids from where the old one left off
+	if registered.ID != 2 {
+		t.Errorf("Expected the next ticket id to be 2, got %d", registered.ID)
+	}
+}
+
+func TestMigrateRebuildsTicketsWithForeignKeysOn(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDB(fmt.Sprintf("file:%s?mode=rwc&_pragma=foreign_keys(1)", filepath.Join(t.TempDir(), "fk.db")))
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	// A version 10 database with a seat assigned to a ticket
+	if err := db.applyMigrations(ctx, migrations[:10]); err != nil {
+		t.Fatalf("Failed to migrate to version 10: %v", err)
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO events (name, total_spots, available_spots) VALUES ('Seated Show', 2, 1);
+		INSERT INTO tickets (event_id, user_email, idempotency_key, status, expires_at)
+		VALUES (1, 'seated@example.com', 'seated_key', 'confirmed', '9999-12-31 23:59:59');
+		INSERT INTO seats (event_id, seat_label, status, ticket_id) VALUES (1, 'A1', 'assigned', 1);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to seed version 10 data: %v", err)
+	}
+
+	if err := db.InitSchema(ctx); err != nil {
+		t.Fatalf("Failed to migrate with foreign keys on: %v", err)
+	}
+
+	var ticketID int64
+	if err := db.QueryRowContext(ctx, `SELECT ticket_id FROM seats WHERE seat_label = 'A1'`).Scan(&ticketID); err != nil || ticketID != 1 {
+		t.Fatalf("Expected seat A1 to keep ticket 1, got %d (%v)", ticketID, err)
+	}
+	if ticket, err := db.GetTicket(ctx, ticketID); err != nil || ticket.UserEmail != "seated@example.com" {
+		t.Errorf("Expected the seated ticket to survive, got %+v (%v)", ticket, err)
+	}
+	var foreignKeys bool
+	if err := db.QueryRowContext(ctx, `PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil || !foreignKeys {
+		t.Errorf("Expected foreign keys to be back on after migrating, got %t (%v)", foreignKeys, err)
+	}
+}
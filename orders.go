@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrOrderNotFound = errors.New("order not found")
+
+// Order groups one ticket per attendee under a single buyer, so a group booking is
+// reserved and confirmed as a unit.
+type Order struct {
+	ID         int64     `json:"id"`
+	EventID    int64     `json:"event_id"`
+	BuyerEmail string    `json:"buyer_email"`
+	CreatedAt  time.Time `json:"created_at"`
+	Tickets    []Ticket  `json:"tickets"`
+}
+
+// orderTicketKey is the idempotency key of the n-th attendee's ticket (from 1) in the order
+// placed under idempotencyKey. Clients cannot send a ':' in their own keys, so these never
+// collide with plain registrations or waitlist promotions.
+func orderTicketKey(idempotencyKey string, n int) string {
+	return fmt.Sprintf("order:%s:%d", idempotencyKey, n)
+}
+
+// CreateOrder reserves one ticket per attendee at eventID under a new order owned by
+// buyer. It is all or nothing: if any attendee cannot be registered (sold out, already
+// registered, ...) no ticket is kept. Ticket keys come from orderTicketKey, so placing an
+// order under a key already used fails with ErrAlreadyRegistered; FindOrderByIdempotencyKey
+// finds the order that used it.
+func (db *DB) CreateOrder(ctx context.Context, eventID int64, buyer, idempotencyKey string, attendees []string) (*Order, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM events WHERE id = ?)`, eventID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check event existence: %w", err)
+	}
+	if !exists {
+		return nil, ErrEventNotFound
+	}
+
+	order := Order{EventID: eventID, BuyerEmail: buyer, CreatedAt: db.Clock.Now().UTC().Truncate(time.Second)}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO orders (event_id, buyer_email, created_at) VALUES (?, ?, ?) RETURNING id
+	`, eventID, buyer, sqliteTimestamp(order.CreatedAt)).Scan(&order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert order: %w", err)
+	}
+
+	remaining := -1
+	for i, email := range attendees {
+		ticket, left, err := db.register(ctx, tx, RegisterParams{
+			EventID:        eventID,
+			Email:          email,
+			IdempotencyKey: orderTicketKey(idempotencyKey, i+1),
+			OrderID:        order.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		order.Tickets = append(order.Tickets, *ticket)
+		remaining = left
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	if remaining == 0 && db.OnSoldOut != nil {
+		db.OnSoldOut(ctx, eventID)
+	}
+	return &order, nil
+}
+
+// FindOrderByIdempotencyKey returns the order placed at eventID under idempotencyKey, with
+// its tickets, or ErrOrderNotFound.
+func (db *DB) FindOrderByIdempotencyKey(ctx context.Context, eventID int64, idempotencyKey string) (*Order, error) {
+	first, err := db.FindTicketByIdempotencyKey(ctx, eventID, orderTicketKey(idempotencyKey, 1))
+	if errors.Is(err, ErrTicketNotFound) || (err == nil && first.OrderID == nil) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	order := Order{ID: *first.OrderID}
+	var createdAt sqliteTime
+	err = db.QueryRowContext(ctx, `SELECT event_id, buyer_email, created_at FROM orders WHERE id = ?`, order.ID).
+		Scan(&order.EventID, &order.BuyerEmail, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load order: %w", err)
+	}
+	order.CreatedAt = createdAt.Time
+	if order.Tickets, err = orderTickets(ctx, db, order.ID); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// orderTickets returns orderID's tickets in the order they were issued.
+func orderTickets(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}, orderID int64) ([]Ticket, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE order_id = ? ORDER BY id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load order tickets: %w", err)
+	}
+	defer rows.Close()
+	var tickets []Ticket
+	for rows.Next() {
+		ticket, err := scanTicket(rows)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, *ticket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load order tickets: %w", err)
+	}
+	return tickets, nil
+}
+
+// ConfirmOrder confirms every ticket of orderID for its buyer in one step. Either all
+// reserved tickets are confirmed or none are: a lapsed or cancelled ticket fails the whole
+// order with ErrTicketExpired or ErrTicketNotActive, and ErrAlreadyConfirmed means there
+// was nothing left to confirm. Alongside the order it returns the ids of the tickets this
+// call confirmed, leaving out any an attendee had already confirmed themselves.
+func (db *DB) ConfirmOrder(ctx context.Context, orderID int64, buyer string) (*Order, []int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	order := Order{ID: orderID}
+	var createdAt sqliteTime
+	err = tx.QueryRowContext(ctx, `SELECT event_id, buyer_email, created_at FROM orders WHERE id = ? AND buyer_email = ?`, orderID, buyer).
+		Scan(&order.EventID, &order.BuyerEmail, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load order: %w", err)
+	}
+	order.CreatedAt = createdAt.Time
+
+	now := sqliteTimestamp(db.Clock.Now())
+	var reserved, lapsed, cancelled int
+	var eventGone bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'reserved'),
+			COUNT(*) FILTER (WHERE status = 'reserved' AND expires_at <= ?),
+			COUNT(*) FILTER (WHERE status = 'cancelled'),
+			NOT EXISTS (SELECT 1 FROM events WHERE id = ? AND status != ?)
+		FROM tickets WHERE order_id = ?
+	`, now, order.EventID, EventStatusCancelled, orderID).Scan(&reserved, &lapsed, &cancelled, &eventGone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to inspect order tickets: %w", err)
+	}
+
+	switch {
+	case reserved == 0 && cancelled == 0:
+		return nil, nil, ErrAlreadyConfirmed
+	case reserved > 0 && eventGone:
+		return nil, nil, ErrEventCancelled
+	case lapsed > 0:
+		return nil, nil, ErrTicketExpired
+	case cancelled > 0:
+		return nil, nil, ErrTicketNotActive
+	}
+
+	rows, err := tx.QueryContext(ctx, `UPDATE tickets SET status = 'confirmed' WHERE order_id = ? AND status = 'reserved' RETURNING id`, orderID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to confirm order: %w", err)
+	}
+	var confirmed []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to confirm order: %w", err)
+		}
+		confirmed = append(confirmed, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to confirm order: %w", err)
+	}
+
+	if order.Tickets, err = orderTickets(ctx, tx, orderID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return &order, confirmed, nil
+}
+
+// CreateOrderRequest is the body of POST /events/{id}/orders.
+type CreateOrderRequest struct {
+	Email          string   `json:"email"`
+	Attendees      []string `json:"attendees"`
+	IdempotencyKey string   `json:"idempotency_key"`
+}
+
+// HandleCreateOrder handles POST /events/{id}/orders, reserving one ticket per attendee
+// email under a single order owned by email.
+func (h *Handlers) HandleCreateOrder(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
+		return
+	}
+
+	var req CreateOrderRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		sendDecodeError(w, err)
+		return
+	}
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		req.IdempotencyKey = key
+	}
+
+	if req.Email == "" || req.IdempotencyKey == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email and an Idempotency-Key header or idempotency_key are required"})
+		return
+	}
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLength || !idempotencyKeyPattern.MatchString(req.IdempotencyKey) {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Idempotency key must be at most %d letters, digits, '-' and '_'", maxIdempotencyKeyLength)})
+		return
+	}
+	if maxAttendees := h.maxTicketQuantity(); len(req.Attendees) < 1 || len(req.Attendees) > maxAttendees {
+		SendJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": fmt.Sprintf("An order must list between 1 and %d attendees", maxAttendees)})
+		return
+	}
+	attendees := make([]string, 0, len(req.Attendees))
+	seen := make(map[string]bool, len(req.Attendees))
+	for _, email := range req.Attendees {
+		email = strings.TrimSpace(email)
+		if email == "" || seen[strings.ToLower(email)] {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Attendee emails must be non-empty and distinct"})
+			return
+		}
+		seen[strings.ToLower(email)] = true
+		attendees = append(attendees, email)
+	}
+
+	if h.rejectOrganizerSelfRegistration(w, r, eventID, attendees...) {
+		return
+	}
+
+	order, err := h.DB.CreateOrder(r.Context(), eventID, req.Email, req.IdempotencyKey, attendees)
+
+	// As for single registrations, a retry of an order that went through gets that order
+	// back rather than a 409, as long as it is for the same buyer and attendees and every
+	// ticket still holds its spot
+	replayed := false
+	if errors.Is(err, ErrAlreadyRegistered) {
+		if prior, lookupErr := h.DB.FindOrderByIdempotencyKey(r.Context(), eventID, req.IdempotencyKey); lookupErr == nil && h.orderMatches(prior, req.Email, attendees) {
+			order, err, replayed = prior, nil, true
+			w.Header().Set("Idempotency-Replayed", "true")
+		}
+	}
+	switch {
+	case errors.Is(err, ErrEventNotFound):
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, ErrEventDraft):
+		h.sendEventDraft(w, r, eventID)
+		return
+	case errors.Is(err, ErrRegistrationNotOpen):
+		SendJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, ErrSoldOut), errors.Is(err, ErrAlreadyRegistered), errors.Is(err, ErrSeatTaken):
+		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, ErrDatabaseBusy):
+		w.Header().Set("Retry-After", "1")
+		SendJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error while creating order"})
+		return
+	}
+
+	for i := range order.Tickets {
+		if order.Tickets[i].Status == "confirmed" && !replayed {
+			h.sendConfirmationCode(r.Context(), &order.Tickets[i])
+		}
+	}
+	SendJSON(w, http.StatusCreated, order)
+}
+
+// orderMatches reports whether order was placed by buyer for exactly attendees, in the
+// same order, and all its tickets still hold their spots.
+func (h *Handlers) orderMatches(order *Order, buyer string, attendees []string) bool {
+	if !strings.EqualFold(order.BuyerEmail, buyer) || len(order.Tickets) != len(attendees) {
+		return false
+	}
+	for i := range order.Tickets {
+		if !strings.EqualFold(order.Tickets[i].UserEmail, attendees[i]) || !h.ticketHolds(&order.Tickets[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// HandleConfirmOrder handles POST /orders/{id}/confirm, confirming every ticket in the
+// order for its buyer at once.
+func (h *Handlers) HandleConfirmOrder(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid order ID format"})
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		sendDecodeError(w, err)
+		return
+	}
+	if req.Email == "" {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Email is required to confirm an order"})
+		return
+	}
+
+	order, confirmed, err := h.DB.ConfirmOrder(r.Context(), orderID, req.Email)
+	switch {
+	case errors.Is(err, ErrOrderNotFound):
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error(), "code": "order_not_found"})
+		return
+	case errors.Is(err, ErrTicketExpired):
+		SendJSON(w, http.StatusGone, map[string]string{"error": err.Error(), "code": "ticket_expired"})
+		return
+	case errors.Is(err, ErrEventCancelled):
+		SendJSON(w, http.StatusGone, map[string]string{"error": err.Error(), "code": "event_cancelled"})
+		return
+	case errors.Is(err, ErrAlreadyConfirmed):
+		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error(), "code": "already_confirmed"})
+		return
+	case errors.Is(err, ErrTicketNotActive):
+		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error(), "code": "ticket_cancelled"})
+		return
+	case err != nil:
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error while confirming order"})
+		return
+	}
+
+	// Attendees who confirmed their own ticket earlier already have their code
+	for i := range order.Tickets {
+		if slices.Contains(confirmed, order.Tickets[i].ID) {
+			h.sendConfirmationCode(r.Context(), &order.Tickets[i])
+		}
+	}
+	SendJSON(w, http.StatusOK, order)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGroupOrderReservesAndConfirmsTogether(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Team Offsite", TotalSpots: 4, PriceCents: 1500})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-Role", "user")
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(fmt.Sprintf("/events/%d/orders", evt.ID), `{
+		"email": "lead@example.com",
+		"idempotency_key": "offsite_order",
+		"attendees": ["ana@example.com", "bo@example.com", "cy@example.com"]
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating the order, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var order Order
+	if err := json.Unmarshal(rec.Body.Bytes(), &order); err != nil {
+		t.Fatalf("Failed to decode order: %v", err)
+	}
+	if len(order.Tickets) != 3 {
+		t.Fatalf("Expected 3 tickets, got %d", len(order.Tickets))
+	}
+	for _, ticket := range order.Tickets {
+		if ticket.Status != "reserved" || ticket.OrderID == nil || *ticket.OrderID != order.ID || ticket.AmountDue != 1500 {
+			t.Errorf("Unexpected order ticket: %+v", ticket)
+		}
+	}
+	if got, _ := db.GetEvent(ctx, evt.ID); got.AvailableSpots != 1 {
+		t.Errorf("Expected 1 spot left, got %d", got.AvailableSpots)
+	}
+
+	// An order that does not fit leaves nothing behind
+	if rec := post(fmt.Sprintf("/events/%d/orders", evt.ID), `{"email": "late@example.com", "idempotency_key": "late_order", "attendees": ["dee@example.com", "eve@example.com"]}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an order larger than what is left, got %d", rec.Code)
+	}
+	if _, err := db.FindActiveTicket(ctx, evt.ID, "dee@example.com"); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("Expected no ticket from the failed order, got %v", err)
+	}
+
+	// Only the buyer can confirm, and one call confirms every ticket
+	if _, _, err := db.ConfirmOrder(ctx, order.ID, "ana@example.com"); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound for an attendee, got %v", err)
+	}
+	rec = post(fmt.Sprintf("/orders/%d/confirm", order.ID), `{"email": "lead@example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 confirming the order, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, ticket := range order.Tickets {
+		got, err := db.GetTicket(ctx, ticket.ID)
+		if err != nil {
+			t.Fatalf("Failed to load ticket: %v", err)
+		}
+		if got.Status != "confirmed" {
+			t.Errorf("Expected ticket %d confirmed, got %s", got.ID, got.Status)
+		}
+	}
+
+	if _, _, err := db.ConfirmOrder(ctx, order.ID, "lead@example.com"); !errors.Is(err, ErrAlreadyConfirmed) {
+		t.Errorf("Expected ErrAlreadyConfirmed on a second confirm, got %v", err)
+	}
+}
+
+func TestOrderTicketKeysDoNotCollideWithClientKeys(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	evt, err := db.CreateEvent(ctx, Event{Name: "Team Offsite", TotalSpots: 4})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	// A plain registration already uses the key the order's first ticket used to get
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "solo@example.com", IdempotencyKey: "abc-1"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	order, err := db.CreateOrder(ctx, evt.ID, "lead@example.com", "abc", []string{"ana@example.com", "bo@example.com"})
+	if err != nil {
+		t.Fatalf("Expected the order to succeed alongside key abc-1, got %v", err)
+	}
+	if len(order.Tickets) != 2 {
+		t.Errorf("Expected 2 tickets, got %d", len(order.Tickets))
+	}
+
+	// Reusing the key conflicts here; HandleCreateOrder turns a matching retry into a replay
+	if _, err := db.CreateOrder(ctx, evt.ID, "lead@example.com", "abc", []string{"ana@example.com"}); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Errorf("Expected a replayed order to fail with ErrAlreadyRegistered, got %v", err)
+	}
+}
+
+func TestRetriedOrderReplaysOriginal(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	h := &Handlers{DB: db}
+	evt, err := db.CreateEvent(ctx, Event{Name: "Team Offsite", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	place := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events/x/orders", strings.NewReader(body))
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		req.Header.Set("Idempotency-Key", "offsite_retry")
+		rec := httptest.NewRecorder()
+		h.HandleCreateOrder(rec, req)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) Order {
+		var order Order
+		if err := json.Unmarshal(rec.Body.Bytes(), &order); err != nil {
+			t.Fatalf("Failed to decode order: %v", err)
+		}
+		return order
+	}
+
+	body := `{"email": "lead@example.com", "attendees": ["ana@example.com", "bo@example.com"]}`
+	first := place(body)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotency-Replayed") != "" {
+		t.Fatalf("Expected a fresh 201, got %d: %s", first.Code, first.Body.String())
+	}
+	original := decode(first)
+
+	// The client never saw that response and sends the same request again
+	retry := place(body)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotency-Replayed") != "true" {
+		t.Fatalf("Expected a replayed 201, got %d: %s", retry.Code, retry.Body.String())
+	}
+	replayed := decode(retry)
+	if replayed.ID != original.ID || len(replayed.Tickets) != 2 || replayed.Tickets[0].ID != original.Tickets[0].ID {
+		t.Errorf("Expected the original order %+v, got %+v", original, replayed)
+	}
+	if got, _ := db.GetEvent(ctx, evt.ID); got.AvailableSpots != 3 {
+		t.Errorf("Expected the replay to take no spots, got %d left", got.AvailableSpots)
+	}
+
+	// The same key with a different buyer or attendee list is still a conflict
+	for _, other := range []string{
+		`{"email": "someone@example.com", "attendees": ["ana@example.com", "bo@example.com"]}`,
+		`{"email": "lead@example.com", "attendees": ["ana@example.com", "cy@example.com"]}`,
+		`{"email": "lead@example.com", "attendees": ["ana@example.com"]}`,
+	} {
+		if rec := place(other); rec.Code != http.StatusConflict {
+			t.Errorf("%s: expected 409, got %d: %s", other, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestConfirmOrderEmailsOnlyNewlyConfirmedTickets(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	sender := &recordingSender{}
+	router := (&Handlers{DB: db, Email: sender, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Team Offsite", TotalSpots: 4})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	order, err := db.CreateOrder(ctx, evt.ID, "lead@example.com", "offsite", []string{"ana@example.com", "bo@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+
+	// Ana confirms her own ticket before the buyer confirms the order
+	if err := db.ConfirmReservation(ctx, order.Tickets[0].ID, "ana@example.com", ""); err != nil {
+		t.Fatalf("Failed to confirm Ana's ticket: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/orders/%d/confirm", order.ID), strings.NewReader(`{"email": "lead@example.com"}`))
+	req.Header.Set("X-Role", "user")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 confirming the order, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "bo@example.com" {
+		t.Errorf("Expected only bo@example.com to be emailed, got %v", sender.sent)
+	}
+}
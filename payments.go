@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultPaymentGrace is how long a reservation is held once its payment is in flight.
+const defaultPaymentGrace = 15 * time.Minute
+
+var ErrNoPaymentDue = errors.New("ticket has nothing to pay")
+
+// MarkPaymentPending flags a live reservation as awaiting payment and extends its hold to
+// at least now+grace, never shortening it. Only the first call extends it: later ones
+// return the ticket unchanged, so repeated calls cannot hold a spot indefinitely. The
+// reclaimer only looks at expires_at, so the extended deadline is honoured like any other
+// hold. Free tickets report ErrNoPaymentDue; otherwise the errors are those of
+// ConfirmReservation.
+func (db *DB) MarkPaymentPending(ctx context.Context, ticketID int64, grace time.Duration) (*Ticket, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := db.Clock.Now()
+	var status string
+	var amountDue int
+	var lapsed, eventGone bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, amount_due, expires_at <= ?,
+			NOT EXISTS (SELECT 1 FROM events WHERE events.id = tickets.event_id AND events.status != ?)
+		FROM tickets WHERE id = ?
+	`, sqliteTimestamp(now), EventStatusCancelled, ticketID).Scan(&status, &amountDue, &lapsed, &eventGone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ticket: %w", err)
+	}
+
+	switch {
+	case status == "confirmed":
+		return nil, ErrAlreadyConfirmed
+	case status == "cancelled":
+		return nil, ErrTicketNotActive
+	case eventGone:
+		return nil, ErrEventCancelled
+	case lapsed:
+		return nil, ErrTicketExpired
+	case amountDue == 0:
+		return nil, ErrNoPaymentDue
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tickets
+		SET expires_at = CASE WHEN payment_pending_at IS NULL THEN MAX(expires_at, ?) ELSE expires_at END,
+			payment_pending_at = COALESCE(payment_pending_at, ?)
+		WHERE id = ?
+	`, sqliteTimestamp(now.Add(grace)), sqliteTimestamp(now), ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark payment pending: %w", err)
+	}
+
+	ticket, err := scanTicket(tx.QueryRowContext(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE id = ?`, ticketID))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return ticket, nil
+}
+
+// CompletePayment confirms a reservation whose payment went through. It needs no owner,
+// since only the payment integration calls it, and fails like ConfirmReservation; a hold
+// that lapsed before the payment landed reports ErrTicketExpired so it can be refunded.
+func (db *DB) CompletePayment(ctx context.Context, ticketID int64) error {
+	return db.confirmReservationTrusted(ctx, ticketID)
+}
+
+// paymentGrace returns the hold extension for in-flight payments.
+func (h *Handlers) paymentGrace() time.Duration {
+	if h.PaymentGrace > 0 {
+		return h.PaymentGrace
+	}
+	return defaultPaymentGrace
+}
+
+// HandlePaymentPending handles POST /tickets/{id}/payment-pending, called by the payment
+// integration when checkout starts so the hold does not lapse mid-payment.
+func (h *Handlers) HandlePaymentPending(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ticket ID format"})
+		return
+	}
+
+	ticket, err := h.DB.MarkPaymentPending(r.Context(), ticketID, h.paymentGrace())
+	if sendPaymentError(w, err) {
+		return
+	}
+	SendJSON(w, http.StatusOK, ticket)
+}
+
+// HandlePaymentComplete handles POST /tickets/{id}/payment-complete, confirming the ticket
+// and emailing its confirmation code.
+func (h *Handlers) HandlePaymentComplete(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ticket ID format"})
+		return
+	}
+
+	if sendPaymentError(w, h.DB.CompletePayment(r.Context(), ticketID)) {
+		return
+	}
+
+	ticket, err := h.DB.GetTicket(r.Context(), ticketID)
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	h.sendConfirmationCode(r.Context(), ticket)
+	SendJSON(w, http.StatusOK, ticket)
+}
+
+// sendPaymentError answers err from the payment endpoints and reports whether it did.
+func sendPaymentError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrTicketNotFound):
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error(), "code": "ticket_not_found"})
+	case errors.Is(err, ErrTicketExpired):
+		SendJSON(w, http.StatusGone, map[string]string{"error": err.Error(), "code": "ticket_expired"})
+	case errors.Is(err, ErrEventCancelled):
+		SendJSON(w, http.StatusGone, map[string]string{"error": err.Error(), "code": "event_cancelled"})
+	case errors.Is(err, ErrAlreadyConfirmed):
+		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error(), "code": "already_confirmed"})
+	case errors.Is(err, ErrTicketNotActive):
+		SendJSON(w, http.StatusConflict, map[string]string{"error": err.Error(), "code": "ticket_cancelled"})
+	case errors.Is(err, ErrNoPaymentDue):
+		SendJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error(), "code": "no_payment_due"})
+	default:
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPaymentPendingExtendsHoldUntilPaymentCompletes(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+	sender := &recordingSender{}
+	router := (&Handlers{DB: db, Email: sender, PaymentGrace: 20 * time.Minute, AllowHeaderRole: true}).Routes()
+
+	post := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("X-Role", "admin")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	paid, err := db.CreateEvent(ctx, Event{Name: "Paid Gala", TotalSpots: 5, PriceCents: 4000})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	ticket, err := db.RegisterForEvent(ctx, RegisterParams{EventID: paid.ID, Email: "payer@example.com", IdempotencyKey: "payer"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	rec := post(fmt.Sprintf("/tickets/%d/payment-pending", ticket.ID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 marking payment pending, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var pending Ticket
+	if err := json.Unmarshal(rec.Body.Bytes(), &pending); err != nil {
+		t.Fatalf("Failed to decode ticket: %v", err)
+	}
+	if want := clock.Now().Add(20 * time.Minute); !pending.AwaitingPayment || !pending.ExpiresAt.Equal(want) {
+		t.Fatalf("Expected awaiting payment until %v, got %+v", want, pending)
+	}
+
+	// Past the original hold the reclaimer leaves the paying reservation alone
+	clock.Advance(defaultHoldDuration + time.Minute)
+	if n, err := db.ReclaimExpiredSeats(ctx); err != nil || n != 0 {
+		t.Fatalf("Expected nothing reclaimed within the payment grace, got %d (%v)", n, err)
+	}
+
+	rec = post(fmt.Sprintf("/tickets/%d/payment-complete", ticket.ID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 completing payment, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var done Ticket
+	if err := json.Unmarshal(rec.Body.Bytes(), &done); err != nil {
+		t.Fatalf("Failed to decode ticket: %v", err)
+	}
+	if done.Status != "confirmed" || done.AwaitingPayment {
+		t.Errorf("Expected a confirmed ticket no longer awaiting payment, got %+v", done)
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "payer@example.com" {
+		t.Errorf("Expected the confirmation code emailed to the payer, got %v", sender.sent)
+	}
+	if rec := post(fmt.Sprintf("/tickets/%d/payment-complete", ticket.ID)); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 completing twice, got %d", rec.Code)
+	}
+
+	// Free tickets have no payment to wait for
+	free, err := db.CreateEvent(ctx, Event{Name: "Free Meetup", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	freeTicket, err := db.RegisterForEvent(ctx, RegisterParams{EventID: free.ID, Email: "free@example.com", IdempotencyKey: "free"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if rec := post(fmt.Sprintf("/tickets/%d/payment-pending", freeTicket.ID)); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a free ticket, got %d", rec.Code)
+	}
+}
+
+func TestPaymentPendingExtendsOnlyOnce(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+
+	paid, err := db.CreateEvent(ctx, Event{Name: "Paid Gala", TotalSpots: 5, PriceCents: 4000})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	ticket, err := db.RegisterForEvent(ctx, RegisterParams{EventID: paid.ID, Email: "payer@example.com", IdempotencyKey: "payer"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	first, err := db.MarkPaymentPending(ctx, ticket.ID, 20*time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to mark payment pending: %v", err)
+	}
+	clock.Advance(15 * time.Minute)
+	again, err := db.MarkPaymentPending(ctx, ticket.ID, 20*time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to repeat payment pending: %v", err)
+	}
+	if !again.ExpiresAt.Equal(first.ExpiresAt) {
+		t.Errorf("Expected a repeat call to keep the expiry at %v, got %v", first.ExpiresAt, again.ExpiresAt)
+	}
+}
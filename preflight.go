@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// minSafeHoldDuration is the shortest reservation hold a person can realistically
+// confirm within: fill in a form, maybe pay, and submit over a slow connection.
+const minSafeHoldDuration = 30 * time.Second
+
+// PreflightConfig is the startup configuration Preflight checks before serving.
+type PreflightConfig struct {
+	HoldDuration time.Duration
+	// StrictHold turns a hold below minSafeHoldDuration from a warning into an error.
+	StrictHold bool
+	// SnapshotInterval is -snapshot-interval; 0 disables the snapshot worker.
+	SnapshotInterval time.Duration
+}
+
+// Preflight rejects configurations that cannot work and warns about ones that would
+// quietly break the confirm flow.
+func Preflight(cfg PreflightConfig) error {
+	if cfg.HoldDuration <= 0 {
+		return fmt.Errorf("hold duration must be positive, got %s", cfg.HoldDuration)
+	}
+	if cfg.HoldDuration < minSafeHoldDuration {
+		if cfg.StrictHold {
+			return fmt.Errorf("hold duration %s is below the %s minimum; reservations would lapse before anyone could confirm them", cfg.HoldDuration, minSafeHoldDuration)
+		}
+		slog.Warn("hold duration is too short for users to confirm in time", "hold_duration", cfg.HoldDuration, "minimum", minSafeHoldDuration)
+	}
+	if cfg.SnapshotInterval > 0 && cfg.SnapshotInterval < minSnapshotInterval {
+		return fmt.Errorf("snapshot interval %s is below the %s minimum", cfg.SnapshotInterval, minSnapshotInterval)
+	}
+	return nil
+}
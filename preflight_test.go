@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreflightFlagsShortHoldDuration(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	if err := Preflight(PreflightConfig{HoldDuration: 2 * time.Second}); err != nil {
+		t.Fatalf("Expected only a warning by default, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "hold duration is too short") {
+		t.Errorf("Expected a warning for a 2s hold, got %q", logs.String())
+	}
+
+	if err := Preflight(PreflightConfig{HoldDuration: 2 * time.Second, StrictHold: true}); err == nil {
+		t.Error("Expected an error for a 2s hold in strict mode")
+	}
+	if err := Preflight(PreflightConfig{HoldDuration: 0}); err == nil {
+		t.Error("Expected an error for a zero hold")
+	}
+	if err := Preflight(PreflightConfig{HoldDuration: defaultHoldDuration, SnapshotInterval: 500 * time.Millisecond}); err == nil {
+		t.Error("Expected an error for a sub-second snapshot interval")
+	}
+
+	logs.Reset()
+	if err := Preflight(PreflightConfig{HoldDuration: defaultHoldDuration, StrictHold: true}); err != nil || logs.Len() != 0 {
+		t.Errorf("Expected the default hold to pass silently, got %v %q", err, logs.String())
+	}
+}
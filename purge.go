@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTicketRetention is how long cancelled tickets are kept before they may be purged.
+const defaultTicketRetention = 30 * 24 * time.Hour
+
+// PurgeCancelledTickets deletes tickets cancelled before cutoff and returns how many
+// went. It goes by cancellation time, not creation time, so a ticket bought long ago but
+// cancelled a minute ago, and the audit entries that point at it, are kept for the full
+// retention period. Lapsed holds are cancelled by the reclaimer, so they are covered too;
+// reserved and confirmed tickets are never touched, however old. Deleting a ticket frees
+// its idempotency key for reuse.
+func (db *DB) PurgeCancelledTickets(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM tickets WHERE status = 'cancelled' AND cancelled_at < ?`, sqliteTimestamp(cutoff))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge tickets: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// RunPurger purges tickets cancelled longer than retention ago each interval until ctx is cancelled.
+func RunPurger(ctx context.Context, db *DB, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("ticket purge worker stopping")
+			return
+		case <-ticker.C:
+			purged, err := db.PurgeCancelledTickets(ctx, db.Clock.Now().Add(-retention))
+			if err != nil && ctx.Err() == nil {
+				slog.Error("failed purging cancelled tickets", "error", err)
+			}
+			if purged > 0 {
+				slog.Info("purged cancelled tickets", "count", purged)
+			}
+		}
+	}
+}
+
+var errInvalidRetention = errors.New("older_than must be a positive number of days such as 30d, or a duration such as 12h")
+
+// parseRetention reads a retention period as a whole number of days ("30d") or a Go
+// duration ("12h").
+func parseRetention(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errInvalidRetention
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, errInvalidRetention
+		}
+	}
+	if d <= 0 {
+		return 0, errInvalidRetention
+	}
+	return d, nil
+}
+
+// ticketRetention returns the default age for POST /admin/purge.
+func (h *Handlers) ticketRetention() time.Duration {
+	if h.TicketRetention > 0 {
+		return h.TicketRetention
+	}
+	return defaultTicketRetention
+}
+
+// HandleAdminPurge handles POST /admin/purge?older_than=30d, deleting tickets cancelled
+// more than older_than ago, or -ticket-retention when it is omitted.
+func (h *Handlers) HandleAdminPurge(w http.ResponseWriter, r *http.Request) {
+	retention := h.ticketRetention()
+	if v := r.URL.Query().Get("older_than"); v != "" {
+		var err error
+		if retention, err = parseRetention(v); err != nil {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	cutoff := h.DB.Clock.Now().Add(-retention).UTC().Truncate(time.Second)
+	purged, err := h.DB.PurgeCancelledTickets(r.Context(), cutoff)
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error while purging tickets"})
+		return
+	}
+	SendJSON(w, http.StatusOK, map[string]interface{}{"purged": purged, "cutoff": cutoff})
+}
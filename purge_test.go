@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdminPurgeRemovesOnlyOldCancelledTickets(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Long Running Series", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	register := func(email string) *Ticket {
+		ticket, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: email, IdempotencyKey: "key_" + email})
+		if err != nil {
+			t.Fatalf("Failed to register %s: %v", email, err)
+		}
+		return ticket
+	}
+
+	// Forty days ago: one cancelled, one confirmed, one hold the reclaimer never reached
+	oldCancelled := register("old-cancelled@example.com")
+	if err := db.CancelTicket(ctx, oldCancelled.ID, "old-cancelled@example.com"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+	oldConfirmed := register("old-confirmed@example.com")
+	if err := db.ConfirmReservation(ctx, oldConfirmed.ID, "old-confirmed@example.com", ""); err != nil {
+		t.Fatalf("Failed to confirm: %v", err)
+	}
+	oldReserved := register("old-reserved@example.com")
+	longHeld := register("long-held@example.com")
+	if err := db.ConfirmReservation(ctx, longHeld.ID, "long-held@example.com", ""); err != nil {
+		t.Fatalf("Failed to confirm: %v", err)
+	}
+
+	// Yesterday: cancellations still inside the retention period, including one of a
+	// ticket bought forty days ago
+	clock.Advance(39 * 24 * time.Hour)
+	recentCancelled := register("recent-cancelled@example.com")
+	for _, ticket := range []*Ticket{recentCancelled, longHeld} {
+		if err := db.CancelTicket(ctx, ticket.ID, ticket.UserEmail); err != nil {
+			t.Fatalf("Failed to cancel: %v", err)
+		}
+	}
+	clock.Advance(24 * time.Hour)
+
+	if rec := serve(router, http.MethodPost, "/admin/purge?older_than=30x", "admin"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed older_than, got %d", rec.Code)
+	}
+	rec := serve(router, http.MethodPost, "/admin/purge?older_than=30d", "admin")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Purged int64 `json:"purged"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Purged != 1 {
+		t.Errorf("Expected 1 ticket purged, got %d", body.Purged)
+	}
+
+	if _, err := db.GetTicket(ctx, oldCancelled.ID); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("Expected the old cancelled ticket to be gone, got %v", err)
+	}
+	for _, kept := range []*Ticket{oldConfirmed, oldReserved, recentCancelled, longHeld} {
+		if _, err := db.GetTicket(ctx, kept.ID); err != nil {
+			t.Errorf("Expected ticket %d for %s to remain, got %v", kept.ID, kept.UserEmail, err)
+		}
+	}
+
+	// The purged ticket's idempotency key can be used again
+	register("old-cancelled@example.com")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ReclaimStatus records the outcome of the reclaim worker's latest pass, so a stalled or
+// failing worker shows up in GET /admin/reclaim/status. The zero value means no pass has run.
+type ReclaimStatus struct {
+	mu        sync.Mutex
+	lastRun   time.Time
+	reclaimed int64
+	lastErr   error
+}
+
+// ReclaimStatusReport is the JSON body of GET /admin/reclaim/status.
+type ReclaimStatusReport struct {
+	// LastRunTime is when the latest pass finished; null until the first one has.
+	LastRunTime        *time.Time `json:"last_run_time"`
+	LastReclaimedCount int64      `json:"last_reclaimed_count"`
+	// LastError is the latest pass's failure, empty when it succeeded.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// record stores the outcome of a pass that finished at. A nil status records nothing.
+func (s *ReclaimStatus) record(at time.Time, reclaimed int64, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRun, s.reclaimed, s.lastErr = at, reclaimed, err
+}
+
+// Report returns the latest pass's outcome.
+func (s *ReclaimStatus) Report() ReclaimStatusReport {
+	var report ReclaimStatusReport
+	if s == nil {
+		return report
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.lastRun.IsZero() {
+		lastRun := s.lastRun.UTC()
+		report.LastRunTime = &lastRun
+	}
+	report.LastReclaimedCount = s.reclaimed
+	if s.lastErr != nil {
+		report.LastError = s.lastErr.Error()
+	}
+	return report
+}
+
+// HandleReclaimStatus handles GET /admin/reclaim/status
+func (h *Handlers) HandleReclaimStatus(w http.ResponseWriter, r *http.Request) {
+	SendJSON(w, http.StatusOK, h.Reclaim.Report())
+}
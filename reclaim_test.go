@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReclaimStatusReportsLatestPass(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+	status := &ReclaimStatus{}
+	router := (&Handlers{DB: db, Reclaim: status, AllowHeaderRole: true}).Routes()
+
+	report := func() ReclaimStatusReport {
+		rec := serve(router, http.MethodGet, "/admin/reclaim/status", "admin")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var report ReclaimStatusReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Failed to decode status: %v", err)
+		}
+		return report
+	}
+
+	if got := report(); got.LastRunTime != nil {
+		t.Fatalf("Expected no last_run_time before the first pass, got %v", got.LastRunTime)
+	}
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Morning Yoga", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: email, IdempotencyKey: "key_" + email}); err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+	}
+	clock.Advance(defaultHoldDuration)
+
+	reclaimExpiredSeats(ctx, db, time.Second, status)
+	got := report()
+	if got.LastRunTime == nil || !got.LastRunTime.Equal(clock.Now()) {
+		t.Errorf("Expected last_run_time %v, got %v", clock.Now(), got.LastRunTime)
+	}
+	if got.LastReclaimedCount != 2 || got.LastError != "" {
+		t.Errorf("Expected 2 reclaimed without error, got %+v", got)
+	}
+
+	// A failing pass is reported and the count reset
+	db.Close()
+	clock.Advance(10 * time.Second)
+	reclaimExpiredSeats(ctx, db, time.Second, status)
+	got = report()
+	if got.LastError == "" || got.LastReclaimedCount != 0 || !got.LastRunTime.Equal(clock.Now()) {
+		t.Errorf("Expected the failed pass to be reported, got %+v", got)
+	}
+
+	if rec := serve(router, http.MethodGet, "/admin/reclaim/status", "user"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-admins, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Reconciliation is the outcome of checking one event's available_spots against its tickets.
+type Reconciliation struct {
+	EventID int64 `json:"event_id"`
+	// Before is the stored available_spots; After is what the tickets say it should be.
+	Before    int  `json:"available_spots_before"`
+	After     int  `json:"available_spots"`
+	Corrected bool `json:"corrected"`
+}
+
+// ReconcileEvent recomputes eventID's available_spots as total_spots minus the spots held
+// by its reserved and confirmed tickets, and stores it if the two disagree. An oversold
+// event cannot go below zero, so it is set to zero and logged as an error.
+func (db *DB) ReconcileEvent(ctx context.Context, eventID int64) (*Reconciliation, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	rec := Reconciliation{EventID: eventID}
+	var total, held int
+	err = tx.QueryRowContext(ctx, `
+		SELECT total_spots, available_spots,
+			(SELECT COALESCE(SUM(quantity), 0) FROM tickets WHERE event_id = events.id AND status IN ('reserved', 'confirmed'))
+		FROM events WHERE id = ?
+	`, eventID).Scan(&total, &rec.Before, &held)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to count held spots: %w", err)
+	}
+
+	rec.After = total - held
+	if rec.After < 0 {
+		slog.ErrorContext(ctx, "event is oversold", "event_id", eventID, "total_spots", total, "held", held)
+		rec.After = 0
+	}
+	if rec.After == rec.Before {
+		return &rec, tx.Commit()
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE events SET available_spots = ? WHERE id = ?`, rec.After, eventID); err != nil {
+		return nil, fmt.Errorf("failed to correct available spots: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	rec.Corrected = true
+	slog.WarnContext(ctx, "corrected available_spots drift", "event_id", eventID, "stored", rec.Before, "expected", rec.After)
+	return &rec, nil
+}
+
+// ReconcileAllEvents runs ReconcileEvent for every event, each in its own transaction so
+// registrations are never blocked for long, and returns how many were corrected.
+func (db *DB) ReconcileAllEvents(ctx context.Context) (int, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id FROM events WHERE status != ?`, EventStatusCancelled)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list events: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read event id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	corrected := 0
+	for _, id := range ids {
+		rec, err := db.ReconcileEvent(ctx, id)
+		if errors.Is(err, ErrEventNotFound) {
+			continue
+		}
+		if err != nil {
+			return corrected, err
+		}
+		if rec.Corrected {
+			corrected++
+		}
+	}
+	return corrected, nil
+}
+
+// RunReconciler reconciles every event each interval until ctx is cancelled.
+func RunReconciler(ctx context.Context, db *DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("reconcile worker stopping")
+			return
+		case <-ticker.C:
+			corrected, err := db.ReconcileAllEvents(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("failed reconciling events", "error", err)
+			}
+			if corrected > 0 {
+				slog.Info("reconciled events", "corrected", corrected)
+			}
+		}
+	}
+}
+
+// HandleAdminReconcile handles POST /admin/reconcile/{id}.
+func (h *Handlers) HandleAdminReconcile(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
+		return
+	}
+
+	rec, err := h.DB.ReconcileEvent(r.Context(), eventID)
+	if errors.Is(err, ErrEventNotFound) {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error during reconciliation"})
+		return
+	}
+	if rec.Corrected {
+		h.EventCache.Invalidate()
+	}
+	SendJSON(w, http.StatusOK, rec)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestReconcileRepairsCorruptedAvailability(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Drifting", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	for i, quantity := range []int{2, 1} {
+		if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: fmt.Sprintf("r%d@example.com", i), IdempotencyKey: fmt.Sprintf("r_%d", i), Quantity: quantity}); err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+	}
+	cancelled, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "gone@example.com", IdempotencyKey: "gone"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if err := db.CancelTicket(ctx, cancelled.ID, "gone@example.com"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+
+	// Simulate a crash that lost track of spots
+	if _, err := db.ExecContext(ctx, `UPDATE events SET available_spots = 2 WHERE id = ?`, evt.ID); err != nil {
+		t.Fatalf("Failed to corrupt event: %v", err)
+	}
+
+	if rec := serve(router, http.MethodPost, fmt.Sprintf("/admin/reconcile/%d", evt.ID), "user"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin, got %d", rec.Code)
+	}
+	rec := serve(router, http.MethodPost, fmt.Sprintf("/admin/reconcile/%d", evt.ID), "admin")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result Reconciliation
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if !result.Corrected || result.Before != 2 || result.After != 7 {
+		t.Errorf("Expected a correction from 2 to 7, got %+v", result)
+	}
+	if got, _ := db.GetEvent(ctx, evt.ID); got.AvailableSpots != 7 {
+		t.Errorf("Expected 7 available spots stored, got %d", got.AvailableSpots)
+	}
+
+	// A consistent event is left alone, including by the periodic pass
+	if corrected, err := db.ReconcileAllEvents(ctx); err != nil || corrected != 0 {
+		t.Errorf("Expected nothing left to correct, got %d (%v)", corrected, err)
+	}
+}
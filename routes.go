@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// Routes builds the application router wrapped in the global middleware chain.
+//
+// Every route is registered with its method, so handlers never check r.Method
+// themselves: ServeMux answers other methods with 405 and an Allow header listing
+// the methods registered for that path.
+func (h *Handlers) Routes() http.Handler {
+	if h.Stats == nil {
+		h.Stats = NewStatsAggregator(h.DB, defaultStatsMaxAge)
+	}
+	if h.EventCache == nil {
+		h.EventCache = NewEventListCache(defaultEventListCacheTTL)
+	}
+
+	// Roles come from verified JWT claims; the X-Role header is only trusted when allowed
+	requireRole := func(role string) func(http.Handler) http.Handler {
+		return RBACMiddleware(role, h.AllowHeaderRole)
+	}
+
+	// Standard Library Router
+	mux := http.NewServeMux()
+
+	// Create Event (Protected: Organizer/Admin)
+	mux.Handle("POST /events", requireRole("organizer")(RequireJSON(http.HandlerFunc(h.HandleCreateEvent))))
+
+	// Bulk import from a CSV upload (Protected: Organizer/Admin)
+	mux.Handle("POST /events/import", requireRole("organizer")(http.HandlerFunc(h.HandleImportEvents)))
+
+	// List Events (Public)
+	mux.HandleFunc("GET /events", h.HandleListEvents)
+
+	// Spot counts for many events in one call (Public)
+	mux.HandleFunc("GET /events/availability", h.HandleEventAvailability)
+
+	// Events owned by the calling organizer (Protected: Organizer/Admin)
+	mux.Handle("GET /organizer/events", requireRole("organizer")(http.HandlerFunc(h.HandleListOrganizerEvents)))
+
+	// Server clock and hold window for client countdown timers (Public)
+	mux.HandleFunc("GET /config", h.HandleConfig)
+
+	// Calendar invite for a published event (Public)
+	mux.HandleFunc("GET /events/{id}/ical", h.HandleEventICal)
+
+	// Seat map and availability for reserved-seating events (Public)
+	mux.HandleFunc("GET /events/{id}/seats", h.HandleListSeats)
+
+	// Cached sales funnel numbers for dashboards (Public)
+	mux.HandleFunc("GET /events/{id}/stats", h.HandleEventStats)
+
+	// Available spots over time from capacity snapshots, for sell-through charts (Public)
+	mux.HandleFunc("GET /events/{id}/velocity", h.HandleEventVelocity)
+
+	// Register (Protected: User). Refused while the instance is draining.
+	mux.Handle("POST /events/{id}/register", requireRole("user")(h.RejectWhileDraining(RequireJSON(http.HandlerFunc(h.HandleRegister)))))
+
+	// Release every registration at once (Protected: owning Organizer/Admin)
+	mux.Handle("POST /events/{id}/cancel-registrations", requireRole("organizer")(http.HandlerFunc(h.HandleCancelEventRegistrations)))
+
+	// Group booking: one ticket per attendee under a single order (Protected: User).
+	// Refused while the instance is draining.
+	mux.Handle("POST /events/{id}/orders", requireRole("user")(h.RejectWhileDraining(RequireJSON(http.HandlerFunc(h.HandleCreateOrder)))))
+
+	// Waitlist for sold-out events (Protected: User)
+	mux.Handle("POST /events/{id}/waitlist", requireRole("user")(http.HandlerFunc(h.HandleJoinWaitlist)))
+
+	// Ticket status (Protected: User)
+	mux.Handle("GET /tickets/{id}", requireRole("user")(http.HandlerFunc(h.HandleGetTicket)))
+
+	// Confirm (Protected: User)
+	mux.Handle("POST /tickets/{id}/confirm", requireRole("user")(RequireJSON(http.HandlerFunc(h.HandleConfirm))))
+
+	// Confirm every ticket in a group order at once (Protected: User)
+	mux.Handle("POST /orders/{id}/confirm", requireRole("user")(RequireJSON(http.HandlerFunc(h.HandleConfirmOrder))))
+
+	// Payment provider callbacks: hold while paying, confirm once paid (Protected: Admin)
+	mux.Handle("POST /tickets/{id}/payment-pending", requireRole("admin")(http.HandlerFunc(h.HandlePaymentPending)))
+	mux.Handle("POST /tickets/{id}/payment-complete", requireRole("admin")(http.HandlerFunc(h.HandlePaymentComplete)))
+
+	// Re-send a lost confirmation code by email (Protected: User)
+	mux.Handle("POST /tickets/recover", requireRole("user")(http.HandlerFunc(h.HandleRecoverTicket)))
+
+	// Cancel, handing the seat to the waitlist (Protected: User)
+	mux.Handle("POST /tickets/{id}/cancel", requireRole("user")(http.HandlerFunc(h.HandleCancelTicket)))
+
+	// Every event including drafts and cancelled ones, streamed (Protected: Admin)
+	mux.Handle("GET /admin/events", requireRole("admin")(http.HandlerFunc(h.HandleAdminListEvents)))
+
+	// Force-cancel any ticket, audited (Protected: Admin)
+	mux.Handle("DELETE /admin/tickets/{id}", requireRole("admin")(http.HandlerFunc(h.HandleAdminCancelTicket)))
+
+	// Move a reservation's hold expiry for support cases, audited (Protected: Admin)
+	mux.Handle("PATCH /admin/tickets/{id}", requireRole("admin")(http.HandlerFunc(h.HandleAdminSetTicketExpiry)))
+
+	// Recompute an event's available_spots from its tickets (Protected: Admin)
+	mux.Handle("POST /admin/reconcile/{id}", requireRole("admin")(http.HandlerFunc(h.HandleAdminReconcile)))
+
+	// Outcome of the reclaim worker's latest pass (Protected: Admin)
+	mux.Handle("GET /admin/reclaim/status", requireRole("admin")(http.HandlerFunc(h.HandleReclaimStatus)))
+
+	// Delete old cancelled tickets (Protected: Admin)
+	mux.Handle("POST /admin/purge", requireRole("admin")(http.HandlerFunc(h.HandleAdminPurge)))
+
+	// Drain mode toggles for zero-downtime deploys (Protected: Admin)
+	mux.Handle("POST /admin/drain", requireRole("admin")(http.HandlerFunc(h.HandleDrain)))
+	mux.Handle("POST /admin/undrain", requireRole("admin")(http.HandlerFunc(h.HandleUndrain)))
+
+	// The API proper is rate limited; operational endpoints mounted beside it are not
+	var api http.Handler = jsonFallback(mux)
+	api = RateLimitMiddleware(api)
+
+	root := http.NewServeMux()
+	root.Handle("/", api)
+
+	// Liveness and readiness probes (Public, never rate limited)
+	root.HandleFunc("GET /healthz", h.HandleHealthz)
+	root.HandleFunc("GET /ready", h.HandleReady)
+
+	// Runtime profiles (Protected: Admin, only with -enable-pprof)
+	if h.EnablePprof {
+		root.Handle("/debug/pprof/", requireRole("admin")(pprofHandler()))
+	}
+
+	// Apply Global Middlewares
+	var handler http.Handler = root
+	handler = APIKeyMiddleware(h.APIKeys)(handler)
+	handler = JWTMiddleware(h.JWTSecret)(handler) // Authenticate first so the limiter and RBAC see the role
+	handler = CORSMiddleware(h.CORS)(handler)
+	handler = HTTPSMiddleware(h.HTTPS)(handler)
+	handler = LoggingMiddleware(handler)
+	handler = RecoveryMiddleware(handler)
+	handler = RequestIDMiddleware(handler)
+	return handler
+}
+
+// jsonFallback answers requests that match no route with the JSON error shape used
+// everywhere else instead of ServeMux's plaintext bodies: 404 for unknown paths and 405,
+// keeping the Allow header, for known paths hit with the wrong method.
+func jsonFallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallback, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Run the mux's own fallback against a scratch writer to learn which error it is
+		probe := &fallbackProbe{header: http.Header{}}
+		fallback.ServeHTTP(probe, r)
+		switch probe.status {
+		case http.StatusNotFound:
+			SendJSON(w, http.StatusNotFound, map[string]string{"error": "not found", "code": "NOT_FOUND"})
+		case http.StatusMethodNotAllowed:
+			w.Header().Set("Allow", probe.header.Get("Allow"))
+			SendJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed", "code": "METHOD_NOT_ALLOWED"})
+		default:
+			mux.ServeHTTP(w, r)
+		}
+	})
+}
+
+// fallbackProbe records the status and headers a handler writes and discards the body.
+type fallbackProbe struct {
+	header http.Header
+	status int
+}
+
+func (p *fallbackProbe) Header() http.Header { return p.header }
+
+func (p *fallbackProbe) WriteHeader(status int) {
+	if p.status == 0 {
+		p.status = status
+	}
+}
+
+func (p *fallbackProbe) Write(b []byte) (int, error) {
+	p.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
+
+// pprofHandler serves the net/http/pprof endpoints without touching http.DefaultServeMux.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
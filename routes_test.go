@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(router http.Handler, method, path, role string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if role != "" {
+		req.Header.Set("X-Role", role)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPprofDisabledByDefault(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t)}).Routes()
+
+	if rec := serve(router, http.MethodGet, "/debug/pprof/", "admin"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 with pprof disabled, got %d", rec.Code)
+	}
+}
+
+func TestPprofRequiresAdminAndSkipsRateLimit(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t), EnablePprof: true, AllowHeaderRole: true}).Routes()
+
+	if rec := serve(router, http.MethodGet, "/debug/pprof/", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a role, got %d", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/debug/pprof/", "user"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin, got %d", rec.Code)
+	}
+
+	// Well past the per-IP rate limit; profiling must never be throttled
+	for i := 0; i < 20; i++ {
+		if rec := serve(router, http.MethodGet, "/debug/pprof/cmdline", "admin"); rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200 for admin, got %d", i, rec.Code)
+		}
+	}
+}
+
+func TestDisallowedMethodReturns405WithAllow(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t)}).Routes()
+
+	rec := serve(router, http.MethodDelete, "/events", "admin")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405, got %d", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow != "GET, HEAD, POST" {
+		t.Errorf("Expected Allow: GET, HEAD, POST, got %q", allow)
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["code"] != "METHOD_NOT_ALLOWED" {
+		t.Errorf("Expected a JSON METHOD_NOT_ALLOWED body, got %v (%v)", body, err)
+	}
+
+	rec = serve(router, http.MethodGet, "/tickets/1/confirm", "user")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST" {
+		t.Errorf("Expected 405 with Allow: POST, got %d %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func TestUnknownPathReturnsJSON404(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t)}).Routes()
+
+	for _, path := range []string{"/nope", "/events/1/unknown"} {
+		rec := serve(router, http.MethodGet, path, "")
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected application/json, got %q", path, ct)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: expected a JSON body: %v", path, err)
+		}
+		if body["error"] != "not found" || body["code"] != "NOT_FOUND" {
+			t.Errorf("%s: unexpected body %v", path, body)
+		}
+	}
+}
+
+func TestJSONEndpointsRequireJSONContentType(t *testing.T) {
+	router := (&Handlers{DB: NewTestDB(t), AllowHeaderRole: true}).Routes()
+
+	post := func(contentType string) int {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"name":"Typed","total_spots":5}`))
+		req.Header.Set("X-Role", "organizer")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, ct := range []string{"", "application/x-www-form-urlencoded", "text/plain"} {
+		if code := post(ct); code != http.StatusUnsupportedMediaType {
+			t.Errorf("Content-Type %q: expected 415, got %d", ct, code)
+		}
+	}
+	if code := post("application/json; charset=utf-8"); code != http.StatusCreated {
+		t.Errorf("Expected 201 for JSON with a charset, got %d", code)
+	}
+}
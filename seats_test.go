@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentRaceForSameSeat(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Opera Night", TotalSpots: 3}, "A1", "A2", "A3")
+	if err != nil {
+		t.Fatalf("Failed to create seated event: %v", err)
+	}
+
+	const racers = 20
+	results := make([]error, racers)
+	var wg sync.WaitGroup
+	wg.Add(racers)
+	for i := 0; i < racers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			_, results[i] = db.RegisterForEvent(ctx, RegisterParams{
+				EventID:        evt.ID,
+				Email:          fmt.Sprintf("fan%d@example.com", i),
+				IdempotencyKey: fmt.Sprintf("fan_%d", i),
+				SeatLabel:      "A1",
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	winners := 0
+	for i, err := range results {
+		switch {
+		case err == nil:
+			winners++
+		case errors.Is(err, ErrSeatTaken):
+		default:
+			t.Errorf("Racer %d got unexpected error: %v", i, err)
+		}
+	}
+	if winners != 1 {
+		t.Fatalf("Expected exactly one racer to win seat A1, got %d", winners)
+	}
+
+	// Losers' transactions must have rolled back their capacity decrement
+	updated, _ := db.GetEvent(ctx, evt.ID)
+	if updated.AvailableSpots != 2 {
+		t.Errorf("Expected 2 spots left, got %d", updated.AvailableSpots)
+	}
+}
+
+func TestSeatAssignmentAndRelease(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Theatre", TotalSpots: 2}, "B1", "B2")
+	if err != nil {
+		t.Fatalf("Failed to create seated event: %v", err)
+	}
+
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "x@example.com", IdempotencyKey: "x", SeatLabel: "Z9"}); !errors.Is(err, ErrSeatNotFound) {
+		t.Errorf("Expected ErrSeatNotFound for unknown label, got %v", err)
+	}
+
+	// No label: the first free seat is assigned automatically
+	registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "auto@example.com", IdempotencyKey: "auto"})
+	if err != nil {
+		t.Fatalf("Auto-assigned registration failed: %v", err)
+	}
+	ticketID := registered.ID
+
+	seats, err := db.ListSeats(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("ListSeats failed: %v", err)
+	}
+	if seats[0].Status != SeatAssigned || seats[1].Status != SeatAvailable {
+		t.Fatalf("Expected B1 assigned and B2 free, got %+v", seats)
+	}
+
+	if err := db.CancelTicket(ctx, ticketID, "auto@example.com"); err != nil {
+		t.Fatalf("CancelTicket failed: %v", err)
+	}
+	seats, _ = db.ListSeats(ctx, evt.ID)
+	if seats[0].Status != SeatAvailable {
+		t.Errorf("Expected B1 released after cancellation, got %+v", seats)
+	}
+
+	plain, _ := db.CreateEvent(ctx, Event{Name: "General Admission", TotalSpots: 5})
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: plain.ID, Email: "y@example.com", IdempotencyKey: "y", SeatLabel: "A1"}); !errors.Is(err, ErrNoSeatMap) {
+		t.Errorf("Expected ErrNoSeatMap for a general admission event, got %v", err)
+	}
+}
+
+func TestHandleListSeats(t *testing.T) {
+	db := NewTestDB(t)
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Cinema", TotalSpots: 2}, "C1", "C2")
+	if err != nil {
+		t.Fatalf("Failed to create seated event: %v", err)
+	}
+	if _, err := db.RegisterForEvent(context.Background(), RegisterParams{EventID: evt.ID, Email: "z@example.com", IdempotencyKey: "z", SeatLabel: "C2"}); err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/events/x/seats", nil)
+	req.SetPathValue("id", fmt.Sprint(evt.ID))
+	rec := httptest.NewRecorder()
+	(&Handlers{DB: db}).HandleListSeats(rec, req)
+
+	var body struct {
+		Total     int    `json:"total"`
+		Available int    `json:"available"`
+		Seats     []Seat `json:"seats"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode seat map: %v", err)
+	}
+	if body.Total != 2 || body.Available != 1 || body.Seats[1] != (Seat{Label: "C2", Status: SeatAssigned}) {
+		t.Errorf("Unexpected seat map: %+v", body)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultSnapshotRetention is how long capacity snapshots are kept before the snapshot
+// worker prunes them.
+const defaultSnapshotRetention = 90 * 24 * time.Hour
+
+// minSnapshotInterval is the shortest -snapshot-interval accepted. captured_at has whole
+// second precision, so faster captures would only pile up snapshots sharing a timestamp.
+const minSnapshotInterval = time.Second
+
+// CapacitySnapshot is one event's available_spots at a moment, for charting sell-through.
+type CapacitySnapshot struct {
+	ID             int64     `json:"id"`
+	AvailableSpots int       `json:"available_spots"`
+	CapturedAt     time.Time `json:"captured_at"`
+}
+
+// RecordCapacitySnapshots stores the current available_spots of every active event, i.e.
+// published and not yet started, all stamped with the same capture time. It returns how
+// many snapshots were taken.
+func (db *DB) RecordCapacitySnapshots(ctx context.Context) (int64, error) {
+	now := sqliteTimestamp(db.Clock.Now())
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO capacity_snapshots (event_id, available_spots, captured_at)
+		SELECT id, available_spots, ? FROM events
+		WHERE status = ? AND (starts_at IS NULL OR starts_at > ?)
+	`, now, EventStatusPublished, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record capacity snapshots: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// PruneCapacitySnapshots deletes snapshots captured before cutoff and returns how many went.
+func (db *DB) PruneCapacitySnapshots(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM capacity_snapshots WHERE captured_at < ?`, sqliteTimestamp(cutoff))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune capacity snapshots: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// CapacitySnapshots returns up to limit of eventID's snapshots captured at or after from,
+// oldest first; a zero from starts at the oldest. afterID, when set, is a keyset cursor:
+// only snapshots sorting after the snapshot with this id are returned, so captures sharing
+// a second are neither repeated nor skipped across pages. Drafts report ErrEventNotFound.
+func (db *DB) CapacitySnapshots(ctx context.Context, eventID int64, from time.Time, afterID int64, limit int) ([]CapacitySnapshot, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM events WHERE id = ? AND status != ?)`, eventID, EventStatusDraft).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check event existence: %w", err)
+	}
+	if !exists {
+		return nil, ErrEventNotFound
+	}
+
+	query := `SELECT id, available_spots, captured_at FROM capacity_snapshots WHERE event_id = ? AND captured_at >= ?`
+	args := []any{eventID, sqliteTimestamp(from)}
+	if afterID > 0 {
+		query += ` AND (captured_at, id) > (SELECT captured_at, id FROM capacity_snapshots WHERE id = ?)`
+		args = append(args, afterID)
+	}
+	rows, err := db.QueryContext(ctx, query+` ORDER BY captured_at, id LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query capacity snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []CapacitySnapshot{}
+	for rows.Next() {
+		var s CapacitySnapshot
+		var capturedAt sqliteTime
+		if err := rows.Scan(&s.ID, &s.AvailableSpots, &capturedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan capacity snapshot: %w", err)
+		}
+		s.CapturedAt = capturedAt.Time
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// RunSnapshotter records capacity snapshots each interval, pruning those older than
+// retention (0 keeps them all), until ctx is cancelled.
+func RunSnapshotter(ctx context.Context, db *DB, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("capacity snapshot worker stopping")
+			return
+		case <-ticker.C:
+			if _, err := db.RecordCapacitySnapshots(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("failed recording capacity snapshots", "error", err)
+			}
+			if retention <= 0 {
+				continue
+			}
+			if _, err := db.PruneCapacitySnapshots(ctx, db.Clock.Now().Add(-retention)); err != nil && ctx.Err() == nil {
+				slog.Error("failed pruning capacity snapshots", "error", err)
+			}
+		}
+	}
+}
+
+// HandleEventVelocity handles GET /events/{id}/velocity?from=&after=&limit=, one page of
+// the event's recorded available_spots over time, oldest first. next_cursor, when present,
+// is the after= value for the following page.
+func (h *Handlers) HandleEventVelocity(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
+		return
+	}
+
+	var from time.Time
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": "from must be an RFC3339 timestamp"})
+			return
+		}
+	}
+	var after int64
+	if v := r.URL.Query().Get("after"); v != "" {
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil || after < 1 {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": "after must be a snapshot id"})
+			return
+		}
+	}
+	limit := defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxPageLimit {
+			SendJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageLimit)})
+			return
+		}
+	}
+
+	// One extra row tells whether another page follows
+	snapshots, err := h.DB.CapacitySnapshots(r.Context(), eventID, from, after, limit+1)
+	if errors.Is(err, ErrEventNotFound) {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error while loading snapshots"})
+		return
+	}
+	resp := map[string]interface{}{"event_id": eventID, "snapshots": snapshots}
+	if len(snapshots) > limit {
+		resp["snapshots"] = snapshots[:limit]
+		resp["next_cursor"] = snapshots[limit-1].ID
+	}
+	SendJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventVelocityReturnsSnapshotsInOrder(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+	router := (&Handlers{DB: db}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Morning Yoga", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	draft, err := db.CreateEvent(ctx, Event{Name: "Evening Yoga", TotalSpots: 5, Status: EventStatusDraft})
+	if err != nil {
+		t.Fatalf("Failed to create draft: %v", err)
+	}
+
+	first := clock.Now()
+	if n, err := db.RecordCapacitySnapshots(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected one snapshot, got %d (%v)", n, err)
+	}
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "a@example.com", IdempotencyKey: "key_a", Quantity: 2}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	clock.Advance(time.Hour)
+	if _, err := db.RecordCapacitySnapshots(ctx); err != nil {
+		t.Fatalf("Failed to record snapshots: %v", err)
+	}
+
+	rec := serve(router, http.MethodGet, fmt.Sprintf("/events/%d/velocity", evt.ID), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Snapshots []CapacitySnapshot `json:"snapshots"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots, got %+v", body.Snapshots)
+	}
+	if s := body.Snapshots[0]; s.AvailableSpots != 5 || !s.CapturedAt.Equal(first) {
+		t.Errorf("Expected 5 spots at %v first, got %+v", first, s)
+	}
+	if s := body.Snapshots[1]; s.AvailableSpots != 3 || !s.CapturedAt.Equal(clock.Now()) {
+		t.Errorf("Expected 3 spots at %v second, got %+v", clock.Now(), s)
+	}
+
+	if rec := serve(router, http.MethodGet, fmt.Sprintf("/events/%d/velocity", draft.ID), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected a draft to look missing, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(router, http.MethodGet, "/events/424242/velocity", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing event, got %d", rec.Code)
+	}
+}
+
+func TestEventVelocityPagesAndPrunes(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+	h := &Handlers{DB: db}
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Evening Pilates", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	start := clock.Now()
+	for range 3 {
+		if _, err := db.RecordCapacitySnapshots(ctx); err != nil {
+			t.Fatalf("Failed to record snapshots: %v", err)
+		}
+		clock.Advance(time.Hour)
+	}
+
+	type page struct {
+		Snapshots  []CapacitySnapshot `json:"snapshots"`
+		NextCursor *int64             `json:"next_cursor"`
+	}
+	velocity := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/events/x/velocity"+query, nil)
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		rec := httptest.NewRecorder()
+		h.HandleEventVelocity(rec, req)
+		return rec
+	}
+	get := func(query string) page {
+		t.Helper()
+		rec := velocity(query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var p page
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return p
+	}
+
+	first := get("?limit=2")
+	third := start.Add(2 * time.Hour)
+	if len(first.Snapshots) != 2 || first.NextCursor == nil || *first.NextCursor != first.Snapshots[1].ID {
+		t.Fatalf("Expected 2 snapshots and a next_cursor, got %+v", first)
+	}
+	second := get(fmt.Sprintf("?limit=2&after=%d", *first.NextCursor))
+	if len(second.Snapshots) != 1 || !second.Snapshots[0].CapturedAt.Equal(third) || second.NextCursor != nil {
+		t.Errorf("Expected only the snapshot at %v and no next_cursor, got %+v", third, second)
+	}
+	if p := get("?from=" + third.Format(time.RFC3339)); len(p.Snapshots) != 1 || !p.Snapshots[0].CapturedAt.Equal(third) {
+		t.Errorf("Expected from to skip earlier captures, got %+v", p)
+	}
+
+	for _, query := range []string{"?from=yesterday", "?after=0", "?after=x", "?limit=0", fmt.Sprintf("?limit=%d", maxPageLimit+1)} {
+		if rec := velocity(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+
+	if n, err := db.PruneCapacitySnapshots(ctx, start.Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("Expected one snapshot pruned, got %d (%v)", n, err)
+	}
+	if p := get(""); len(p.Snapshots) != 2 || !p.Snapshots[0].CapturedAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the two newest snapshots to remain, got %+v", p.Snapshots)
+	}
+}
+
+func TestEventVelocityPagesThroughCapturesInTheSameSecond(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+	h := &Handlers{DB: db}
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Evening Pilates", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	// A sub-second interval stamps both captures with the same whole second
+	for range 2 {
+		if _, err := db.RecordCapacitySnapshots(ctx); err != nil {
+			t.Fatalf("Failed to record snapshots: %v", err)
+		}
+		clock.Advance(500 * time.Millisecond)
+	}
+
+	seen := map[int64]bool{}
+	query := "?limit=1"
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatalf("Paging did not finish; saw %v", seen)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/events/x/velocity"+query, nil)
+		req.SetPathValue("id", fmt.Sprint(evt.ID))
+		rec := httptest.NewRecorder()
+		h.HandleEventVelocity(rec, req)
+		var p struct {
+			Snapshots  []CapacitySnapshot `json:"snapshots"`
+			NextCursor *int64             `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, s := range p.Snapshots {
+			if seen[s.ID] {
+				t.Fatalf("Snapshot %d returned twice", s.ID)
+			}
+			seen[s.ID] = true
+		}
+		if p.NextCursor == nil {
+			break
+		}
+		query = fmt.Sprintf("?limit=1&after=%d", *p.NextCursor)
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected both snapshots across the pages, got %v", seen)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ErrorKind is the broad category of a SQLite failure, for callers that need to react to
+// the kind of constraint that fired rather than to the driver's message text.
+type ErrorKind int
+
+const (
+	ErrorKindOther ErrorKind = iota
+	ErrorKindUnique
+	ErrorKindCheck
+	ErrorKindForeignKey
+	ErrorKindBusy
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindUnique:
+		return "unique"
+	case ErrorKindCheck:
+		return "check"
+	case ErrorKindForeignKey:
+		return "foreign_key"
+	case ErrorKindBusy:
+		return "busy"
+	default:
+		return "other"
+	}
+}
+
+// ErrDatabaseBusy reports that SQLite could not get a lock in time; retrying may succeed.
+var ErrDatabaseBusy = errors.New("database is busy, please retry")
+
+// classifySQLiteError inspects a (possibly wrapped) modernc.org/sqlite error. Anything that is
+// not a SQLite error, including nil, is ErrorKindOther.
+func classifySQLiteError(err error) ErrorKind {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return ErrorKindOther
+	}
+
+	switch code := sqliteErr.Code(); code {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return ErrorKindUnique
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		return ErrorKindCheck
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return ErrorKindForeignKey
+	default:
+		// Extended result codes keep the primary code in the low byte
+		if primary := code & 0xff; primary == sqlite3.SQLITE_BUSY || primary == sqlite3.SQLITE_LOCKED {
+			return ErrorKindBusy
+		}
+		return ErrorKindOther
+	}
+}
+
+// uniqueViolationOn reports whether err is a UNIQUE failure of the named constraint: an
+// index for expression indexes, which SQLite names as "index 'name'", or table.column
+// otherwise. SQLite carries the name only in the message, hence the text match.
+func uniqueViolationOn(err error, name string) bool {
+	if classifySQLiteError(err) != ErrorKindUnique {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "index '"+name+"'") || strings.Contains(msg, "failed: "+name)
+}
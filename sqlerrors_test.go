@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClassifySQLiteError(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, `PRAGMA foreign_keys = ON`); err != nil {
+		t.Fatalf("Failed to enable foreign keys: %v", err)
+	}
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Constraint Lab", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "first@example.com", IdempotencyKey: "dup"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+		want  ErrorKind
+	}{
+		{"unique", `INSERT INTO tickets (event_id, user_email, idempotency_key, expires_at) VALUES (?, 'second@example.com', 'dup', ?)`, []any{evt.ID, noExpiry}, ErrorKindUnique},
+		{"check", `UPDATE events SET available_spots = -1 WHERE id = ?`, []any{evt.ID}, ErrorKindCheck},
+		{"foreign key", `INSERT INTO tickets (event_id, user_email, idempotency_key, expires_at) VALUES (9999, 'ghost@example.com', 'ghost', ?)`, []any{noExpiry}, ErrorKindForeignKey},
+		{"syntax", `SELEC 1`, nil, ErrorKindOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.ExecContext(ctx, tt.query, tt.args...)
+			if err == nil {
+				t.Fatal("Expected the statement to fail")
+			}
+			// Classification must survive the %w wrapping db.go applies
+			if got := classifySQLiteError(fmt.Errorf("wrapped: %w", err)); got != tt.want {
+				t.Errorf("Expected %v, got %v (%v)", tt.want, got, err)
+			}
+		})
+	}
+
+	if got := classifySQLiteError(errors.New("not from sqlite")); got != ErrorKindOther {
+		t.Errorf("Expected a plain error to be other, got %v", got)
+	}
+	if got := classifySQLiteError(nil); got != ErrorKindOther {
+		t.Errorf("Expected nil to be other, got %v", got)
+	}
+}
+
+func TestUniqueViolationOnNamesTheConstraint(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	insert := func(query string, args ...any) error {
+		_, err := db.ExecContext(ctx, query, args...)
+		return err
+	}
+
+	external := `INSERT INTO events (name, total_spots, available_spots, organizer_email, external_id) VALUES ('Lab', 1, 1, 'host@example.com', 'ext-1')`
+	if err := insert(external); err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+	err := insert(external)
+	if !uniqueViolationOn(err, "idx_events_external_id") {
+		t.Errorf("Expected the external id index to be named, got %v", err)
+	}
+	if uniqueViolationOn(err, "idx_tickets_claim_token") {
+		t.Errorf("Expected another index not to match %v", err)
+	}
+
+	tag := `INSERT INTO event_tags (event_id, tag) VALUES (1, 'jazz')`
+	if err := insert(tag); err != nil {
+		t.Fatalf("Failed to insert tag: %v", err)
+	}
+	if err := insert(tag); classifySQLiteError(err) != ErrorKindUnique || uniqueViolationOn(err, "idx_events_external_id") {
+		t.Errorf("Expected a tag collision not to look like a duplicate external id, got %v", err)
+	}
+	if uniqueViolationOn(errors.New("index 'idx_events_external_id'"), "idx_events_external_id") {
+		t.Error("Expected a non-SQLite error never to match")
+	}
+}
+
+func TestClassifySQLiteErrorBusy(t *testing.T) {
+	path := t.TempDir() + "/busy.db"
+	holder, err := NewDB(fmt.Sprintf("file:%s?mode=rwc", path))
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer holder.Close()
+	contender, err := NewDB(fmt.Sprintf("file:%s?mode=rwc", path))
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer contender.Close()
+
+	if err := holder.InitSchema(context.Background()); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+
+	// Hold the write lock on one connection so the other cannot take it
+	tx, err := holder.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO events (name, total_spots, available_spots) VALUES ('Lock', 1, 1)`); err != nil {
+		t.Fatalf("Failed to take write lock: %v", err)
+	}
+
+	_, err = contender.Exec(`INSERT INTO events (name, total_spots, available_spots) VALUES ('Blocked', 1, 1)`)
+	if got := classifySQLiteError(err); got != ErrorKindBusy {
+		t.Errorf("Expected busy, got %v (%v)", got, err)
+	}
+}
+
+func TestCapacityCheckViolationIsSoldOut(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Overdrawn", TotalSpots: 1})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	// Stand-in for a buggy code path that takes one spot too many behind the guard's back
+	if _, err := db.ExecContext(ctx, `
+		CREATE TRIGGER over_decrement AFTER UPDATE OF available_spots ON events
+		WHEN NEW.available_spots < OLD.available_spots
+		BEGIN
+			UPDATE events SET available_spots = available_spots - 1 WHERE id = NEW.id;
+		END
+	`); err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+
+	_, err = db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "last@example.com", IdempotencyKey: "key_last"})
+	if !errors.Is(err, ErrSoldOut) {
+		t.Fatalf("Expected ErrSoldOut, got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/events/%d/register", evt.ID), strings.NewReader(`{"email":"last@example.com","idempotency_key":"key_last"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Role", "user")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected a 409 sold-out response, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	got, err := db.GetEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to load event: %v", err)
+	}
+	if got.AvailableSpots != 1 {
+		t.Errorf("Expected the failed registration to leave 1 spot, got %d", got.AvailableSpots)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultStatsMaxAge is how old cached stats may get before a read recomputes them.
+const defaultStatsMaxAge = time.Minute
+
+// defaultLowAvailabilityPercent is the share of spots left below which an event is
+// flagged as almost sold out, when Handlers.LowAvailabilityPercent is unset.
+const defaultLowAvailabilityPercent = 10
+
+// EventStats is a point-in-time summary of an event's sales funnel.
+type EventStats struct {
+	EventID        int64 `json:"event_id"`
+	TotalSpots     int   `json:"total_spots"`
+	AvailableSpots int   `json:"available_spots"`
+	Reserved       int   `json:"reserved"`
+	Confirmed      int   `json:"confirmed"`
+	Cancelled      int   `json:"cancelled"`
+	// ConversionRate is confirmed tickets over all tickets ever issued, 0 when none were.
+	ConversionRate float64   `json:"conversion_rate"`
+	SoldOut        bool      `json:"sold_out"`
+	ComputedAt     time.Time `json:"computed_at"`
+	// PercentRemaining is available over total spots as a percentage, to one decimal.
+	PercentRemaining float64 `json:"percent_remaining"`
+	// LowAvailability marks an event that still has spots, but fewer than the
+	// configured percentage, so frontends can show "Almost sold out!".
+	LowAvailability bool `json:"low_availability"`
+}
+
+// markAvailability fills in PercentRemaining and LowAvailability for thresholdPercent.
+func (s *EventStats) markAvailability(thresholdPercent float64) {
+	if s.TotalSpots <= 0 {
+		return
+	}
+	percent := 100 * float64(s.AvailableSpots) / float64(s.TotalSpots)
+	s.PercentRemaining = math.Round(percent*10) / 10
+	s.LowAvailability = s.AvailableSpots > 0 && percent < thresholdPercent
+}
+
+// ComputeEventStats builds EventStats for every event but drafts in a single aggregate query.
+func (db *DB) ComputeEventStats(ctx context.Context) (map[int64]EventStats, error) {
+	return db.computeEventStats(ctx, ``)
+}
+
+// ComputeOneEventStats builds EventStats for eventID alone, or reports ErrEventNotFound
+// when it does not exist or is a draft.
+func (db *DB) ComputeOneEventStats(ctx context.Context, eventID int64) (EventStats, error) {
+	stats, err := db.computeEventStats(ctx, ` AND e.id = ?`, eventID)
+	if err != nil {
+		return EventStats{}, err
+	}
+	s, ok := stats[eventID]
+	if !ok {
+		return EventStats{}, ErrEventNotFound
+	}
+	return s, nil
+}
+
+// computeEventStats builds EventStats for the non-draft events matching cond.
+func (db *DB) computeEventStats(ctx context.Context, cond string, args ...any) (map[int64]EventStats, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.id, e.total_spots, e.available_spots,
+			COALESCE(SUM(t.status = 'reserved'), 0),
+			COALESCE(SUM(t.status = 'confirmed'), 0),
+			COALESCE(SUM(t.status = 'cancelled'), 0)
+		FROM events e
+		LEFT JOIN tickets t ON t.event_id = e.id
+		WHERE e.status != ?`+cond+`
+		GROUP BY e.id
+	`, append([]any{EventStatusDraft}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := db.Clock.Now().UTC()
+	stats := make(map[int64]EventStats)
+	for rows.Next() {
+		var s EventStats
+		if err := rows.Scan(&s.EventID, &s.TotalSpots, &s.AvailableSpots, &s.Reserved, &s.Confirmed, &s.Cancelled); err != nil {
+			return nil, err
+		}
+		if issued := s.Reserved + s.Confirmed + s.Cancelled; issued > 0 {
+			s.ConversionRate = float64(s.Confirmed) / float64(issued)
+		}
+		s.SoldOut = s.AvailableSpots == 0
+		s.ComputedAt = now
+		stats[s.EventID] = s
+	}
+	return stats, rows.Err()
+}
+
+// StatsAggregator keeps the latest EventStats in memory so dashboard reads never hit the
+// database. Run refreshes it on an interval; Get refreshes on demand once data is older
+// than MaxAge.
+type StatsAggregator struct {
+	db     *DB
+	MaxAge time.Duration
+
+	mu          sync.RWMutex
+	stats       map[int64]EventStats
+	refreshedAt time.Time
+}
+
+func NewStatsAggregator(db *DB, maxAge time.Duration) *StatsAggregator {
+	return &StatsAggregator{db: db, MaxAge: maxAge}
+}
+
+// Refresh recomputes every event's stats and swaps them in.
+func (a *StatsAggregator) Refresh(ctx context.Context) error {
+	stats, err := a.db.ComputeEventStats(ctx)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.stats = stats
+	a.refreshedAt = a.db.Clock.Now()
+	a.mu.Unlock()
+	return nil
+}
+
+// Run refreshes every interval until ctx is cancelled.
+func (a *StatsAggregator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("stats aggregator stopping")
+			return
+		case <-ticker.C:
+			if err := a.Refresh(ctx); err != nil {
+				slog.Error("failed refreshing event stats", "error", err)
+			}
+		}
+	}
+}
+
+// Get returns the cached stats for eventID, refreshing everything first if the cache is
+// stale. An event a fresh cache has not seen (e.g. created since the last refresh) is
+// computed on its own, so probing made-up ids costs one indexed query, not a full refresh.
+func (a *StatsAggregator) Get(ctx context.Context, eventID int64) (EventStats, error) {
+	a.mu.RLock()
+	s, ok := a.stats[eventID]
+	stale := a.stats == nil || a.db.Clock.Now().Sub(a.refreshedAt) > a.MaxAge
+	a.mu.RUnlock()
+	if ok && !stale {
+		return s, nil
+	}
+	if !stale {
+		s, err := a.db.ComputeOneEventStats(ctx, eventID)
+		if err != nil {
+			return EventStats{}, err
+		}
+		a.mu.Lock()
+		a.stats[eventID] = s
+		a.mu.Unlock()
+		return s, nil
+	}
+
+	if err := a.Refresh(ctx); err != nil {
+		return EventStats{}, err
+	}
+	a.mu.RLock()
+	s, ok = a.stats[eventID]
+	a.mu.RUnlock()
+	if !ok {
+		return EventStats{}, ErrEventNotFound
+	}
+	return s, nil
+}
+
+// HandleEventStats handles GET /events/{id}/stats
+func (h *Handlers) HandleEventStats(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid event ID format"})
+		return
+	}
+
+	stats, err := h.Stats.Get(r.Context(), eventID)
+	if errors.Is(err, ErrEventNotFound) {
+		SendJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	stats.markAvailability(h.lowAvailabilityPercent())
+	SendJSON(w, http.StatusOK, stats)
+}
+
+func (h *Handlers) lowAvailabilityPercent() float64 {
+	if h.LowAvailabilityPercent > 0 {
+		return h.LowAvailabilityPercent
+	}
+	return defaultLowAvailabilityPercent
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStatsAggregatorMatchesDirectCounts(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Funnel", TotalSpots: 4})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	var tickets []int64
+	for i := 0; i < 4; i++ {
+		registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: fmt.Sprintf("f%d@example.com", i), IdempotencyKey: fmt.Sprintf("funnel_%d", i)})
+		if err != nil {
+			t.Fatalf("Failed to register: %v", err)
+		}
+		id := registered.ID
+		tickets = append(tickets, id)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.ConfirmReservation(ctx, tickets[i], fmt.Sprintf("f%d@example.com", i), ""); err != nil {
+			t.Fatalf("Failed to confirm: %v", err)
+		}
+	}
+	if err := db.CancelTicket(ctx, tickets[3], "f3@example.com"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+
+	agg := NewStatsAggregator(db, time.Minute)
+	if err := agg.Refresh(ctx); err != nil {
+		t.Fatalf("Failed to refresh: %v", err)
+	}
+	got, err := agg.Get(ctx, evt.ID)
+	if err != nil {
+		t.Fatalf("Failed to read stats: %v", err)
+	}
+
+	var reserved, confirmed, cancelled, available int
+	if err := db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM tickets WHERE event_id = ? AND status = 'reserved'),
+			(SELECT COUNT(*) FROM tickets WHERE event_id = ? AND status = 'confirmed'),
+			(SELECT COUNT(*) FROM tickets WHERE event_id = ? AND status = 'cancelled'),
+			(SELECT available_spots FROM events WHERE id = ?)
+	`, evt.ID, evt.ID, evt.ID, evt.ID).Scan(&reserved, &confirmed, &cancelled, &available); err != nil {
+		t.Fatalf("Failed to count directly: %v", err)
+	}
+
+	if got.Reserved != reserved || got.Confirmed != confirmed || got.Cancelled != cancelled || got.AvailableSpots != available {
+		t.Errorf("Cached %+v does not match direct counts reserved=%d confirmed=%d cancelled=%d available=%d", got, reserved, confirmed, cancelled, available)
+	}
+	if got.ConversionRate != 0.5 || got.SoldOut {
+		t.Errorf("Expected 50%% conversion and not sold out, got %+v", got)
+	}
+
+	// Cached reads do not see new activity until the data goes stale
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "late@example.com", IdempotencyKey: "late"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if cached, _ := agg.Get(ctx, evt.ID); cached.Reserved != reserved {
+		t.Errorf("Expected a cached read, got reserved=%d", cached.Reserved)
+	}
+	clock.Advance(2 * time.Minute)
+	if fresh, _ := agg.Get(ctx, evt.ID); fresh.Reserved != reserved+1 || !fresh.SoldOut {
+		t.Errorf("Expected a stale read to recompute, got %+v", fresh)
+	}
+}
+
+func TestHandleEventStats(t *testing.T) {
+	db := NewTestDB(t)
+	router := (&Handlers{DB: db}).Routes()
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Just Created", TotalSpots: 3})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	rec := serve(router, http.MethodGet, fmt.Sprintf("/events/%d/stats", evt.ID), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var stats EventStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.EventID != evt.ID || stats.AvailableSpots != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if rec := serve(router, http.MethodGet, "/events/9999/stats", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing event, got %d", rec.Code)
+	}
+}
+
+func TestStatsMissDoesNotRefreshEverything(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+	agg := NewStatsAggregator(db, time.Minute)
+	if err := agg.Refresh(ctx); err != nil {
+		t.Fatalf("Failed to refresh: %v", err)
+	}
+	refreshedAt := agg.refreshedAt
+	clock.Advance(10 * time.Second)
+
+	if _, err := agg.Get(ctx, 424242); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected ErrEventNotFound for a made-up id, got %v", err)
+	}
+	evt, err := db.CreateEvent(ctx, Event{Name: "Since Refresh", TotalSpots: 3})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if s, err := agg.Get(ctx, evt.ID); err != nil || s.AvailableSpots != 3 {
+		t.Errorf("Expected stats for the new event, got %+v (%v)", s, err)
+	}
+	if !agg.refreshedAt.Equal(refreshedAt) {
+		t.Errorf("Expected misses on a fresh cache to leave the full refresh alone")
+	}
+}
+
+func TestStatsFlagLowAvailabilityBelowThreshold(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+	router := h.Routes()
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Nearly Gone", TotalSpots: 20})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	tests := []struct {
+		available int
+		percent   float64
+		low       bool
+	}{
+		{3, 15, false},
+		{2, 10, false}, // exactly at the 10% threshold is not yet low
+		{1, 5, true},
+		{0, 0, false}, // sold out rather than almost
+	}
+	for _, tt := range tests {
+		if _, err := db.Exec(`UPDATE events SET available_spots = ? WHERE id = ?`, tt.available, evt.ID); err != nil {
+			t.Fatalf("Failed to set availability: %v", err)
+		}
+		if err := h.Stats.Refresh(context.Background()); err != nil {
+			t.Fatalf("Failed to refresh stats: %v", err)
+		}
+
+		rec := serve(router, http.MethodGet, fmt.Sprintf("/events/%d/stats", evt.ID), "")
+		var stats EventStats
+		if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+			t.Fatalf("Failed to decode stats: %v", err)
+		}
+		if stats.PercentRemaining != tt.percent || stats.LowAvailability != tt.low {
+			t.Errorf("%d of 20 left: expected percent_remaining %v and low_availability %t, got %v and %t",
+				tt.available, tt.percent, tt.low, stats.PercentRemaining, stats.LowAvailability)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// streamBatchSize is how many events HandleAdminListEvents reads per query. Each batch is
+// loaded in full and its rows closed before anything is written, so a slow client never
+// holds the database connection.
+const streamBatchSize = 200
+
+// HandleAdminListEvents handles GET /admin/events. Every event, drafts and cancelled ones
+// included, is streamed as a JSON array in keyset-paged batches of streamBatchSize rather
+// than collected into one slice first.
+//
+// The 200 is only committed once the first event is written, so a failing query still gets
+// a proper 500. After that a failure can only be logged; the array is left unterminated so
+// clients see invalid JSON instead of mistaking a cut-off list for a complete one.
+func (h *Handlers) HandleAdminListEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	written := 0
+
+	var err error
+	for after := int64(0); ; {
+		var batch []Event
+		if batch, err = h.DB.ListEvents(r.Context(), EventFilter{Limit: streamBatchSize, AfterID: after, IncludeDrafts: true}); err != nil {
+			break
+		}
+		for i := range batch {
+			sep := ","
+			if written == 0 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				sep = "["
+			}
+			if _, err = io.WriteString(w, sep); err != nil {
+				break
+			}
+			if err = enc.Encode(&batch[i]); err != nil {
+				break
+			}
+			written++
+		}
+		if err != nil || len(batch) < streamBatchSize {
+			break
+		}
+		if err = rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			break
+		}
+		err = nil
+		after = batch[len(batch)-1].ID
+	}
+
+	switch {
+	case err != nil && written == 0:
+		SendJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	case err != nil:
+		slog.ErrorContext(r.Context(), "event stream aborted", "written", written, "error", err)
+	case written == 0:
+		SendJSON(w, http.StatusOK, []Event{})
+	default:
+		io.WriteString(w, "]\n")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdminListEventsStreamsValidJSON(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	router := (&Handlers{DB: db, AllowHeaderRole: true}).Routes()
+
+	const total = 10_000
+	events := make([]Event, total)
+	for i := range events {
+		events[i] = Event{Name: fmt.Sprintf("Synthetic %d", i), TotalSpots: 1}
+	}
+	if _, err := db.CreateEvents(ctx, events); err != nil {
+		t.Fatalf("Failed to create events: %v", err)
+	}
+	// Cancelled events are part of the admin listing too
+	if _, err := db.ExecContext(ctx, `UPDATE events SET status = ? WHERE id % 10 = 0`, EventStatusCancelled); err != nil {
+		t.Fatalf("Failed to cancel events: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/events", nil)
+	req.Header.Set("X-Role", "admin")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if !json.Valid(rec.Body.Bytes()) {
+		t.Fatal("Streamed body is not valid JSON")
+	}
+	var got []Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode stream: %v", err)
+	}
+	if len(got) != total {
+		t.Fatalf("Expected %d events, got %d", total, len(got))
+	}
+	cancelled := 0
+	for i, e := range got {
+		if i > 0 && e.ID <= got[i-1].ID {
+			t.Fatalf("Events out of order at %d", i)
+		}
+		if e.Status == EventStatusCancelled {
+			cancelled++
+		}
+	}
+	if cancelled != total/10 {
+		t.Errorf("Expected %d cancelled events, got %d", total/10, cancelled)
+	}
+}
+
+func TestAdminListEventsEmptyAndAborted(t *testing.T) {
+	db := NewTestDB(t)
+	h := &Handlers{DB: db}
+
+	rec := httptest.NewRecorder()
+	h.HandleAdminListEvents(rec, httptest.NewRequest(http.MethodGet, "/admin/events", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if _, err := db.CreateEvent(context.Background(), Event{Name: "Only", TotalSpots: 1}); err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	// A client that hangs up mid-stream keeps its 200 and nothing else is written
+	fw := &flakyWriter{ResponseRecorder: httptest.NewRecorder(), failWrites: true}
+	h.HandleAdminListEvents(wrapResponseWriter(fw), httptest.NewRequest(http.MethodGet, "/admin/events", nil))
+	if fw.headerWrites != 1 || fw.Code != http.StatusOK || fw.Body.Len() != 0 {
+		t.Errorf("Expected a lone 200 header, got %d header writes, status %d, body %q", fw.headerWrites, fw.Code, fw.Body.String())
+	}
+}
+
+// stalledWriter is a client that stops reading: after signalling started, every write
+// blocks until release is closed, whatever happens to the request context.
+type stalledWriter struct {
+	*httptest.ResponseRecorder
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *stalledWriter) Write(b []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	return len(b), nil
+}
+
+func TestStalledStreamDoesNotHoldConnection(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	h := &Handlers{DB: db}
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Held Open", TotalSpots: 5})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	for i := 0; i < streamBatchSize; i++ {
+		if _, err := db.CreateEvent(ctx, Event{Name: fmt.Sprintf("Filler %d", i), TotalSpots: 5}); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+	}
+
+	// The client stops reading partway through the first batch
+	w := &stalledWriter{ResponseRecorder: httptest.NewRecorder(), started: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.HandleAdminListEvents(w, httptest.NewRequest(http.MethodGet, "/admin/events", nil))
+	}()
+	defer func() {
+		close(w.release)
+		<-done
+	}()
+	<-w.started
+
+	// Batches are read in full before writing, so the only connection is free meanwhile
+	readCtx, readCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer readCancel()
+	if _, err := db.GetEvent(readCtx, evt.ID); err != nil {
+		t.Fatalf("Expected reads to proceed while a stream is stalled, got %v", err)
+	}
+	if _, err := db.CreateEvent(readCtx, Event{Name: "Written Meanwhile", TotalSpots: 5}); err != nil {
+		t.Fatalf("Expected writes to proceed while a stream is stalled, got %v", err)
+	}
+}
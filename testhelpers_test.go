@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// NewTestDB returns a migrated database private to the calling test. Each call gets its
+// own file under t.TempDir(), so tests never share state through SQLite's shared cache,
+// and everything is closed and removed when the test ends.
+func NewTestDB(t testing.TB) *DB {
+	t.Helper()
+	db, err := NewDB(fmt.Sprintf("file:%s?mode=rwc", filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.InitSchema(context.Background()); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	return db
+}
+
+func TestNewTestDBIsIsolated(t *testing.T) {
+	ctx := context.Background()
+	first, second := NewTestDB(t), NewTestDB(t)
+
+	if _, err := first.CreateEvent(ctx, Event{Name: "Only In First", TotalSpots: 3}); err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	if n, err := first.CountEvents(ctx, EventFilter{}); err != nil || n != 1 {
+		t.Fatalf("Expected 1 event in the first db, got %d (%v)", n, err)
+	}
+	if n, err := second.CountEvents(ctx, EventFilter{}); err != nil || n != 0 {
+		t.Errorf("Expected the second db to be empty, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// sqliteTimeLayouts are the textual forms SQLite (and the driver) may hand back for
+// DATETIME columns. Values without an offset are UTC, which is what datetime('now')
+// and CURRENT_TIMESTAMP produce.
+var sqliteTimeLayouts = []string{
+	sqliteTimeFormat,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// ParseSQLiteTime converts a SQLite datetime string into a UTC time.Time.
+func ParseSQLiteTime(s string) (time.Time, error) {
+	for _, layout := range sqliteTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised SQLite datetime %q", s)
+}
+
+// sqliteTime scans a DATETIME column whether the driver returns it as time.Time or as
+// text, always normalising to UTC so API responses serialise as RFC3339 "Z" timestamps.
+type sqliteTime struct {
+	time.Time
+}
+
+func (t *sqliteTime) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		t.Time = v.UTC()
+		return nil
+	case string:
+		parsed, err := ParseSQLiteTime(v)
+		t.Time = parsed
+		return err
+	case []byte:
+		parsed, err := ParseSQLiteTime(string(v))
+		t.Time = parsed
+		return err
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into sqliteTime", src)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSQLiteTimeNormalisesToUTC(t *testing.T) {
+	want := time.Date(2026, 3, 1, 12, 34, 56, 0, time.UTC)
+	for _, in := range []string{
+		"2026-03-01 12:34:56",
+		"2026-03-01T12:34:56Z",
+		"2026-03-01T13:34:56+01:00",
+		"2026-03-01 07:34:56-05:00",
+	} {
+		got, err := ParseSQLiteTime(in)
+		if err != nil {
+			t.Errorf("ParseSQLiteTime(%q) failed: %v", in, err)
+			continue
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("ParseSQLiteTime(%q) = %v, want %v in UTC", in, got, want)
+		}
+	}
+
+	if _, err := ParseSQLiteTime("next tuesday"); err == nil {
+		t.Error("Expected an error for garbage input")
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// soldOutEventWithWaitlist creates an event with capacity seats, fills it and queues
+// waiting users on its waitlist. It returns the event and the ticket ids of the holders.
+func soldOutEventWithWaitlist(t *testing.T, db *DB, capacity, waiting int) (*Event, []int64) {
+	t.Helper()
+	ctx := context.Background()
+
+	evt, err := db.CreateEvent(ctx, Event{Name: "Popular Talk", TotalSpots: capacity})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	var tickets []int64
+	for i := 0; i < capacity; i++ {
+		registered, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: fmt.Sprintf("holder%d@example.com", i), IdempotencyKey: fmt.Sprintf("holder_%d", i)})
+		if err != nil {
+			t.Fatalf("Failed to fill event: %v", err)
+		}
+		id := registered.ID
+		tickets = append(tickets, id)
+	}
+	for i := 0; i < waiting; i++ {
+		pos, err := db.JoinWaitlist(ctx, evt.ID, fmt.Sprintf("waiter%d@example.com", i))
+		if err != nil {
+			t.Fatalf("Failed to join waitlist: %v", err)
+		}
+		if pos != i+1 {
+			t.Fatalf("Expected waitlist position %d, got %d", i+1, pos)
+		}
+	}
+	return evt, tickets
+}
+
+func TestPromoteWaitlistFillsOnlyFreedSeats(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	evt, tickets := soldOutEventWithWaitlist(t, db, 5, 5)
+
+	// A group of three cancels at once
+	for _, id := range tickets[:3] {
+		if _, err := db.ExecContext(ctx, `UPDATE tickets SET status = 'cancelled' WHERE id = ?`, id); err != nil {
+			t.Fatalf("Failed to cancel: %v", err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE events SET available_spots = available_spots + 3 WHERE id = ?`, evt.ID); err != nil {
+		t.Fatalf("Failed to free seats: %v", err)
+	}
+
+	promoted, err := db.PromoteWaitlist(ctx, evt.ID, 5)
+	if err != nil {
+		t.Fatalf("PromoteWaitlist failed: %v", err)
+	}
+
+	want := []string{"waiter0@example.com", "waiter1@example.com", "waiter2@example.com"}
+	if fmt.Sprint(promoted) != fmt.Sprint(want) {
+		t.Errorf("Expected promotions %v, got %v", want, promoted)
+	}
+
+	var remaining, available, reserved int
+	db.QueryRow(`SELECT COUNT(*) FROM waitlist WHERE event_id = ?`, evt.ID).Scan(&remaining)
+	db.QueryRow(`SELECT available_spots FROM events WHERE id = ?`, evt.ID).Scan(&available)
+	db.QueryRow(`SELECT COUNT(*) FROM tickets WHERE event_id = ? AND status = 'reserved' AND user_email LIKE 'waiter%'`, evt.ID).Scan(&reserved)
+	if remaining != 2 || available != 0 || reserved != 3 {
+		t.Errorf("Expected 2 still waiting, 0 available, 3 promoted tickets; got %d, %d, %d", remaining, available, reserved)
+	}
+}
+
+func TestCancelTicketPromotesHeadOfWaitlist(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	evt, tickets := soldOutEventWithWaitlist(t, db, 1, 2)
+
+	if err := db.CancelTicket(ctx, tickets[0], "holder0@example.com"); err != nil {
+		t.Fatalf("CancelTicket failed: %v", err)
+	}
+
+	var holder string
+	if err := db.QueryRow(`SELECT user_email FROM tickets WHERE event_id = ? AND status = 'reserved'`, evt.ID).Scan(&holder); err != nil {
+		t.Fatalf("Expected a promoted reservation: %v", err)
+	}
+	if holder != "waiter0@example.com" {
+		t.Errorf("Expected head of line to be promoted, got %s", holder)
+	}
+
+	if err := db.CancelTicket(ctx, tickets[0], "holder0@example.com"); !errors.Is(err, ErrTicketNotActive) {
+		t.Errorf("Expected ErrTicketNotActive cancelling twice, got %v", err)
+	}
+	if err := db.CancelTicket(ctx, tickets[0], "intruder@example.com"); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("Expected ErrTicketNotFound for the wrong owner, got %v", err)
+	}
+}
+
+func TestLapsedPromotionFallsThroughToNextInLine(t *testing.T) {
+	db := NewTestDB(t)
+	clock := NewMockClock(time.Date(2030, 5, 1, 10, 0, 0, 0, time.UTC))
+	db.Clock = clock
+	ctx := context.Background()
+	evt, tickets := soldOutEventWithWaitlist(t, db, 1, 2)
+
+	if err := db.CancelTicket(ctx, tickets[0], "holder0@example.com"); err != nil {
+		t.Fatalf("CancelTicket failed: %v", err)
+	}
+
+	// waiter0 was offered the seat but never confirms
+	clock.Advance(defaultHoldDuration + time.Second)
+	reclaimed, err := db.ReclaimExpiredSeats(ctx)
+	if err != nil || reclaimed != 1 {
+		t.Fatalf("Expected the lapsed offer to be reclaimed, got %d (%v)", reclaimed, err)
+	}
+
+	var holder string
+	if err := db.QueryRow(`SELECT user_email FROM tickets WHERE event_id = ? AND status = 'reserved'`, evt.ID).Scan(&holder); err != nil {
+		t.Fatalf("Expected a promoted reservation: %v", err)
+	}
+	if holder != "waiter1@example.com" {
+		t.Errorf("Expected the next in line to be promoted, got %s", holder)
+	}
+
+	var available, waiting int
+	db.QueryRow(`SELECT available_spots FROM events WHERE id = ?`, evt.ID).Scan(&available)
+	db.QueryRow(`SELECT COUNT(*) FROM waitlist WHERE event_id = ?`, evt.ID).Scan(&waiting)
+	if available != 0 || waiting != 0 {
+		t.Errorf("Expected the seat never to reach general availability and the queue to be empty, got %d available, %d waiting", available, waiting)
+	}
+}
+
+func TestJoinWaitlistRequiresSoldOutEvent(t *testing.T) {
+	db := NewTestDB(t)
+	evt, err := db.CreateEvent(context.Background(), Event{Name: "Roomy", TotalSpots: 10})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	if _, err := db.JoinWaitlist(context.Background(), evt.ID, "eager@example.com"); !errors.Is(err, ErrSpotsAvailable) {
+		t.Errorf("Expected ErrSpotsAvailable, got %v", err)
+	}
+}
+
+func TestJoinWaitlistRejectsBeyondCap(t *testing.T) {
+	db := NewTestDB(t)
+	db.MaxWaitlist = 3
+	ctx := context.Background()
+	h := &Handlers{DB: db}
+	evt, _ := soldOutEventWithWaitlist(t, db, 1, 2)
+
+	join := func(eventID int64, email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf(`{"email":%q}`, email)))
+		req.SetPathValue("id", strconv.FormatInt(eventID, 10))
+		rec := httptest.NewRecorder()
+		h.HandleJoinWaitlist(rec, req)
+		return rec
+	}
+
+	// The last place on the list is still open
+	if rec := join(evt.ID, "third@example.com"); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 up to the cap, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := join(evt.ID, "fourth@example.com")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 beyond the cap, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		WaitlistLength int `json:"waitlist_length"`
+		MaxWaitlist    int `json:"max_waitlist"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.WaitlistLength != 3 || body.MaxWaitlist != 3 {
+		t.Errorf("Expected waitlist_length 3 of 3, got %+v", body)
+	}
+
+	// An event's own max_waitlist wins over the server-wide cap
+	one := 1
+	small, err := db.CreateEvent(ctx, Event{Name: "Tiny Room", TotalSpots: 1, MaxWaitlist: &one})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: small.ID, Email: "holder@example.com", IdempotencyKey: "tiny_holder"}); err != nil {
+		t.Fatalf("Failed to fill event: %v", err)
+	}
+	if _, err := db.JoinWaitlist(ctx, small.ID, "first@example.com"); err != nil {
+		t.Fatalf("Failed to join waitlist: %v", err)
+	}
+	if _, err := db.JoinWaitlist(ctx, small.ID, "second@example.com"); !errors.Is(err, ErrWaitlistFull) {
+		t.Errorf("Expected ErrWaitlistFull, got %v", err)
+	}
+}
+
+func TestPromoteWaitlistIgnoresClientKeysThatLookLikePromotions(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+	evt, tickets := soldOutEventWithWaitlist(t, db, 1, 0)
+	if _, err := db.ExecContext(ctx, `UPDATE events SET total_spots = 2, available_spots = 1 WHERE id = ?`, evt.ID); err != nil {
+		t.Fatalf("Failed to add a spot: %v", err)
+	}
+
+	// A client takes the last spot under the key the first promotion used to get
+	if _, err := db.RegisterForEvent(ctx, RegisterParams{EventID: evt.ID, Email: "squatter@example.com", IdempotencyKey: "waitlist-1"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if _, err := db.JoinWaitlist(ctx, evt.ID, "waiter@example.com"); err != nil {
+		t.Fatalf("Failed to join waitlist: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE tickets SET status = 'cancelled' WHERE id = ?`, tickets[0]); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE events SET available_spots = 1 WHERE id = ?`, evt.ID); err != nil {
+		t.Fatalf("Failed to free the seat: %v", err)
+	}
+
+	promoted, err := db.PromoteWaitlist(ctx, evt.ID, 1)
+	if err != nil {
+		t.Fatalf("PromoteWaitlist failed: %v", err)
+	}
+	if len(promoted) != 1 || promoted[0] != "waiter@example.com" {
+		t.Errorf("Expected waiter@example.com to be promoted, got %v", promoted)
+	}
+
+	var key string
+	if err := db.QueryRowContext(ctx, `SELECT idempotency_key FROM tickets WHERE user_email = 'waiter@example.com'`).Scan(&key); err != nil {
+		t.Fatalf("Failed to read promoted ticket: %v", err)
+	}
+	if idempotencyKeyPattern.MatchString(key) {
+		t.Errorf("Expected promotion key %q to be one clients cannot send", key)
+	}
+}